@@ -0,0 +1,124 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/steffakasid/eslog"
+	wiper "github.com/steffakasid/wiper/internal"
+)
+
+// Constants used in dupes command flags
+const (
+	keepFlag     = "keep"
+	priorityFlag = "priority"
+	dryRunFlag   = "dry-run"
+)
+
+var dupesCmd = &cobra.Command{
+	Use:   "dupes",
+	Short: "Find duplicate files below base_dir and wipe all but one copy.",
+	Long: `Find files with identical content below base_dir and apply the configured
+action to all but one copy of each. Which copy is kept is chosen by the keep
+policy: oldest (default), newest, shortest_path or priority.`,
+	Example: `  wiper dupes --dry-run
+  wiper dupes --keep newest --use_trash
+  wiper dupes --keep priority --priority ~/Documents --priority ~/Downloads`,
+	Args: cobra.NoArgs,
+	RunE: RunDupesE,
+}
+
+func RunDupesE(cmd *cobra.Command, args []string) error {
+	setupLogging()
+	if err := wiper.RefreshInstanceFromViper(); err != nil {
+		return err
+	}
+	w := wiper.GetInstance()
+
+	flags := cmd.Flags()
+	if flags.Changed(keepFlag) {
+		w.Dupes.Keep, _ = flags.GetString(keepFlag)
+	}
+	if flags.Changed(priorityFlag) {
+		w.Dupes.Priority, _ = flags.GetStringArray(priorityFlag)
+	}
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	lock, err := lockRun(cmd, w)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		eslog.LogIfError(lock.Release(), eslog.Warn)
+	}()
+
+	started := time.Now()
+	report := &wiper.Report{}
+	collect := func(events chan wiper.Event, done chan struct{}) {
+		for event := range events {
+			logEvent(event)
+			report.Add(event)
+		}
+		close(done)
+	}
+
+	events := make(chan wiper.Event)
+	done := make(chan struct{})
+	go collect(events, done)
+	groups, err := w.FindDuplicates(events)
+	close(events)
+	<-done
+	if err != nil {
+		return err
+	}
+	printDuplicates(cmd.OutOrStdout(), groups)
+	dryRun, _ := flags.GetBool(dryRunFlag)
+	wipe := !dryRun && len(groups) > 0
+	if wipe {
+		events = make(chan wiper.Event)
+		done = make(chan struct{})
+		go collect(events, done)
+		w.WipeDuplicates(groups, events)
+		<-done
+		run := w.NewHistoryRun(started, report)
+		saveHistory(&run)
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d errors occurred during wiping duplicates", len(report.Errors))
+	}
+	if wipe {
+		fmt.Fprintf(cmd.OutOrStdout(), "Wiped %d duplicate files.\n", w.WipedFiles)
+	}
+	return nil
+}
+
+func printDuplicates(out io.Writer, groups []wiper.DuplicateGroup) {
+	var copies int
+	var redundant int64
+	for _, group := range groups {
+		fmt.Fprintf(out, "%s (%s)\n", group.Keep.Path, wiper.FormatSize(group.Size))
+		for _, file := range group.Remove {
+			fmt.Fprintf(out, "  duplicate %s\n", file.Path)
+		}
+		copies += len(group.Remove)
+		redundant += group.Size * int64(len(group.Remove))
+	}
+	fmt.Fprintf(out, "Found %d files with %d duplicates taking up %s.\n", len(groups), copies, wiper.FormatSize(redundant))
+}
+
+func init() {
+	flags := dupesCmd.Flags()
+	flags.String(keepFlag, "", "Which copy to keep: oldest, newest, shortest_path or priority. [default: dupes.keep or oldest]")
+	flags.StringArray(priorityFlag, []string{}, "Directories in order of preference for --keep priority. [default: dupes.priority]")
+	flags.Bool(dryRunFlag, false, "Only list the duplicates.")
+	flags.String(waitFlag, "", "How long to wait for another wiper run on the same base_dir to finish, e.g. 10m. [default: fail immediately]")
+
+	rootCmd.AddCommand(dupesCmd)
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	wiper "github.com/steffakasid/wiper/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDupesE(t *testing.T) {
+	setup := func(t *testing.T) (string, *cobra.Command, *bytes.Buffer) {
+		t.Helper()
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("XDG_STATE_HOME", "")
+		testDir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(testDir, "Downloads"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(testDir, "report.pdf"), []byte("report"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(testDir, "Downloads", "report (1).pdf"), []byte("report"), 0o644))
+		older := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(filepath.Join(testDir, "report.pdf"), older, older))
+
+		wiper.CfgFile = ""
+		viper.Reset()
+		wiper.InitConfig()
+		viper.Set(baseDirFlag, testDir)
+
+		out := &bytes.Buffer{}
+		cmd := &cobra.Command{}
+		cmd.SetOut(out)
+		cmd.Flags().AddFlagSet(dupesCmd.Flags())
+		return testDir, cmd, out
+	}
+
+	t.Run("green case - dry run only lists duplicates", func(t *testing.T) {
+		testDir, cmd, out := setup(t)
+		require.NoError(t, cmd.Flags().Set(dryRunFlag, "true"))
+		t.Cleanup(func() {
+			require.NoError(t, cmd.Flags().Set(dryRunFlag, "false"))
+		})
+
+		require.NoError(t, RunDupesE(cmd, []string{}))
+		assert.Contains(t, out.String(), "duplicate "+filepath.Join(testDir, "Downloads", "report (1).pdf"))
+		assert.Contains(t, out.String(), "Found 1 files with 1 duplicates taking up 6 B.")
+		assert.FileExists(t, filepath.Join(testDir, "Downloads", "report (1).pdf"))
+	})
+
+	t.Run("green case - duplicates are wiped by keep policy", func(t *testing.T) {
+		testDir, cmd, out := setup(t)
+		require.NoError(t, cmd.Flags().Set(keepFlag, "priority"))
+		require.NoError(t, cmd.Flags().Set(priorityFlag, filepath.Join(testDir, "Downloads")))
+
+		require.NoError(t, RunDupesE(cmd, []string{}))
+		assert.Contains(t, out.String(), "Wiped 1 duplicate files.")
+		assert.NoFileExists(t, filepath.Join(testDir, "report.pdf"))
+		assert.FileExists(t, filepath.Join(testDir, "Downloads", "report (1).pdf"))
+	})
+
+	t.Run("red case - invalid keep policy", func(t *testing.T) {
+		_, cmd, _ := setup(t)
+		require.NoError(t, cmd.Flags().Set(keepFlag, "largest"))
+		assert.Error(t, RunDupesE(cmd, []string{}))
+	})
+
+	t.Run("red case - a run on a parent of base_dir holds the lock", func(t *testing.T) {
+		testDir, cmd, _ := setup(t)
+		require.NoError(t, cmd.Flags().Set(keepFlag, "oldest"))
+		lock, err := (&wiper.Wiper{BaseDir: filepath.Dir(testDir)}).Lock(0)
+		require.NoError(t, err)
+		t.Cleanup(func() { assert.NoError(t, lock.Release()) })
+
+		assert.ErrorContains(t, RunDupesE(cmd, []string{}), "use --wait")
+		assert.FileExists(t, filepath.Join(testDir, "report.pdf"))
+		assert.FileExists(t, filepath.Join(testDir, "Downloads", "report (1).pdf"))
+	})
+}
@@ -0,0 +1,146 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	wiper "github.com/steffakasid/wiper/internal"
+)
+
+// Constants used in history command flags
+const (
+	pathFlag  = "path"
+	limitFlag = "limit"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the recorded wiper runs.",
+	Long: `List the wiper runs recorded in $XDG_STATE_HOME/wiper/history, newest
+first. Every run records its counters, errors and the entries it wiped, so
+--path tells whether and when wiper took a file.`,
+	Example: `  wiper history
+  wiper history --path ~/Downloads/report.pdf
+  wiper history show 20240305-101500`,
+	Args: cobra.NoArgs,
+	RunE: RunHistoryE,
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show the details and affected entries of a recorded run.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  RunHistoryShowE,
+}
+
+func RunHistoryE(cmd *cobra.Command, args []string) error {
+	runs, err := wiper.LoadHistory()
+	if err != nil {
+		return err
+	}
+	flags := cmd.Flags()
+	path, _ := flags.GetString(pathFlag)
+	if path != "" {
+		if path, err = filepath.Abs(expandHome(path)); err != nil {
+			return err
+		}
+	}
+	limit, _ := flags.GetInt(limitFlag)
+
+	out := cmd.OutOrStdout()
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTARTED\tDURATION\tWIPED FILES\tWIPED DIRS\tSIZE\tERRORS\tBASE DIRS")
+	listed := 0
+	for i := len(runs) - 1; i >= 0 && (limit <= 0 || listed < limit); i-- {
+		run := runs[i]
+		if path != "" && len(run.Affected(path)) == 0 {
+			continue
+		}
+		listed++
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%d\t%s\n", run.ID, run.Started.Format(time.DateTime), run.Duration().Round(time.Millisecond),
+			run.WipedFiles, run.WipedDirs, wiper.FormatSize(run.WipedBytes), len(run.Errors), strings.Join(run.BaseDirs, ", "))
+	}
+	if listed == 0 {
+		if path != "" {
+			fmt.Fprintf(out, "No recorded run affected %s.\n", path)
+		} else {
+			fmt.Fprintln(out, "No recorded runs.")
+		}
+		return nil
+	}
+	return tw.Flush()
+}
+
+func RunHistoryShowE(cmd *cobra.Command, args []string) error {
+	run, err := wiper.LoadHistoryRun(args[0])
+	if err != nil {
+		return err
+	}
+	printHistoryRun(cmd.OutOrStdout(), run)
+	return nil
+}
+
+func printHistoryRun(out io.Writer, run wiper.HistoryRun) {
+	fmt.Fprintf(out, "Run:         %s\n", run.ID)
+	fmt.Fprintf(out, "Started:     %s\n", run.Started.Format(time.DateTime))
+	fmt.Fprintf(out, "Finished:    %s (%s)\n", run.Finished.Format(time.DateTime), run.Duration().Round(time.Millisecond))
+	if run.ConfigFile != "" {
+		fmt.Fprintf(out, "Config:      %s (%s)\n", run.ConfigFile, run.ConfigHash)
+	} else {
+		fmt.Fprintf(out, "Config:      %s\n", run.ConfigHash)
+	}
+	fmt.Fprintf(out, "Base dirs:   %s\n", strings.Join(run.BaseDirs, ", "))
+	fmt.Fprintf(out, "Inspected:   %d files, %d directories\n", run.InspectedFiles, run.InspectedDirs)
+	fmt.Fprintf(out, "Wiped:       %d files, %d directories, %s\n", run.WipedFiles, run.WipedDirs, wiper.FormatSize(run.WipedBytes))
+
+	if len(run.Entries) > 0 {
+		fmt.Fprintln(out)
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ACTION\tSIZE\tRULE\tPATH")
+		for _, entry := range run.Entries {
+			path := entry.Path
+			if entry.Destination != "" {
+				path += " -> " + entry.Destination
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.Action, wiper.FormatSize(entry.Size), entry.Rule, path)
+		}
+		_ = tw.Flush()
+	}
+	if len(run.Errors) > 0 {
+		fmt.Fprintf(out, "\n%d errors:\n", len(run.Errors))
+		for _, err := range run.Errors {
+			fmt.Fprintf(out, "  %s\n", err)
+		}
+	}
+}
+
+// expandHome replaces a leading ~ with the home directory, for paths which
+// were quoted in the shell.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+func init() {
+	flags := historyCmd.Flags()
+	flags.String(pathFlag, "", "Only list runs which wiped this path or entries below it.")
+	flags.Int(limitFlag, 0, "Only list the newest runs. [default: all]")
+
+	historyCmd.AddCommand(historyShowCmd)
+	rootCmd.AddCommand(historyCmd)
+}
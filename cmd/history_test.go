@@ -0,0 +1,79 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	wiper "github.com/steffakasid/wiper/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	newHistoryCmd := func(t *testing.T, path string) (*cobra.Command, *bytes.Buffer) {
+		t.Helper()
+		out := &bytes.Buffer{}
+		cmd := &cobra.Command{}
+		cmd.SetOut(out)
+		cmd.Flags().AddFlagSet(historyCmd.Flags())
+		if path != "" {
+			require.NoError(t, cmd.Flags().Set(pathFlag, path))
+			t.Cleanup(func() { require.NoError(t, cmd.Flags().Set(pathFlag, "")) })
+		}
+		return cmd, out
+	}
+
+	t.Run("green case - runs are listed and shown", func(t *testing.T) {
+		testDir := t.TempDir()
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("XDG_STATE_HOME", "")
+		wiped := filepath.Join(testDir, "a.orig")
+		require.NoError(t, os.WriteFile(wiped, []byte("content"), 0o644))
+
+		wiper.CfgFile = ""
+		viper.Reset()
+		wiper.InitConfig()
+		viper.Set(baseDirFlag, testDir)
+		viper.Set(wipeOutPatternFlag, []string{`\.orig$`})
+		require.NoError(t, RunWiperE(&cobra.Command{}, []string{}))
+
+		cmd, out := newHistoryCmd(t, "")
+		require.NoError(t, RunHistoryE(cmd, []string{}))
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.True(t, strings.HasPrefix(lines[0], "ID "))
+		assert.Contains(t, lines[1], "7 B")
+		assert.True(t, strings.HasSuffix(lines[1], testDir))
+		id := strings.Fields(lines[1])[0]
+
+		out.Reset()
+		require.NoError(t, RunHistoryShowE(cmd, []string{id}))
+		assert.Contains(t, out.String(), "Run:         "+id)
+		assert.Contains(t, out.String(), "Wiped:       1 files, 0 directories, 7 B")
+		assert.Contains(t, out.String(), "wiped   7 B   wipe_out_pattern  "+wiped)
+
+		cmd, out = newHistoryCmd(t, wiped)
+		require.NoError(t, RunHistoryE(cmd, []string{}))
+		assert.Contains(t, out.String(), id)
+
+		cmd, out = newHistoryCmd(t, filepath.Join(testDir, "other"))
+		require.NoError(t, RunHistoryE(cmd, []string{}))
+		assert.Equal(t, "No recorded run affected "+filepath.Join(testDir, "other")+".\n", out.String())
+	})
+
+	t.Run("red case - unknown run", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		cmd, out := newHistoryCmd(t, "")
+		require.NoError(t, RunHistoryE(cmd, []string{}))
+		assert.Equal(t, "No recorded runs.\n", out.String())
+		assert.Error(t, RunHistoryShowE(cmd, []string{"20240101-000000"}))
+	})
+}
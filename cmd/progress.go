@@ -0,0 +1,158 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/steffakasid/eslog"
+	wiper "github.com/steffakasid/wiper/internal"
+	"golang.org/x/term"
+)
+
+// progressInterval is how often the progress line is redrawn.
+const progressInterval = 200 * time.Millisecond
+
+// clearLine moves the cursor to the start of the line and erases it.
+const clearLine = "\r\033[K"
+
+// progressDisplay shows the counters of a running wipe on a single line of a
+// terminal. Log lines are written above it.
+type progressDisplay struct {
+	out     io.Writer
+	width   func() int
+	w       *wiper.Wiper
+	started time.Time
+	mu      sync.Mutex
+	shown   bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// startProgress shows the progress of w on stderr, if stderr is a terminal
+// and neither --no-progress nor --debug is set. It returns nil otherwise.
+func startProgress(noProgress bool, w *wiper.Wiper) *progressDisplay {
+	fd := int(os.Stderr.Fd())
+	if noProgress || viper.GetBool(debugFlag) || !term.IsTerminal(fd) {
+		return nil
+	}
+	width := func() int {
+		width, _, err := term.GetSize(fd)
+		if err != nil {
+			return 80
+		}
+		return width
+	}
+	p := newProgressDisplay(os.Stderr, width, w)
+	eslog.Logger.SetOutput(p.logWriter(os.Stdout))
+	go p.run()
+	return p
+}
+
+func newProgressDisplay(out io.Writer, width func() int, w *wiper.Wiper) *progressDisplay {
+	return &progressDisplay{
+		out:     out,
+		width:   width,
+		w:       w,
+		started: time.Now(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (p *progressDisplay) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.draw()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// Stop removes the progress line and restores the log output. It does
+// nothing on a nil display.
+func (p *progressDisplay) Stop() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	p.mu.Lock()
+	p.clear()
+	p.mu.Unlock()
+	eslog.Logger.SetOutput(os.Stdout)
+}
+
+// draw replaces the progress line. p.mu must be held.
+func (p *progressDisplay) draw() {
+	fmt.Fprint(p.out, clearLine+p.line(time.Since(p.started)))
+	p.shown = true
+}
+
+// clear removes the progress line. p.mu must be held.
+func (p *progressDisplay) clear() {
+	if p.shown {
+		fmt.Fprint(p.out, clearLine)
+		p.shown = false
+	}
+}
+
+// line formats the progress after elapsed, cut to the terminal width.
+func (p *progressDisplay) line(elapsed time.Duration) string {
+	progress := p.w.Progress()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(progress.InspectedFiles) / elapsed.Seconds()
+	}
+	line := fmt.Sprintf("%d dirs, %d files (%.0f/s), wiped %d files, %d dirs, %s",
+		progress.InspectedDirs, progress.InspectedFiles, rate, progress.WipedFiles, progress.WipedDirs, wiper.FormatSize(progress.WipedBytes))
+	width := p.width() - 1
+	if room := width - len([]rune(line)) - 3; room > 3 && progress.CurrentDir != "" {
+		line += " | " + shortenPath(progress.CurrentDir, room)
+	}
+	if runes := []rune(line); len(runes) > width && width > 0 {
+		line = string(runes[:width])
+	}
+	return line
+}
+
+// logWriter returns a writer for the log which writes each line above the
+// progress line.
+func (p *progressDisplay) logWriter(out io.Writer) io.Writer {
+	return writerFunc(func(b []byte) (int, error) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.clear()
+		n, err := out.Write(b)
+		p.draw()
+		return n, err
+	})
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
+
+// shortenPath cuts the start of path to at most width runes.
+func shortenPath(path string, width int) string {
+	runes := []rune(path)
+	if len(runes) <= width {
+		return path
+	}
+	return "…" + string(runes[len(runes)-width+1:])
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	wiper "github.com/steffakasid/wiper/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressDisplay(t *testing.T) {
+	newRun := func(t *testing.T) *wiper.Wiper {
+		t.Helper()
+		fsys := wiper.NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base/projects/wiper/internal", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/projects/wiper/internal/a.orig", make([]byte, 2048), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/b.txt", nil, 0o644))
+		w := &wiper.Wiper{WipeOutPattern: []string{`\.orig$`}, BaseDir: "/base", FS: fsys}
+		events := make(chan wiper.Event)
+		go w.WipeFiles(nil, "", events)
+		for range events {
+		}
+		return w
+	}
+
+	t.Run("green case - counters, rate and current directory", func(t *testing.T) {
+		sut := newProgressDisplay(&bytes.Buffer{}, func() int { return 120 }, newRun(t))
+		assert.Equal(t, "4 dirs, 2 files (1/s), wiped 1 files, 0 dirs, 2.0 KiB | /base/projects/wiper/internal", sut.line(2*time.Second))
+	})
+
+	t.Run("line is cut to the terminal width", func(t *testing.T) {
+		sut := newProgressDisplay(&bytes.Buffer{}, func() int { return 70 }, newRun(t))
+		line := sut.line(time.Second)
+		assert.Equal(t, "4 dirs, 2 files (2/s), wiped 1 files, 0 dirs, 2.0 KiB | …per/internal", line)
+		assert.Len(t, []rune(line), 69)
+
+		sut.width = func() int { return 20 }
+		assert.Equal(t, "4 dirs, 2 files (2/", sut.line(time.Second))
+	})
+
+	t.Run("log lines are written above the progress", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logs := &bytes.Buffer{}
+		sut := newProgressDisplay(out, func() int { return 40 }, newRun(t))
+		sut.draw()
+		out.Reset()
+
+		_, err := fmt.Fprintln(sut.logWriter(logs), "level=INFO msg=wiped")
+		require.NoError(t, err)
+		assert.Equal(t, "level=INFO msg=wiped\n", logs.String())
+		assert.True(t, bytes.HasPrefix(out.Bytes(), []byte(clearLine+clearLine+"4 dirs")))
+
+		out.Reset()
+		sut.clear()
+		assert.Equal(t, clearLine, out.String())
+		var nilDisplay *progressDisplay
+		assert.NotPanics(t, nilDisplay.Stop)
+	})
+}
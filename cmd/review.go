@@ -0,0 +1,516 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"github.com/steffakasid/eslog"
+	wiper "github.com/steffakasid/wiper/internal"
+	"golang.org/x/term"
+)
+
+// previewBytes and previewLines limit the head of a file shown by the
+// preview.
+const (
+	previewBytes = 4096
+	previewLines = 10
+)
+
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Review the entries a run would wipe and wipe the selected ones.",
+	Long: `Scan base_dir without wiping anything and show the matched entries grouped
+by rule and directory. Entries can be deselected, matched directories expanded
+and the head of files previewed before the selected entries are wiped.
+
+Keys: up/down or j/k move, space selects or deselects, right/enter expands,
+left collapses, a selects or deselects everything, p previews a file, w wipes
+the selected entries and q quits without wiping.`,
+	Args: cobra.NoArgs,
+	RunE: RunReviewE,
+}
+
+func RunReviewE(cmd *cobra.Command, args []string) error {
+	setupLogging()
+	stdin, stdout := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(stdin) || !term.IsTerminal(stdout) {
+		return errors.New("wiper review needs a terminal")
+	}
+	if err := wiper.RefreshInstanceFromViper(); err != nil {
+		return err
+	}
+	w := wiper.GetInstance()
+
+	w.DryRun = true
+	events := make(chan wiper.Event)
+	done := make(chan struct{})
+	go func() {
+		for event := range events {
+			logEvent(event)
+		}
+		close(done)
+	}()
+	w.WipeFiles(nil, "", events)
+	<-done
+	w.DryRun = false
+
+	candidates := w.Candidates()
+	if len(candidates) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "Nothing to wipe.")
+		return nil
+	}
+
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return err
+	}
+	model := newReviewModel(w, candidates)
+	apply, err := runReview(model, os.Stdin, cmd.OutOrStdout(), func() (int, int) {
+		width, height, err := term.GetSize(stdout)
+		if err != nil {
+			return 80, 24
+		}
+		return width, height
+	})
+	if restoreErr := term.Restore(stdin, state); restoreErr != nil {
+		eslog.Warnf("Restoring the terminal failed: %s", restoreErr)
+	}
+	if err != nil || !apply {
+		return err
+	}
+	return wipeReviewed(cmd, w, model.selected())
+}
+
+// runReview shows model until it is quit and returns whether the selected
+// entries should be wiped.
+func runReview(model *reviewModel, in io.Reader, out io.Writer, size func() (int, int)) (bool, error) {
+	fmt.Fprint(out, "\033[?1049h\033[?25l")
+	defer fmt.Fprint(out, "\033[?25h\033[?1049l")
+	buf := make([]byte, 32)
+	for {
+		width, height := size()
+		fmt.Fprint(out, "\033[H\033[2J"+strings.Join(model.render(width, height), "\r\n"))
+		n, err := in.Read(buf)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return false, nil
+			}
+			return false, err
+		}
+		if quit, apply := model.handleKey(string(buf[:n])); quit {
+			return apply, nil
+		}
+	}
+}
+
+func wipeReviewed(cmd *cobra.Command, w *wiper.Wiper, selected []wiper.Candidate) error {
+	lock, err := lockRun(cmd, w)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		eslog.LogIfError(lock.Release(), eslog.Warn)
+	}()
+
+	started := time.Now()
+	report := &wiper.Report{}
+	if result := w.RunHook(wiper.HookBefore); result != nil {
+		logHook(result)
+		if result.Err != nil {
+			return fmt.Errorf("aborting, %s", result)
+		}
+		report.AddHook(result)
+	}
+
+	events := make(chan wiper.Event)
+	done := make(chan struct{})
+	go func() {
+		for event := range events {
+			logEvent(event)
+			report.Add(event)
+		}
+		close(done)
+	}()
+	w.WipeCandidates(selected, events)
+	<-done
+
+	afterHook := w.RunHook(wiper.HookAfter)
+	if afterHook != nil {
+		logHook(afterHook)
+		report.AddHook(afterHook)
+	}
+
+	run := w.NewHistoryRun(started, report)
+	saveHistory(&run)
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Wiped %d files and %d directories, %s.\n", w.WipedFiles, w.WipedDirs, wiper.FormatSize(w.WipedBytes))
+	printHooks(out, report)
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d errors occurred during wiping files", len(report.Errors))
+	}
+	if afterHook != nil && afterHook.Err != nil {
+		return fmt.Errorf("%s", afterHook)
+	}
+	return nil
+}
+
+// reviewNode is a line of the review tree: a rule, a directory of matched
+// entries, a matched entry or something inside a matched directory.
+type reviewNode struct {
+	label     string
+	path      string
+	isDir     bool
+	size      int64
+	candidate *wiper.Candidate
+	selected  bool
+	expanded  bool
+	loaded    bool
+	depth     int
+	children  []*reviewNode
+	parent    *reviewNode
+}
+
+// candidates returns the matched entries at or below n.
+func (n *reviewNode) candidates() []*reviewNode {
+	if n.candidate != nil {
+		return []*reviewNode{n}
+	}
+	found := []*reviewNode{}
+	for _, child := range n.children {
+		found = append(found, child.candidates()...)
+	}
+	return found
+}
+
+// expandable reports whether n has or may have children.
+func (n *reviewNode) expandable() bool {
+	return len(n.children) > 0 || (n.isDir && !n.loaded)
+}
+
+// reviewModel is the state of wiper review, independent of the terminal.
+type reviewModel struct {
+	w       *wiper.Wiper
+	roots   []*reviewNode
+	cursor  int
+	offset  int
+	preview []string
+	confirm bool
+}
+
+// newReviewModel groups candidates by rule and by their directory relative to
+// base_dir. All candidates start selected.
+func newReviewModel(w *wiper.Wiper, candidates []wiper.Candidate) *reviewModel {
+	m := &reviewModel{w: w}
+	rules := map[string]*reviewNode{}
+	dirs := map[string]*reviewNode{}
+	for i := range candidates {
+		c := &candidates[i]
+		rule, ok := rules[c.Rule]
+		if !ok {
+			rule = &reviewNode{label: c.Rule, expanded: true}
+			rules[c.Rule] = rule
+			m.roots = append(m.roots, rule)
+		}
+		dirPath := filepath.Dir(c.Path)
+		dir, ok := dirs[c.Rule+"\x00"+dirPath]
+		if !ok {
+			label, err := filepath.Rel(w.BaseDir, dirPath)
+			if err != nil || strings.HasPrefix(label, "..") {
+				label = dirPath
+			}
+			dir = &reviewNode{label: label + string(filepath.Separator), path: dirPath, depth: 1, parent: rule, expanded: true}
+			dirs[c.Rule+"\x00"+dirPath] = dir
+			rule.children = append(rule.children, dir)
+		}
+		dir.children = append(dir.children, &reviewNode{
+			label:     filepath.Base(c.Path),
+			path:      c.Path,
+			isDir:     c.IsDir,
+			size:      c.Size,
+			candidate: c,
+			selected:  true,
+			depth:     2,
+			parent:    dir,
+		})
+	}
+	slices.SortFunc(m.roots, func(a, b *reviewNode) int { return strings.Compare(a.label, b.label) })
+	for _, rule := range m.roots {
+		slices.SortFunc(rule.children, func(a, b *reviewNode) int { return strings.Compare(a.label, b.label) })
+		for _, dir := range rule.children {
+			for _, item := range dir.children {
+				dir.size += item.size
+			}
+			rule.size += dir.size
+		}
+	}
+	return m
+}
+
+// visible returns the nodes of expanded parents in display order.
+func (m *reviewModel) visible() []*reviewNode {
+	nodes := []*reviewNode{}
+	var add func([]*reviewNode)
+	add = func(children []*reviewNode) {
+		for _, node := range children {
+			nodes = append(nodes, node)
+			if node.expanded {
+				add(node.children)
+			}
+		}
+	}
+	add(m.roots)
+	return nodes
+}
+
+// selected returns the selected candidates.
+func (m *reviewModel) selected() []wiper.Candidate {
+	selected := []wiper.Candidate{}
+	for _, root := range m.roots {
+		for _, node := range root.candidates() {
+			if node.selected {
+				selected = append(selected, *node.candidate)
+			}
+		}
+	}
+	return selected
+}
+
+// handleKey applies a key press and reports whether the review is done and
+// whether the selected entries should be wiped.
+func (m *reviewModel) handleKey(key string) (quit, apply bool) {
+	if m.confirm {
+		m.confirm = false
+		return key == "y" || key == "Y", key == "y" || key == "Y"
+	}
+	nodes := m.visible()
+	node := nodes[m.cursor]
+	m.preview = nil
+	switch key {
+	case "q", "Q", "\x1b", "\x03":
+		return true, false
+	case "j", "\x1b[B", "\x1bOB":
+		m.cursor = min(m.cursor+1, len(nodes)-1)
+	case "k", "\x1b[A", "\x1bOA":
+		m.cursor = max(m.cursor-1, 0)
+	case "\x1b[6~":
+		m.cursor = min(m.cursor+10, len(nodes)-1)
+	case "\x1b[5~":
+		m.cursor = max(m.cursor-10, 0)
+	case " ":
+		m.toggle(node)
+	case "a":
+		m.toggleAll()
+	case "l", "\r", "\x1b[C", "\x1bOC":
+		m.expand(node, !node.expanded || key != "\r")
+	case "h", "\x1b[D", "\x1bOD":
+		if node.expanded && node.expandable() {
+			node.expanded = false
+		} else if node.parent != nil {
+			m.cursor = slices.Index(nodes, node.parent)
+		}
+	case "p":
+		m.preview = m.previewFile(node)
+	case "w":
+		if len(m.selected()) > 0 {
+			m.confirm = true
+		}
+	}
+	return false, false
+}
+
+// toggle selects the candidates at or below node, or deselects them if all
+// are selected. Entries inside a matched directory belong to it and are
+// toggled with it.
+func (m *reviewModel) toggle(node *reviewNode) {
+	for node.candidate == nil && node.parent != nil && node.parent.depth >= 2 {
+		node = node.parent
+	}
+	candidates := node.candidates()
+	selected := !allSelected(candidates)
+	for _, candidate := range candidates {
+		candidate.selected = selected
+	}
+}
+
+func (m *reviewModel) toggleAll() {
+	candidates := []*reviewNode{}
+	for _, root := range m.roots {
+		candidates = append(candidates, root.candidates()...)
+	}
+	selected := !allSelected(candidates)
+	for _, candidate := range candidates {
+		candidate.selected = selected
+	}
+}
+
+func allSelected(candidates []*reviewNode) bool {
+	for _, candidate := range candidates {
+		if !candidate.selected {
+			return false
+		}
+	}
+	return true
+}
+
+// expand shows or hides the children of node, reading the content of
+// directories when they are expanded the first time.
+func (m *reviewModel) expand(node *reviewNode, expanded bool) {
+	if node.isDir && !node.loaded {
+		node.loaded = true
+		entries, err := m.w.FileSystem().ReadDir(node.path)
+		if err != nil {
+			node.children = []*reviewNode{{label: "error: " + err.Error(), depth: node.depth + 1, parent: node}}
+		}
+		for _, entry := range entries {
+			child := &reviewNode{
+				label:  entry.Name(),
+				path:   filepath.Join(node.path, entry.Name()),
+				isDir:  entry.IsDir(),
+				depth:  node.depth + 1,
+				parent: node,
+			}
+			if info, err := entry.Info(); err == nil && !entry.IsDir() {
+				child.size = info.Size()
+			}
+			node.children = append(node.children, child)
+		}
+	}
+	node.expanded = expanded && len(node.children) > 0
+}
+
+// previewFile returns the first lines of the file of node.
+func (m *reviewModel) previewFile(node *reviewNode) []string {
+	if node.path == "" || node.isDir {
+		return []string{"Only files can be previewed."}
+	}
+	file, err := m.w.FileSystem().Open(node.path)
+	if err != nil {
+		return []string{"error: " + err.Error()}
+	}
+	head, err := io.ReadAll(io.LimitReader(file, previewBytes))
+	if err = errors.Join(err, file.Close()); err != nil {
+		return []string{"error: " + err.Error()}
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return []string{fmt.Sprintf("Binary file, %s.", wiper.FormatSize(node.size))}
+	}
+	lines := strings.Split(strings.TrimRight(string(head), "\n"), "\n")
+	if len(lines) > previewLines {
+		lines = lines[:previewLines]
+	}
+	for i, line := range lines {
+		lines[i] = strings.Map(func(r rune) rune {
+			if r == '\t' {
+				return ' '
+			}
+			if unicode.IsControl(r) {
+				return '?'
+			}
+			return r
+		}, line)
+	}
+	return lines
+}
+
+// render returns the lines of the screen.
+func (m *reviewModel) render(width, height int) []string {
+	nodes := m.visible()
+	selected := m.selected()
+	var selectedSize int64
+	for _, candidate := range selected {
+		selectedSize += candidate.Size
+	}
+	total := 0
+	for _, root := range m.roots {
+		total += len(root.candidates())
+	}
+
+	lines := []string{fmt.Sprintf("wiper review: %d of %d entries selected, %s", len(selected), total, wiper.FormatSize(selectedSize))}
+	footer := "up/down move  space select  right/left expand/collapse  a all  p preview  w wipe  q quit"
+	if m.confirm {
+		footer = fmt.Sprintf("Wipe %d entries (%s)? [y/N]", len(selected), wiper.FormatSize(selectedSize))
+	}
+	preview := []string{}
+	if len(m.preview) > 0 {
+		preview = append([]string{strings.Repeat("─", max(width, 1))}, m.preview...)
+	}
+
+	rows := max(height-2-len(preview), 1)
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+	for i := m.offset; i < len(nodes) && i < m.offset+rows; i++ {
+		lines = append(lines, m.renderNode(nodes[i], i == m.cursor, width))
+	}
+	for len(lines) < rows+1 {
+		lines = append(lines, "")
+	}
+	for _, line := range preview {
+		lines = append(lines, cut(line, width))
+	}
+	return append(lines, cut(footer, width))
+}
+
+func (m *reviewModel) renderNode(node *reviewNode, current bool, width int) string {
+	mark := "   "
+	switch candidates := node.candidates(); {
+	case node.candidate == nil && node.depth >= 3:
+	case allSelected(candidates):
+		mark = "[x]"
+	case slices.ContainsFunc(candidates, func(n *reviewNode) bool { return n.selected }):
+		mark = "[-]"
+	default:
+		mark = "[ ]"
+	}
+	arrow := " "
+	if node.expandable() {
+		arrow = "▸"
+		if node.expanded {
+			arrow = "▾"
+		}
+	}
+	label := node.label
+	if node.isDir && node.depth >= 2 {
+		label += string(filepath.Separator)
+	}
+	if node.depth == 0 {
+		label = fmt.Sprintf("%s (%d)", label, len(node.candidates()))
+	}
+	line := fmt.Sprintf("%s%s %s %s", strings.Repeat("  ", node.depth), mark, arrow, label)
+	size := wiper.FormatSize(node.size)
+	if pad := width - len([]rune(line)) - len(size) - 1; pad > 0 {
+		line += strings.Repeat(" ", pad) + size
+	}
+	line = cut(line, width)
+	if current {
+		return "\033[7m" + line + "\033[0m"
+	}
+	return line
+}
+
+// cut shortens s to width runes.
+func cut(s string, width int) string {
+	if runes := []rune(s); width > 0 && len(runes) > width {
+		return string(runes[:width])
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+}
@@ -0,0 +1,181 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	wiper "github.com/steffakasid/wiper/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReview(t *testing.T) {
+	newModel := func(t *testing.T) (*reviewModel, *wiper.MemFileSystem) {
+		t.Helper()
+		fsys := wiper.NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base/app/node_modules/pkg", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/app/node_modules/pkg/index.js", make([]byte, 100), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/app/node_modules/README", []byte("line 1\n\tline 2\x07\n"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/a.orig", []byte("orig\n"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/app/b.orig", []byte{0, 1, 2}, 0o644))
+		w := &wiper.Wiper{WipeOutPattern: []string{`\.orig$`}, WipeOutDirs: []string{"node_modules"}, BaseDir: "/base", FS: fsys, DryRun: true}
+		events := make(chan wiper.Event)
+		go w.WipeFiles(nil, "", events)
+		for range events {
+		}
+		w.DryRun = false
+		return newReviewModel(w, w.Candidates()), fsys
+	}
+
+	labels := func(m *reviewModel) []string {
+		lines := []string{}
+		for _, node := range m.visible() {
+			lines = append(lines, strings.Repeat("  ", node.depth)+node.label)
+		}
+		return lines
+	}
+
+	t.Run("green case - candidates are grouped by rule and directory", func(t *testing.T) {
+		sut, _ := newModel(t)
+		assert.Equal(t, []string{
+			"wipe_out_dirs",
+			"  app/",
+			"    node_modules",
+			"wipe_out_pattern",
+			"  ./",
+			"    a.orig",
+			"  app/",
+			"    b.orig",
+		}, labels(sut))
+		assert.Len(t, sut.selected(), 3)
+
+		screen := sut.render(60, 12)
+		require.Len(t, screen, 12)
+		assert.Equal(t, "wiper review: 3 of 3 entries selected, 124 B", screen[0])
+		assert.Equal(t, "\033[7m[x] ▾ wipe_out_dirs (1)                               116 B\033[0m", screen[1])
+		assert.Equal(t, "    [x] ▸ node_modules/                               116 B", screen[3])
+	})
+
+	t.Run("selecting, expanding and previewing", func(t *testing.T) {
+		sut, _ := newModel(t)
+		press := func(keys ...string) {
+			for _, key := range keys {
+				quit, _ := sut.handleKey(key)
+				require.False(t, quit)
+			}
+		}
+
+		// deselect b.orig, which makes its rule partially selected
+		press("k", "\x1b[B", "j", "j", "j", "j", "j", "j", " ")
+		assert.Len(t, sut.selected(), 2)
+		assert.Contains(t, sut.render(80, 20)[4], "[-] ▾ wipe_out_pattern")
+
+		// the rule toggles all of its candidates
+		press("\x1b[A", "\x1b[A", "\x1b[A", "\x1b[A", " ")
+		assert.Len(t, sut.selected(), 3)
+		press(" ")
+		assert.Len(t, sut.selected(), 1)
+		press("a")
+		assert.Len(t, sut.selected(), 3)
+
+		// expand node_modules and preview a file inside it
+		press("k", "l")
+		assert.Equal(t, []string{"      README", "      pkg"}, labels(sut)[3:5])
+		press("j", "p")
+		assert.Equal(t, []string{"line 1", " line 2?"}, sut.preview)
+		screen := sut.render(40, 12)
+		assert.Equal(t, " line 2?", screen[len(screen)-2])
+
+		// entries inside a matched directory toggle the directory
+		press(" ")
+		assert.Len(t, sut.selected(), 2)
+		press("h", "h")
+		assert.Equal(t, "node_modules", sut.visible()[sut.cursor].label)
+		assert.False(t, sut.visible()[sut.cursor].expanded)
+		press(" ")
+
+		press("j", "j", "j", "j", "j", "p")
+		assert.Equal(t, []string{"Binary file, 3 B."}, sut.preview)
+	})
+
+	t.Run("green case - wipe after confirmation", func(t *testing.T) {
+		sut, fsys := newModel(t)
+		out := &bytes.Buffer{}
+		keys := []string{"j", "j", " ", "w", "n", "w", "y"}
+		apply, err := runReview(sut, &keyReader{keys: keys}, out, func() (int, int) { return 80, 24 })
+		require.NoError(t, err)
+		assert.True(t, apply)
+		assert.Contains(t, out.String(), "Wipe 2 entries (8 B)? [y/N]")
+
+		cmd := &cobra.Command{}
+		cmd.SetOut(out)
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		require.NoError(t, wipeReviewed(cmd, sut.w, sut.selected()))
+		_, err = fsys.Stat("/base/a.orig")
+		assert.Error(t, err)
+		_, err = fsys.Stat("/base/app/node_modules")
+		assert.NoError(t, err)
+		assert.Contains(t, out.String(), "Wiped 2 files and 0 directories, 8 B.")
+	})
+
+	t.Run("red case - failing before hook aborts the wipe", func(t *testing.T) {
+		sut, fsys := newModel(t)
+		sut.w.Hooks = wiper.Hooks{Before: []string{"false"}, After: []string{"true"}}
+
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		err := wipeReviewed(&cobra.Command{}, sut.w, sut.selected())
+		assert.ErrorContains(t, err, "aborting")
+		_, err = fsys.Stat("/base/a.orig")
+		assert.NoError(t, err)
+	})
+
+	t.Run("red case - failing after hook fails the wipe", func(t *testing.T) {
+		sut, fsys := newModel(t)
+		sut.w.Hooks = wiper.Hooks{Before: []string{"true"}, After: []string{"false"}}
+
+		out := &bytes.Buffer{}
+		cmd := &cobra.Command{}
+		cmd.SetOut(out)
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		err := wipeReviewed(cmd, sut.w, sut.selected())
+		assert.ErrorContains(t, err, "after hook")
+		_, err = fsys.Stat("/base/a.orig")
+		assert.Error(t, err)
+		assert.Contains(t, out.String(), "Ran 2 hooks, 1 failed.\n  after hook \"false\" failed: exit status 1\n")
+	})
+
+	t.Run("quitting wipes nothing", func(t *testing.T) {
+		sut, _ := newModel(t)
+		apply, err := runReview(sut, &keyReader{keys: []string{"j", "q"}}, &bytes.Buffer{}, func() (int, int) { return 80, 24 })
+		require.NoError(t, err)
+		assert.False(t, apply)
+
+		apply, err = runReview(sut, &keyReader{}, &bytes.Buffer{}, func() (int, int) { return 80, 24 })
+		require.NoError(t, err)
+		assert.False(t, apply)
+	})
+
+	t.Run("red case - review needs a terminal", func(t *testing.T) {
+		assert.ErrorContains(t, RunReviewE(&cobra.Command{}, []string{}), "needs a terminal")
+	})
+}
+
+// keyReader returns one key per Read, like a terminal in raw mode.
+type keyReader struct {
+	keys []string
+}
+
+func (k *keyReader) Read(b []byte) (int, error) {
+	if len(k.keys) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, k.keys[0])
+	k.keys = k.keys[1:]
+	return n, nil
+}
@@ -4,9 +4,12 @@ Copyright © 2024 steffakasid
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -22,10 +25,20 @@ const (
 	excludeFileFlag    = "exclude_file"
 	baseDirFlag        = "base_dir"
 	useTrashFlag       = "use_trash"
+	actionFlag         = "action"
+	respectGitFlag     = "respect_git"
+	onlyOwnedFlag      = "only_owned_by_current_user"
 	configFlag         = "config"
 	debugFlag          = "debug"
+	waitFlag           = "wait"
+	metricsFileFlag    = "metrics-file"
+	noProgressFlag     = "no-progress"
 )
 
+// tracingShutdownTimeout limits how long wiper waits for the trace exporter
+// before it exits.
+const tracingShutdownTimeout = 5 * time.Second
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "wiper",
@@ -34,8 +47,7 @@ var rootCmd = &cobra.Command{
 	RunE:  RunWiperE,
 }
 
-func RunWiperE(cmd *cobra.Command, args []string) error {
-
+func setupLogging() {
 	if viper.GetBool(debugFlag) {
 		err := eslog.Logger.SetLogLevel("debug")
 		eslog.LogIfError(err, eslog.Error)
@@ -45,40 +57,207 @@ func RunWiperE(cmd *cobra.Command, args []string) error {
 		eslog.LogIfError(err, eslog.Error)
 		eslog.Info("Debugging disabled.")
 	}
+}
+
+func RunWiperE(cmd *cobra.Command, args []string) error {
+
+	setupLogging()
 
 	if err := wiper.RefreshInstanceFromViper(); err != nil {
 		return err
 	}
 
-	wiper := wiper.GetInstance()
-	if wiper.UseTrash {
+	w := wiper.GetInstance()
+	if w.UseTrash {
 		eslog.Info("use_trash enabled; deleted items will be moved to the user's Trash.")
 	}
-	errChan := make(chan error)
-	errResult := make(chan bool, 1)
+	switch w.Action {
+	case wiper.ActionArchive:
+		eslog.Info("archive action enabled; deleted items will be appended to an archive before removal.")
+	case wiper.ActionQuarantine:
+		eslog.Infof("quarantine action enabled; deleted items will be moved to %s.", w.QuarantineDir)
+	case wiper.ActionShred:
+		eslog.Info("shred action enabled; deleted files will be overwritten before removal.")
+	}
+	lock, err := lockRun(cmd, w)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		eslog.LogIfError(lock.Release(), eslog.Warn)
+	}()
+
+	started := time.Now()
+	report := &wiper.Report{}
+	if result := w.RunHook(wiper.HookBefore); result != nil {
+		logHook(result)
+		if result.Err != nil {
+			return fmt.Errorf("aborting, %s", result)
+		}
+		report.AddHook(result)
+	}
+
+	events := make(chan wiper.Event)
+	done := make(chan struct{})
 	go func() {
-		var errorsOccurred bool
-		for err := range errChan {
-			eslog.Error(err)
-			errorsOccurred = true
+		for event := range events {
+			logEvent(event)
+			report.Add(event)
 		}
-		errResult <- errorsOccurred
+		close(done)
 	}()
 
-	wiper.WipeFiles(nil, "", errChan)
-	errorsOccurred := <-errResult
-	if errorsOccurred {
-		return errors.New("errors occurred during wiping files")
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	noProgress, _ := cmd.Flags().GetBool(noProgressFlag)
+	progress := startProgress(noProgress, w)
+	w.WipeFilesContext(ctx, nil, "", events)
+	<-done
+	progress.Stop()
+
+	afterHook := w.RunHook(wiper.HookAfter)
+	if afterHook != nil {
+		logHook(afterHook)
+		report.AddHook(afterHook)
+	}
+
+	purged := []wiper.TrashItem{}
+	if w.TrashRetention.Enabled() {
+		var err error
+		purged, err = w.Trash().ApplyRetention(w.TrashRetention)
+		if err != nil {
+			eslog.Errorf("Applying trash_retention failed: %s", err)
+			report.Add(wiper.Event{Type: wiper.EventError, Path: wiper.TrashDir(), Op: "purge", Err: err})
+		}
+		if len(purged) > 0 {
+			eslog.Infof("Deleted %d items from the trash according to trash_retention.", len(purged))
+		}
+	}
+
+	run := w.NewHistoryRun(started, report)
+	run.AddPurged(w.Trash(), purged, "trash_retention")
+	saveHistory(&run)
+
+	if metricsFile, _ := cmd.Flags().GetString(metricsFileFlag); metricsFile != "" {
+		metrics := wiper.Metrics{
+			Profile: wiper.ProfileName(viper.ConfigFileUsed()),
+			Run:     run,
+			Errors:  report.ErrorsByOp(),
+			Success: len(report.Errors) == 0 && (afterHook == nil || afterHook.Err == nil),
+		}
+		if err := metrics.WriteFile(metricsFile); err != nil {
+			eslog.Errorf("Writing the metrics to %s failed: %s", metricsFile, err)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	if len(report.Errors) > 0 {
+		printHooks(out, report)
+		return fmt.Errorf("%d errors occurred during wiping files", len(report.Errors))
+	}
+	fmt.Fprintf(out, "Inspected %d files and wiped %d files.\n", w.InspectedFiles, w.WipedFiles)
+	fmt.Fprintf(out, "Inspected %d directories and wiped %d directories.\n", w.InspectedDirs, w.WipedDirs)
+	if len(report.Shredded) > 0 {
+		warnings := report.ShredWarnings()
+		fmt.Fprintf(out, "Shredded %d items, %d may still be recoverable.\n", len(report.Shredded), len(warnings))
+		for _, event := range warnings {
+			fmt.Fprintf(out, "  %s: %s\n", event.Path, event.Reason)
+		}
+	}
+	printHooks(out, report)
+	if afterHook != nil && afterHook.Err != nil {
+		return fmt.Errorf("%s", afterHook)
 	}
-	fmt.Printf("Inspected %d files and wiped %d files.\n", wiper.InspectedFiles, wiper.WipedFiles)
-	fmt.Printf("Inspected %d directories and wiped %d directories.\n", wiper.InspectedDirs, wiper.WipedDirs)
 	return nil
 }
 
+// printHooks summarizes the hooks of the run, listing the failed ones.
+func printHooks(out io.Writer, report *wiper.Report) {
+	if len(report.Hooks) == 0 {
+		return
+	}
+	failed := report.FailedHooks()
+	fmt.Fprintf(out, "Ran %d hooks, %d failed.\n", len(report.Hooks), len(failed))
+	for _, result := range failed {
+		fmt.Fprintf(out, "  %s\n", result)
+	}
+}
+
+// lockRun acquires the lock of base_dir, so a scheduled run and a manual one
+// never work on the same tree at the same time.
+func lockRun(cmd *cobra.Command, w *wiper.Wiper) (*wiper.RunLock, error) {
+	var wait time.Duration
+	if value, _ := cmd.Flags().GetString(waitFlag); value != "" {
+		var err error
+		if wait, err = wiper.ParseAge(value); err != nil {
+			return nil, fmt.Errorf("--wait: %w", err)
+		}
+	}
+	lock, err := w.Lock(wait)
+	var locked *wiper.LockedError
+	if errors.As(err, &locked) {
+		return nil, fmt.Errorf("%w, use --wait to wait for it", err)
+	}
+	return lock, err
+}
+
+// saveHistory records run in the history. A run which can't be recorded
+// still succeeded, so failures are only logged.
+func saveHistory(run *wiper.HistoryRun) {
+	if err := wiper.SaveHistoryRun(run); err != nil {
+		eslog.Warnf("Recording the run in the history failed: %s", err)
+	} else {
+		eslog.Debugf("Recorded the run as %s, see wiper history show %s.", run.ID, run.ID)
+	}
+}
+
+func logEvent(event wiper.Event) {
+	switch event.Type {
+	case wiper.EventError:
+		eslog.Error(event.String())
+	case wiper.EventWiped, wiper.EventTrashed, wiper.EventArchived, wiper.EventQuarantined:
+		eslog.Info(event.String())
+	case wiper.EventShredded:
+		if event.Reason != "" {
+			eslog.Warn(event.String())
+		} else {
+			eslog.Info(event.String())
+		}
+	case wiper.EventHook:
+		logHook(event.Hook)
+	default:
+		eslog.Debug(event.String())
+	}
+}
+
+func logHook(result *wiper.HookResult) {
+	if result.Output != "" {
+		eslog.Debugf("%s hook output: %s", result.Stage, result.Output)
+	}
+	if result.Err != nil {
+		eslog.Warn(result.String())
+	} else {
+		eslog.Debug(result.String())
+	}
+}
+
 func Execute(version string) {
 	rootCmd.Version = version
-	err := rootCmd.Execute()
+	shutdownTracing, err := wiper.SetupTracing(context.Background(), version)
 	if err != nil {
+		eslog.Warnf("Tracing disabled: %s", err)
+	}
+	err = rootCmd.Execute()
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	if shutdownErr := shutdownTracing(ctx); shutdownErr != nil {
+		eslog.Warnf("Exporting the traces failed: %s", shutdownErr)
+	}
+	if err != nil {
+		cancel()
 		os.Exit(1)
 	}
 }
@@ -98,8 +277,15 @@ func init() {
 	peristentFlags.StringArrayP(wipeOutFlag, "w", []string{}, "String array of files to be wiped.")
 	peristentFlags.StringArrayP(wipeOutPatternFlag, "p", []string{}, "String array of patterns for files to be wiped.")
 	peristentFlags.BoolP(useTrashFlag, "t", false, "Enable using trash folder ($HOME/.Trash). If folder does not exist already, it will be created. [default: false]")
+	peristentFlags.StringP(actionFlag, "a", "", "Action applied to matched items: remove, trash, archive, quarantine or shred. [default: remove, or trash if use_trash is set]")
+	peristentFlags.Bool(respectGitFlag, false, "Never wipe files tracked by git. [default: false]")
+	peristentFlags.Bool(onlyOwnedFlag, false, "Only wipe files owned by the user running wiper. [default: false]")
 	peristentFlags.BoolP(debugFlag, "d", false, "Enable debugging.")
 	peristentFlags.StringVar(&wiper.CfgFile, configFlag, "", "Config file to use insted default: $HOME/.config/wiper/config")
 
 	cobra.CheckErr(viper.BindPFlags(peristentFlags))
+
+	rootCmd.Flags().Bool(noProgressFlag, false, "Don't show the progress on stderr, which is shown if it is a terminal and --debug is not set.")
+	rootCmd.Flags().String(metricsFileFlag, "", "Write the metrics of the run to this file for the node_exporter textfile collector, e.g. /var/lib/node_exporter/textfile/wiper.prom.")
+	rootCmd.Flags().String(waitFlag, "", "How long to wait for another wiper run on the same base_dir to finish, e.g. 10m. [default: fail immediately]")
 }
@@ -4,6 +4,7 @@ Copyright © 2024 steffakasid
 package cmd
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"testing"
@@ -46,6 +47,7 @@ func TestRunWiperE(t *testing.T) {
 
 	t.Run("red case - error when file deletion fails", func(t *testing.T) {
 		testDir := t.TempDir()
+		t.Setenv("HOME", t.TempDir())
 		readOnlyDir := filepath.Join(testDir, "readonly")
 		require.NoError(t, os.Mkdir(readOnlyDir, 0o555))
 		t.Cleanup(func() {
@@ -155,6 +157,108 @@ func TestRunWiperE(t *testing.T) {
 		assert.FileExists(t, trashPath)
 	})
 
+	t.Run("red case - failing before hook aborts the run", func(t *testing.T) {
+		testDir := t.TempDir()
+		testHome := t.TempDir()
+		t.Setenv("HOME", testHome)
+
+		fileToKeep, err := os.CreateTemp(testDir, "todelete")
+		require.NoError(t, err)
+		require.NoError(t, fileToKeep.Close())
+
+		wiper.CfgFile = ""
+		viper.Reset()
+		wiper.InitConfig()
+
+		viper.Set(baseDirFlag, testDir)
+		viper.Set(wipeOutFlag, []string{filepath.Base(fileToKeep.Name())})
+		viper.Set("hooks.before", []string{"false"})
+
+		cmd := &cobra.Command{}
+		err = RunWiperE(cmd, []string{})
+
+		assert.Error(t, err)
+		assert.FileExists(t, fileToKeep.Name())
+	})
+
+	t.Run("red case - failed hooks are reported when the run fails", func(t *testing.T) {
+		testHome := t.TempDir()
+		t.Setenv("HOME", testHome)
+
+		wiper.CfgFile = ""
+		viper.Reset()
+		wiper.InitConfig()
+
+		viper.Set(baseDirFlag, filepath.Join(testHome, "missing"))
+		viper.Set("hooks.after", []string{"false"})
+
+		out := &bytes.Buffer{}
+		cmd := &cobra.Command{}
+		cmd.SetOut(out)
+		err := RunWiperE(cmd, []string{})
+
+		assert.ErrorContains(t, err, "1 errors occurred")
+		assert.Contains(t, out.String(), "Ran 1 hooks, 1 failed.\n  after hook \"false\" failed: exit status 1\n")
+	})
+
+	t.Run("red case - base_dir is locked by another run", func(t *testing.T) {
+		testDir := t.TempDir()
+		testHome := t.TempDir()
+		t.Setenv("HOME", testHome)
+		t.Setenv("XDG_STATE_HOME", "")
+
+		fileToKeep, err := os.CreateTemp(testDir, "todelete")
+		require.NoError(t, err)
+		require.NoError(t, fileToKeep.Close())
+
+		wiper.CfgFile = ""
+		viper.Reset()
+		wiper.InitConfig()
+
+		viper.Set(baseDirFlag, testDir)
+		viper.Set(wipeOutFlag, []string{filepath.Base(fileToKeep.Name())})
+
+		lock, err := (&wiper.Wiper{BaseDir: testDir}).Lock(0)
+		require.NoError(t, err)
+		t.Cleanup(func() { assert.NoError(t, lock.Release()) })
+
+		cmd := &cobra.Command{}
+		cmd.Flags().String(waitFlag, "", "")
+		require.NoError(t, cmd.Flags().Set(waitFlag, "300ms"))
+		err = RunWiperE(cmd, []string{})
+
+		assert.ErrorContains(t, err, "use --wait")
+		assert.FileExists(t, fileToKeep.Name())
+		assert.DirExists(t, filepath.Join(testHome, ".local", "state", "wiper", "locks"))
+	})
+
+	t.Run("green case - metrics file is written", func(t *testing.T) {
+		testDir := t.TempDir()
+		t.Setenv("HOME", t.TempDir())
+
+		fileToDelete, err := os.CreateTemp(testDir, "todelete")
+		require.NoError(t, err)
+		require.NoError(t, fileToDelete.Close())
+
+		wiper.CfgFile = ""
+		viper.Reset()
+		wiper.InitConfig()
+
+		viper.Set(baseDirFlag, testDir)
+		viper.Set(wipeOutFlag, []string{filepath.Base(fileToDelete.Name())})
+
+		metricsFile := filepath.Join(t.TempDir(), "wiper.prom")
+		cmd := &cobra.Command{}
+		cmd.Flags().String(metricsFileFlag, "", "")
+		require.NoError(t, cmd.Flags().Set(metricsFileFlag, metricsFile))
+		require.NoError(t, RunWiperE(cmd, []string{}))
+
+		content, err := os.ReadFile(metricsFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), `wiper_wiped_files{profile="default",base_dir="`+testDir+`"} 1`)
+		assert.Contains(t, string(content), `wiper_last_run_success{profile="default",base_dir="`+testDir+`"} 1`)
+	})
+
 	t.Run("multiple exclude patterns", func(t *testing.T) {
 		testDir := t.TempDir()
 		testHome := t.TempDir()
@@ -194,6 +298,7 @@ func TestRootCmdInit(t *testing.T) {
 		assert.NotNil(t, flags.Lookup(wipeOutFlag))
 		assert.NotNil(t, flags.Lookup(wipeOutPatternFlag))
 		assert.NotNil(t, flags.Lookup(useTrashFlag))
+		assert.NotNil(t, flags.Lookup(actionFlag))
 		assert.NotNil(t, flags.Lookup(debugFlag))
 		assert.NotNil(t, flags.Lookup(configFlag))
 	})
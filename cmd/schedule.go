@@ -0,0 +1,299 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	wiper "github.com/steffakasid/wiper/internal"
+)
+
+// Constants used in schedule command flags
+const (
+	everyFlag   = "every"
+	profileFlag = "profile"
+	cronFlag    = "cron"
+)
+
+// runCommand runs an external command with stdin and returns its combined
+// output. Tests replace it to avoid touching the real systemd and crontab.
+var runCommand = func(stdin, name string, args ...string) (string, error) {
+	command := exec.Command(name, args...)
+	command.Stdin = strings.NewReader(stdin)
+	output, err := command.CombinedOutput()
+	return string(output), err
+}
+
+// lookPath finds external commands, tests replace it as well.
+var lookPath = exec.LookPath
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Run wiper periodically with systemd user units or cron.",
+	Long: `Install, list and remove periodic wiper runs. On Linux with systemd a user
+service and timer named wiper-<profile> are installed, otherwise a crontab line.
+A profile is a config file next to the default one, e.g.
+~/.config/wiper/projects.yaml for the profile projects.`,
+}
+
+var scheduleInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install or update a periodic run of a profile.",
+	Example: `  wiper schedule install --every 1d
+  wiper schedule install --every 6h --profile projects
+  wiper schedule install --every 30m --profile downloads --cron`,
+	Args: cobra.NoArgs,
+	RunE: RunScheduleInstallE,
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the installed periodic runs.",
+	Args:  cobra.NoArgs,
+	RunE:  RunScheduleListE,
+}
+
+var scheduleRemoveCmd = &cobra.Command{
+	Use:   "remove <profile>",
+	Short: "Remove the periodic run of a profile.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  RunScheduleRemoveE,
+}
+
+func RunScheduleInstallE(cmd *cobra.Command, args []string) error {
+	setupLogging()
+	schedule, err := newSchedule(cmd)
+	if err != nil {
+		return err
+	}
+
+	if cron, _ := cmd.Flags().GetBool(cronFlag); cron || !hasSystemd() {
+		return installCron(cmd, schedule)
+	}
+	unitDir := systemdUnitDir()
+	if err := os.MkdirAll(unitDir, 0o755); err != nil {
+		return err
+	}
+	units := map[string]string{
+		schedule.UnitName() + ".service": schedule.ServiceUnit(),
+		schedule.UnitName() + ".timer":   schedule.TimerUnit(),
+	}
+	for name, content := range units {
+		if err := os.WriteFile(filepath.Join(unitDir, name), []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if err := systemctl("enable", "--now", schedule.UnitName()+".timer"); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Installed %s.timer running %s every %s.\n", schedule.UnitName(), schedule.ConfigFile, schedule.Every)
+	return nil
+}
+
+// newSchedule builds the schedule from the flags. --config takes precedence
+// over --profile and names the schedule after the file unless --profile is
+// given as well.
+func newSchedule(cmd *cobra.Command) (wiper.Schedule, error) {
+	flags := cmd.Flags()
+	everyValue, _ := flags.GetString(everyFlag)
+	every, err := wiper.ParseAge(everyValue)
+	if err != nil {
+		return wiper.Schedule{}, fmt.Errorf("--every: %w", err)
+	}
+	if every <= 0 {
+		return wiper.Schedule{}, fmt.Errorf("--every must be positive")
+	}
+	profile, _ := flags.GetString(profileFlag)
+
+	configFile := wiper.CfgFile
+	if configFile == "" {
+		if configFile, err = wiper.ProfileConfig(profile); err != nil {
+			return wiper.Schedule{}, err
+		}
+	} else if !flags.Changed(profileFlag) {
+		profile = strings.TrimSuffix(filepath.Base(configFile), filepath.Ext(configFile))
+	}
+	if err := wiper.ValidateProfile(profile); err != nil {
+		return wiper.Schedule{}, err
+	}
+	if configFile, err = filepath.Abs(configFile); err != nil {
+		return wiper.Schedule{}, err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return wiper.Schedule{}, err
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	return wiper.Schedule{Name: profile, Every: every, Executable: executable, ConfigFile: configFile}, nil
+}
+
+func installCron(cmd *cobra.Command, schedule wiper.Schedule) error {
+	flock, _ := lookPath("flock")
+	if flock != "" {
+		if err := os.MkdirAll(filepath.Dir(schedule.LockFile()), 0o700); err != nil {
+			return err
+		}
+	}
+	line, err := schedule.CronLine(flock)
+	if err != nil {
+		return err
+	}
+	crontab, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	if err := writeCrontab(schedule.SetCronLine(crontab, line)); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Installed crontab line: %s\n", line)
+	return nil
+}
+
+func RunScheduleListE(cmd *cobra.Command, args []string) error {
+	setupLogging()
+	out := cmd.OutOrStdout()
+	timers, _ := filepath.Glob(filepath.Join(systemdUnitDir(), "wiper-*.timer"))
+	for _, timer := range timers {
+		content, err := os.ReadFile(timer)
+		if err != nil {
+			return err
+		}
+		triggers := []string{}
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.HasPrefix(line, "On") {
+				triggers = append(triggers, line)
+			}
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(timer), "wiper-"), ".timer")
+		fmt.Fprintf(out, "%s\tsystemd\t%s\n", name, strings.Join(triggers, " "))
+	}
+
+	if _, err := lookPath("crontab"); err != nil {
+		return nil
+	}
+	crontab, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	schedules := wiper.CronSchedules(crontab)
+	for _, name := range slices.Sorted(maps.Keys(schedules)) {
+		fmt.Fprintf(out, "%s\tcron\t%s\n", name, schedules[name])
+	}
+	return nil
+}
+
+func RunScheduleRemoveE(cmd *cobra.Command, args []string) error {
+	setupLogging()
+	if err := wiper.ValidateProfile(args[0]); err != nil {
+		return err
+	}
+	schedule := wiper.Schedule{Name: args[0]}
+	removed := false
+
+	timer := filepath.Join(systemdUnitDir(), schedule.UnitName()+".timer")
+	if _, err := os.Stat(timer); err == nil {
+		if err := systemctl("disable", "--now", schedule.UnitName()+".timer"); err != nil {
+			return err
+		}
+		for _, unit := range []string{timer, strings.TrimSuffix(timer, ".timer") + ".service"} {
+			if err := os.Remove(unit); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if err := systemctl("daemon-reload"); err != nil {
+			return err
+		}
+		removed = true
+	}
+
+	if _, err := lookPath("crontab"); err == nil {
+		crontab, err := readCrontab()
+		if err != nil {
+			return err
+		}
+		if updated, found := wiper.RemoveCronLine(crontab, schedule.Name); found {
+			if err := writeCrontab(updated); err != nil {
+				return err
+			}
+			removed = true
+		}
+	}
+
+	if !removed {
+		return fmt.Errorf("no schedule for profile %s installed", schedule.Name)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Removed schedule %s.\n", schedule.Name)
+	return nil
+}
+
+func hasSystemd() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	_, err := lookPath("systemctl")
+	return err == nil
+}
+
+// systemdUnitDir returns $XDG_CONFIG_HOME/systemd/user, falling back to
+// ~/.config/systemd/user.
+func systemdUnitDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "systemd", "user")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "systemd", "user")
+}
+
+func systemctl(args ...string) error {
+	output, err := runCommand("", "systemctl", append([]string{"--user"}, args...)...)
+	if err != nil {
+		return fmt.Errorf("systemctl --user %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// readCrontab returns the crontab of the user, which is empty if there is
+// none yet.
+func readCrontab() (string, error) {
+	output, err := runCommand("", "crontab", "-l")
+	if err != nil {
+		if strings.Contains(output, "no crontab") {
+			return "", nil
+		}
+		return "", fmt.Errorf("crontab -l: %w: %s", err, strings.TrimSpace(output))
+	}
+	return output, nil
+}
+
+func writeCrontab(crontab string) error {
+	if output, err := runCommand(crontab, "crontab", "-"); err != nil {
+		return fmt.Errorf("crontab -: %w: %s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+func init() {
+	flags := scheduleInstallCmd.Flags()
+	flags.String(everyFlag, "1d", "Interval between two runs, e.g. 30m, 6h, 1d or 1w.")
+	flags.String(profileFlag, "default", "Profile to run, i.e. the config file ~/.config/wiper/<profile>.yaml. --config takes precedence.")
+	flags.Bool(cronFlag, false, "Install a crontab line even if systemd is available.")
+
+	scheduleCmd.AddCommand(scheduleInstallCmd, scheduleListCmd, scheduleRemoveCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
@@ -0,0 +1,181 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	wiper "github.com/steffakasid/wiper/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	type fakeSystem struct {
+		commands []string
+		crontab  string
+	}
+
+	setup := func(t *testing.T, tools ...string) (string, *fakeSystem) {
+		t.Helper()
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("XDG_CONFIG_HOME", "")
+		t.Setenv("XDG_STATE_HOME", "")
+		require.NoError(t, os.MkdirAll(filepath.Join(home, ".config", "wiper"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".config", "wiper", "projects.yaml"), nil, 0o644))
+		wiper.CfgFile = ""
+		viper.Reset()
+
+		system := &fakeSystem{}
+		originalRun, originalLookPath := runCommand, lookPath
+		t.Cleanup(func() { runCommand, lookPath = originalRun, originalLookPath })
+		runCommand = func(stdin, name string, args ...string) (string, error) {
+			system.commands = append(system.commands, strings.Join(append([]string{name}, args...), " "))
+			switch {
+			case name == "crontab" && args[0] == "-l":
+				if system.crontab == "" {
+					return "no crontab for user\n", errors.New("exit status 1")
+				}
+				return system.crontab, nil
+			case name == "crontab":
+				system.crontab = stdin
+			}
+			return "", nil
+		}
+		lookPath = func(file string) (string, error) {
+			for _, tool := range tools {
+				if tool == file {
+					return "/usr/bin/" + file, nil
+				}
+			}
+			return "", errors.New("not found")
+		}
+		return home, system
+	}
+
+	newInstallCmd := func(t *testing.T, flags map[string]string) (*cobra.Command, *bytes.Buffer) {
+		t.Helper()
+		out := &bytes.Buffer{}
+		cmd := &cobra.Command{}
+		cmd.SetOut(out)
+		cmd.Flags().AddFlagSet(scheduleInstallCmd.Flags())
+		for name, value := range flags {
+			require.NoError(t, cmd.Flags().Set(name, value))
+			t.Cleanup(func() {
+				flag := cmd.Flags().Lookup(name)
+				require.NoError(t, flag.Value.Set(flag.DefValue))
+				flag.Changed = false
+			})
+		}
+		return cmd, out
+	}
+
+	t.Run("green case - systemd units are installed, listed and removed", func(t *testing.T) {
+		home, system := setup(t, "systemctl", "crontab")
+		if !hasSystemd() {
+			t.Skip("systemd units are only installed on linux")
+		}
+		cmd, out := newInstallCmd(t, map[string]string{everyFlag: "6h", profileFlag: "projects"})
+
+		require.NoError(t, RunScheduleInstallE(cmd, []string{}))
+		unitDir := filepath.Join(home, ".config", "systemd", "user")
+		service, err := os.ReadFile(filepath.Join(unitDir, "wiper-projects.service"))
+		require.NoError(t, err)
+		assert.Contains(t, string(service), "--config "+filepath.Join(home, ".config", "wiper", "projects.yaml"))
+		timer, err := os.ReadFile(filepath.Join(unitDir, "wiper-projects.timer"))
+		require.NoError(t, err)
+		assert.Contains(t, string(timer), "OnUnitActiveSec=6h\n")
+		assert.Equal(t, []string{"systemctl --user daemon-reload", "systemctl --user enable --now wiper-projects.timer"}, system.commands)
+		assert.Contains(t, out.String(), "Installed wiper-projects.timer")
+
+		out.Reset()
+		require.NoError(t, RunScheduleListE(cmd, []string{}))
+		assert.Equal(t, "projects\tsystemd\tOnBootSec=5min OnUnitActiveSec=6h\n", out.String())
+
+		system.commands = nil
+		require.NoError(t, RunScheduleRemoveE(cmd, []string{"projects"}))
+		assert.NoFileExists(t, filepath.Join(unitDir, "wiper-projects.timer"))
+		assert.NoFileExists(t, filepath.Join(unitDir, "wiper-projects.service"))
+		assert.Contains(t, system.commands, "systemctl --user disable --now wiper-projects.timer")
+	})
+
+	t.Run("green case - crontab line as fallback", func(t *testing.T) {
+		home, system := setup(t, "crontab", "flock")
+		system.crontab = "MAILTO=me\n"
+		cmd, out := newInstallCmd(t, map[string]string{everyFlag: "30m", profileFlag: "projects", cronFlag: "true"})
+
+		require.NoError(t, RunScheduleInstallE(cmd, []string{}))
+		assert.Contains(t, system.crontab, "MAILTO=me\n*/30 * * * * /usr/bin/flock -n "+filepath.Join(home, ".local", "state", "wiper", "wiper-projects.lock"))
+		assert.True(t, strings.HasSuffix(system.crontab, "--config "+filepath.Join(home, ".config", "wiper", "projects.yaml")+" # wiper-schedule:projects\n"))
+		assert.DirExists(t, filepath.Join(home, ".local", "state", "wiper"))
+
+		out.Reset()
+		require.NoError(t, RunScheduleListE(cmd, []string{}))
+		assert.True(t, strings.HasPrefix(out.String(), "projects\tcron\t*/30 * * * * "))
+
+		require.NoError(t, RunScheduleRemoveE(cmd, []string{"projects"}))
+		assert.Equal(t, "MAILTO=me\n", system.crontab)
+	})
+
+	t.Run("--config names the schedule after the file", func(t *testing.T) {
+		home, system := setup(t, "crontab")
+		wiper.CfgFile = filepath.Join(home, "work.yaml")
+		t.Cleanup(func() { wiper.CfgFile = "" })
+		cmd, _ := newInstallCmd(t, map[string]string{cronFlag: "true"})
+
+		require.NoError(t, RunScheduleInstallE(cmd, []string{}))
+		assert.Contains(t, system.crontab, "@daily ")
+		assert.Contains(t, system.crontab, "--config "+filepath.Join(home, "work.yaml")+" # wiper-schedule:work\n")
+	})
+
+	t.Run("red case - unknown profile, bad interval and missing schedule", func(t *testing.T) {
+		setup(t, "crontab")
+		cmd, _ := newInstallCmd(t, map[string]string{profileFlag: "missing", cronFlag: "true"})
+		assert.Error(t, RunScheduleInstallE(cmd, []string{}))
+
+		cmd, _ = newInstallCmd(t, map[string]string{profileFlag: "projects", everyFlag: "7m", cronFlag: "true"})
+		assert.Error(t, RunScheduleInstallE(cmd, []string{}))
+
+		assert.Error(t, RunScheduleRemoveE(cmd, []string{"projects"}))
+	})
+
+	t.Run("red case - profile names must not contain paths", func(t *testing.T) {
+		home, system := setup(t, "systemctl", "crontab")
+		outside := filepath.Join(home, ".config", "x.timer")
+		require.NoError(t, os.WriteFile(outside, nil, 0o644))
+
+		cmd := &cobra.Command{}
+		for _, name := range []string{"../../x", "..", `..\x`} {
+			assert.ErrorContains(t, RunScheduleRemoveE(cmd, []string{name}), "invalid profile")
+		}
+		assert.FileExists(t, outside)
+		assert.Empty(t, system.commands)
+
+		wiper.CfgFile = filepath.Join(home, "work.yaml")
+		t.Cleanup(func() { wiper.CfgFile = "" })
+		cmd, _ = newInstallCmd(t, map[string]string{profileFlag: "../../x", cronFlag: "true"})
+		assert.ErrorContains(t, RunScheduleInstallE(cmd, []string{}), "invalid profile")
+	})
+
+	t.Run("red case - profile names must not inject crontab lines or units", func(t *testing.T) {
+		home, system := setup(t, "systemctl", "crontab")
+		wiper.CfgFile = filepath.Join(home, "work.yaml")
+		t.Cleanup(func() { wiper.CfgFile = "" })
+
+		for _, cron := range []string{"true", "false"} {
+			cmd, _ := newInstallCmd(t, map[string]string{profileFlag: "x\n* * * * * rm -rf ~", cronFlag: cron})
+			assert.ErrorContains(t, RunScheduleInstallE(cmd, []string{}), "invalid profile")
+		}
+		assert.Empty(t, system.commands)
+		assert.NoDirExists(t, filepath.Join(home, ".config", "systemd"))
+	})
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	wiper "github.com/steffakasid/wiper/internal"
+)
+
+// Constants used in trash command flags
+const (
+	olderThanFlag = "older-than"
+	maxSizeFlag   = "max-size"
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage the items wiper moved to the trash.",
+	Long: `Manage the items wiper moved to the trash. Only items wiper trashed itself
+are listed or removed; everything else in the trash folder is left alone.`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the items wiper moved to the trash.",
+	Args:  cobra.NoArgs,
+	RunE:  RunTrashListE,
+}
+
+var trashEmptyCmd = &cobra.Command{
+	Use:   "empty",
+	Short: "Permanently delete all items wiper moved to the trash.",
+	Args:  cobra.NoArgs,
+	RunE:  RunTrashEmptyE,
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Permanently delete items wiper moved to the trash by age or total size.",
+	Example: `  wiper trash purge --older-than 14d
+  wiper trash purge --max-size 5GB`,
+	Args: cobra.NoArgs,
+	RunE: RunTrashPurgeE,
+}
+
+func currentTrash() (*wiper.Trash, error) {
+	setupLogging()
+	if err := wiper.RefreshInstanceFromViper(); err != nil {
+		return nil, err
+	}
+	return wiper.GetInstance().Trash(), nil
+}
+
+// purgeTrash deletes items from the trash with purge and records them in the
+// history.
+func purgeTrash(out io.Writer, purge func(trash *wiper.Trash) ([]wiper.TrashItem, error)) error {
+	trash, err := currentTrash()
+	if err != nil {
+		return err
+	}
+	w := wiper.GetInstance()
+	started := time.Now()
+	purged, err := purge(trash)
+	printPurged(out, purged)
+
+	report := &wiper.Report{}
+	if err != nil {
+		report.Add(wiper.Event{Type: wiper.EventError, Path: trash.Dir, Op: "purge", Err: err})
+	}
+	run := w.NewHistoryRun(started, report)
+	run.BaseDirs = []string{trash.Dir}
+	run.AddPurged(trash, purged, "")
+	saveHistory(&run)
+	return err
+}
+
+func RunTrashListE(cmd *cobra.Command, args []string) error {
+	trash, err := currentTrash()
+	if err != nil {
+		return err
+	}
+	items, err := trash.List()
+	if err != nil {
+		return err
+	}
+	printTrashItems(cmd.OutOrStdout(), items)
+	return nil
+}
+
+func RunTrashEmptyE(cmd *cobra.Command, args []string) error {
+	return purgeTrash(cmd.OutOrStdout(), (*wiper.Trash).Empty)
+}
+
+func RunTrashPurgeE(cmd *cobra.Command, args []string) error {
+	olderThan, err := cmd.Flags().GetString(olderThanFlag)
+	if err != nil {
+		return err
+	}
+	maxSize, err := cmd.Flags().GetString(maxSizeFlag)
+	if err != nil {
+		return err
+	}
+	retention := wiper.TrashRetention{OlderThan: olderThan, MaxSize: maxSize}
+	if !retention.Enabled() {
+		return fmt.Errorf("at least one of --%s or --%s is required", olderThanFlag, maxSizeFlag)
+	}
+
+	return purgeTrash(cmd.OutOrStdout(), func(trash *wiper.Trash) ([]wiper.TrashItem, error) {
+		return trash.ApplyRetention(retention)
+	})
+}
+
+func printTrashItems(out io.Writer, items []wiper.TrashItem) {
+	if len(items) == 0 {
+		fmt.Fprintln(out, "No items in the trash.")
+		return
+	}
+
+	var total int64
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TRASHED\tSIZE\tORIGINAL PATH\tNAME")
+	for _, item := range items {
+		total += item.Size
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", item.TrashedAt.Format(time.DateTime), wiper.FormatSize(item.Size), item.OriginalPath, item.Name)
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(out, "%d items, %s.\n", len(items), wiper.FormatSize(total))
+}
+
+func printPurged(out io.Writer, purged []wiper.TrashItem) {
+	var total int64
+	for _, item := range purged {
+		total += item.Size
+	}
+	fmt.Fprintf(out, "Deleted %d items from the trash, freed %s.\n", len(purged), wiper.FormatSize(total))
+}
+
+func init() {
+	flags := trashPurgeCmd.Flags()
+	flags.String(olderThanFlag, "", "Delete items trashed longer ago than this, e.g. 14d, 2w or 36h.")
+	flags.String(maxSizeFlag, "", "Delete the oldest items until the tracked items take up at most this size, e.g. 5GB.")
+
+	trashCmd.AddCommand(trashListCmd, trashEmptyCmd, trashPurgeCmd)
+	rootCmd.AddCommand(trashCmd)
+}
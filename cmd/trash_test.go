@@ -0,0 +1,127 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	wiper "github.com/steffakasid/wiper/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func trashSomething(t *testing.T) string {
+	t.Helper()
+
+	testDir := t.TempDir()
+	testHome := t.TempDir()
+	t.Setenv("HOME", testHome)
+	t.Setenv("XDG_STATE_HOME", "")
+
+	fileToTrash := filepath.Join(testDir, "file.orig")
+	require.NoError(t, os.WriteFile(fileToTrash, []byte("content"), 0o644))
+
+	wiper.CfgFile = ""
+	viper.Reset()
+	wiper.InitConfig()
+
+	viper.Set(baseDirFlag, testDir)
+	viper.Set(wipeOutFlag, []string{"file.orig"})
+	viper.Set(useTrashFlag, true)
+	require.NoError(t, RunWiperE(&cobra.Command{}, []string{}))
+	return fileToTrash
+}
+
+func TestTrashCommands(t *testing.T) {
+	t.Run("green case - list shows trashed items", func(t *testing.T) {
+		original := trashSomething(t)
+
+		out := &bytes.Buffer{}
+		cmd := &cobra.Command{}
+		cmd.SetOut(out)
+		require.NoError(t, RunTrashListE(cmd, []string{}))
+
+		assert.Contains(t, out.String(), original)
+		assert.Contains(t, out.String(), "1 items, 7 B.")
+	})
+
+	t.Run("empty deletes trashed items", func(t *testing.T) {
+		trashSomething(t)
+
+		out := &bytes.Buffer{}
+		cmd := &cobra.Command{}
+		cmd.SetOut(out)
+		require.NoError(t, RunTrashEmptyE(cmd, []string{}))
+		assert.Contains(t, out.String(), "Deleted 1 items from the trash")
+		assert.NoFileExists(t, filepath.Join(wiper.TrashDir(), "file.orig"))
+
+		out.Reset()
+		require.NoError(t, RunTrashListE(cmd, []string{}))
+		assert.Contains(t, out.String(), "No items in the trash.")
+
+		runs, err := wiper.LoadHistory()
+		require.NoError(t, err)
+		require.Len(t, runs, 2)
+		assert.Equal(t, []string{wiper.TrashDir()}, runs[1].BaseDirs)
+		assert.Equal(t, []wiper.HistoryEntry{
+			{Path: filepath.Join(wiper.TrashDir(), "file.orig"), Action: "purged", Size: 7},
+		}, runs[1].Entries)
+	})
+
+	t.Run("purge keeps items within the limits", func(t *testing.T) {
+		trashSomething(t)
+
+		out := &bytes.Buffer{}
+		cmd := &cobra.Command{}
+		cmd.SetOut(out)
+		cmd.Flags().AddFlagSet(trashPurgeCmd.Flags())
+		require.NoError(t, cmd.Flags().Set(olderThanFlag, "14d"))
+		t.Cleanup(func() {
+			require.NoError(t, cmd.Flags().Set(olderThanFlag, ""))
+		})
+
+		require.NoError(t, RunTrashPurgeE(cmd, []string{}))
+		assert.Contains(t, out.String(), "Deleted 0 items from the trash")
+		assert.FileExists(t, filepath.Join(wiper.TrashDir(), "file.orig"))
+	})
+
+	t.Run("red case - purge without limits", func(t *testing.T) {
+		cmd := &cobra.Command{}
+		cmd.Flags().AddFlagSet(trashPurgeCmd.Flags())
+		assert.Error(t, RunTrashPurgeE(cmd, []string{}))
+	})
+
+	t.Run("trash_retention is applied after a run", func(t *testing.T) {
+		testHome := t.TempDir()
+		t.Setenv("HOME", testHome)
+		t.Setenv("XDG_STATE_HOME", "")
+		trashDir := filepath.Join(testHome, ".Trash")
+		require.NoError(t, os.MkdirAll(filepath.Join(trashDir, ".wiper"), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(trashDir, "old.orig"), []byte("old"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(trashDir, ".wiper", "old.orig.json"),
+			[]byte(`{"name":"old.orig","original_path":"/x/old.orig","trashed_at":"2020-01-01T00:00:00Z","size":3}`), 0o600))
+
+		wiper.CfgFile = ""
+		viper.Reset()
+		wiper.InitConfig()
+		viper.Set(baseDirFlag, t.TempDir())
+		viper.Set("trash_retention.older_than", "14d")
+
+		require.NoError(t, RunWiperE(&cobra.Command{}, []string{}))
+		assert.NoFileExists(t, filepath.Join(trashDir, "old.orig"))
+
+		runs, err := wiper.LoadHistory()
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, []wiper.HistoryEntry{
+			{Path: filepath.Join(trashDir, "old.orig"), Action: "purged", Rule: "trash_retention", Size: 3},
+		}, runs[0].Entries)
+		assert.Equal(t, int64(3), runs[0].WipedBytes)
+	})
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/steffakasid/eslog"
+	wiper "github.com/steffakasid/wiper/internal"
+)
+
+// Constants used in watch command flags
+const (
+	debounceFlag = "debounce"
+	delayFlag    = "delay"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch base_dir and wipe matching entries as they appear.",
+	Long: `Watch base_dir recursively and apply the rules to every entry which is
+created or moved into it, until wiper is interrupted. An entry is checked once
+it did not change for the debounce time and wiped once it is older than the
+delay of its rule. Entries which already exist are left alone; run wiper
+without a command to wipe them.`,
+	Example: `  wiper watch
+  wiper watch --delay 10m -p '\.orig$'`,
+	Args: cobra.NoArgs,
+	RunE: RunWatchE,
+}
+
+func RunWatchE(cmd *cobra.Command, args []string) error {
+	setupLogging()
+	if err := wiper.RefreshInstanceFromViper(); err != nil {
+		return err
+	}
+	w := wiper.GetInstance()
+
+	flags := cmd.Flags()
+	if flags.Changed(debounceFlag) {
+		w.Watch.Debounce, _ = flags.GetString(debounceFlag)
+	}
+	if flags.Changed(delayFlag) {
+		w.Watch.Delay, _ = flags.GetString(delayFlag)
+	}
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	lock, err := lockRun(cmd, w)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		eslog.LogIfError(lock.Release(), eslog.Warn)
+	}()
+
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	started := time.Now()
+	report := &wiper.Report{}
+	events := make(chan wiper.Event)
+	done := make(chan struct{})
+	go func() {
+		for event := range events {
+			logEvent(event)
+			report.Add(event)
+		}
+		close(done)
+	}()
+	err = w.WatchFiles(ctx, events)
+	<-done
+	run := w.NewHistoryRun(started, report)
+	saveHistory(&run)
+	if err != nil {
+		return err
+	}
+
+	eslog.Info("Stopped watching.")
+	fmt.Fprintf(cmd.OutOrStdout(), "Wiped %d files and %d directories.\n", w.WipedFiles, w.WipedDirs)
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d errors occurred during watching", len(report.Errors))
+	}
+	return nil
+}
+
+func init() {
+	flags := watchCmd.Flags()
+	flags.String(debounceFlag, "", "How long an entry has to stay unchanged before it is checked. [default: watch.debounce or 2s]")
+	flags.String(delayFlag, "", "How long matched entries are kept after they appeared, unless their rule sets a delay. [default: watch.delay or 0]")
+	flags.String(waitFlag, "", "How long to wait for another wiper run on the same base_dir to finish, e.g. 10m. [default: fail immediately]")
+
+	rootCmd.AddCommand(watchCmd)
+}
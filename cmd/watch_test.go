@@ -0,0 +1,71 @@
+/*
+Copyright © 2024 steffakasid
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	wiper "github.com/steffakasid/wiper/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWatchE(t *testing.T) {
+	setup := func(t *testing.T) (string, *cobra.Command, *bytes.Buffer) {
+		t.Helper()
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("XDG_STATE_HOME", "")
+		testDir := t.TempDir()
+
+		wiper.CfgFile = ""
+		viper.Reset()
+		wiper.InitConfig()
+		viper.Set(baseDirFlag, testDir)
+		viper.Set(wipeOutPatternFlag, []string{`\.orig$`})
+
+		out := &bytes.Buffer{}
+		cmd := &cobra.Command{}
+		cmd.SetOut(out)
+		cmd.Flags().AddFlagSet(watchCmd.Flags())
+		return testDir, cmd, out
+	}
+
+	t.Run("green case - new matches are wiped until the context ends", func(t *testing.T) {
+		testDir, cmd, out := setup(t)
+		require.NoError(t, cmd.Flags().Set(debounceFlag, "10ms"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cmd.SetContext(ctx)
+
+		errs := make(chan error, 1)
+		go func() { errs <- RunWatchE(cmd, []string{}) }()
+		// give the watcher time to register base_dir
+		time.Sleep(200 * time.Millisecond)
+		target := filepath.Join(testDir, "file.orig")
+		require.NoError(t, os.WriteFile(target, nil, 0o644))
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(target)
+			return os.IsNotExist(err)
+		}, 5*time.Second, 10*time.Millisecond)
+		cancel()
+
+		require.NoError(t, <-errs)
+		assert.Contains(t, out.String(), "Wiped 1 files and 0 directories.")
+	})
+
+	t.Run("red case - invalid delay", func(t *testing.T) {
+		_, cmd, _ := setup(t)
+		require.NoError(t, cmd.Flags().Set(delayFlag, "later"))
+		t.Cleanup(func() {
+			require.NoError(t, cmd.Flags().Set(delayFlag, ""))
+		})
+		assert.Error(t, RunWatchE(cmd, []string{}))
+	})
+}
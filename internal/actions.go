@@ -0,0 +1,184 @@
+package wiper
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/steffakasid/eslog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Actions applied to matched entries
+const (
+	ActionRemove     = "remove"
+	ActionTrash      = "trash"
+	ActionArchive    = "archive"
+	ActionQuarantine = "quarantine"
+	ActionShred      = "shred"
+)
+
+var actions = []string{ActionRemove, ActionTrash, ActionArchive, ActionQuarantine, ActionShred}
+
+// action returns the configured action. use_trash is kept as a shorthand for
+// action: trash.
+func (w *Wiper) action() string {
+	if w.Action != "" {
+		return w.Action
+	}
+	if w.UseTrash {
+		return ActionTrash
+	}
+	return ActionRemove
+}
+
+// Validate checks the settings which can't be checked while unmarshalling.
+func (w *Wiper) Validate() error {
+	if err := validateAction(w.action(), w.QuarantineDir); err != nil {
+		return err
+	}
+	if err := validatePresets(w.Presets); err != nil {
+		return err
+	}
+	if w.OnlyOwnedByCurrentUser && !ownersSupported {
+		return fmt.Errorf("only_owned_by_current_user is not supported on this platform")
+	}
+	rules, err := w.compileRules()
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if err := w.validateRule(rule); err != nil {
+			return err
+		}
+	}
+	if err := w.Shred.validate(); err != nil {
+		return err
+	}
+	if err := w.Dupes.validate(); err != nil {
+		return err
+	}
+	if err := w.Watch.validate(); err != nil {
+		return err
+	}
+	if _, _, err := w.TrashRetention.Limits(); err != nil {
+		return err
+	}
+	return w.Archive.validate()
+}
+
+// validateRule checks the settings of a compiled rule which depend on the
+// global config.
+func (w *Wiper) validateRule(rule *Rule) error {
+	if err := validateAction(rule.action(w), w.QuarantineDir); err != nil {
+		return fmt.Errorf("rule %s: %w", rule.Name, err)
+	}
+	if err := rule.Shred.validate(); err != nil {
+		return fmt.Errorf("rule %s: %w", rule.Name, err)
+	}
+	if rule.Delay != "" {
+		if _, err := ParseAge(rule.Delay); err != nil {
+			return fmt.Errorf("rule %s: delay: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateAction(action, quarantineDir string) error {
+	if !slices.Contains(actions, action) {
+		return fmt.Errorf("unknown action %q, expected one of %v", action, actions)
+	}
+	if action == ActionQuarantine && quarantineDir == "" {
+		return fmt.Errorf("action %q requires quarantine_dir to be set", ActionQuarantine)
+	}
+	return nil
+}
+
+// wipe applies the action of rule to target and reports the outcome.
+func (w *Wiper) wipe(ctx context.Context, target, trash string, matched *Rule, isDir bool, events chan Event) {
+	rule := matched.Name
+	ctx, span := tracer.Start(ctx, "wipe", trace.WithAttributes(
+		attribute.String("wiper.path", target),
+		attribute.String("wiper.rule", rule),
+		attribute.Bool("wiper.is_dir", isDir),
+	))
+	defer span.End()
+	if w.DryRun {
+		candidate := w.addCandidate(target, matched, isDir)
+		events <- Event{Type: EventMatched, Path: target, IsDir: isDir, Rule: rule, Size: candidate.Size}
+		return
+	}
+	events <- Event{Type: EventMatched, Path: target, IsDir: isDir, Rule: rule}
+
+	if result := w.runMatchHook(target, rule, isDir); result != nil {
+		events <- Event{Type: EventHook, Path: target, IsDir: isDir, Rule: rule, Hook: result}
+		if result.Err != nil {
+			events <- Event{Type: EventSkipped, Path: target, IsDir: isDir, Rule: rule, Reason: "vetoed by on_match hook: " + result.Err.Error()}
+			return
+		}
+	}
+
+	size, err := sizeOf(w.fs(), target)
+	if err != nil {
+		eslog.Debugf("Can't determine the size of %s: %s", target, err)
+	}
+	action := matched.action(w)
+	record, err := w.auditRecord(target, rule, action, isDir, size)
+	if err != nil {
+		events <- errorEvent("audit", target, isDir, err)
+		return
+	}
+	w.mu.Lock()
+	if isDir {
+		w.WipedDirs++
+	} else {
+		w.WipedFiles++
+	}
+	w.mu.Unlock()
+
+	span.SetAttributes(attribute.String("wiper.action", action), attribute.Int64("wiper.size", size))
+	_, actionSpan := tracer.Start(ctx, action, trace.WithAttributes(attribute.String("wiper.path", target)))
+	event := Event{Path: target, IsDir: isDir, Rule: rule, Size: size}
+	switch action {
+	case ActionTrash:
+		event.Type = EventTrashed
+		event.Destination, err = w.moveToTrash(target, trash, isDir)
+	case ActionArchive:
+		event.Type = EventArchived
+		event.Destination, err = w.archiveEntry(target, isDir)
+	case ActionQuarantine:
+		event.Type = EventQuarantined
+		event.Destination, err = w.quarantineEntry(target, rule, isDir)
+	case ActionShred:
+		event.Type = EventShredded
+		event.Reason, err = w.shredEntry(target, isDir, matched.Shred.merge(w.Shred))
+	default:
+		event.Type = EventWiped
+		err = w.remove(target, isDir)
+	}
+	endSpan(actionSpan, err)
+	if auditErr := w.audit(record, event.Destination, err); auditErr != nil {
+		events <- errorEvent("audit", target, isDir, auditErr)
+	}
+	if err != nil {
+		events <- errorEvent(action, target, isDir, err)
+		return
+	}
+	w.reclaimed(size)
+	events <- event
+}
+
+// reclaimed adds the size of a wiped entry to WipedBytes.
+func (w *Wiper) reclaimed(size int64) {
+	w.mu.Lock()
+	w.WipedBytes += size
+	w.mu.Unlock()
+}
+
+func (w *Wiper) remove(target string, isDir bool) error {
+	if isDir {
+		return w.fs().RemoveAll(target)
+	}
+	return w.fs().Remove(target)
+}
@@ -0,0 +1,296 @@
+package wiper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Archive formats
+const (
+	ArchiveTarGz  = "tar.gz"
+	ArchiveTarZst = "tar.zst"
+	ArchiveZip    = "zip"
+)
+
+var archiveFormats = []string{ArchiveTarGz, ArchiveTarZst, ArchiveZip}
+
+// Archive configures the archive action. All items wiped during one run are
+// appended to a single archive in Dir, named after the time it was created.
+type Archive struct {
+	Dir    string `json:"dir,omitempty" mapstructure:"dir" yaml:"dir"`
+	Format string `json:"format,omitempty" mapstructure:"format" yaml:"format"`
+}
+
+func (a Archive) validate() error {
+	if !slices.Contains(archiveFormats, a.format()) {
+		return fmt.Errorf("unknown archive format %q, expected one of %v", a.Format, archiveFormats)
+	}
+	return nil
+}
+
+func (a Archive) format() string {
+	if a.Format == "" {
+		return ArchiveTarGz
+	}
+	return a.Format
+}
+
+func (a Archive) dir() string {
+	if a.Dir != "" {
+		return a.Dir
+	}
+	return filepath.Join(dataDir(), "archives")
+}
+
+// archiveEntry appends target with its path relative to BaseDir to the
+// archive of the current run and removes it once the archive is synced to
+// disk. It returns the path of the archive.
+func (w *Wiper) archiveEntry(target string, isDir bool) (string, error) {
+	name, err := filepath.Rel(w.BaseDir, target)
+	if err != nil {
+		return "", err
+	}
+
+	w.archiveMu.Lock()
+	if w.archiver == nil {
+		w.archiver, err = newArchiveWriter(w.fs(), w.Archive.dir(), w.Archive.format())
+		if err != nil {
+			w.archiveMu.Unlock()
+			return "", err
+		}
+	}
+	archivePath := w.archiver.path
+	err = w.archiver.add(w.fs(), name, target)
+	if err == nil {
+		err = w.archiver.flush()
+	}
+	w.archiveMu.Unlock()
+	if err != nil {
+		return archivePath, err
+	}
+
+	return archivePath, w.remove(target, isDir)
+}
+
+// closeArchive finishes the archive of the current run, if one was started.
+func (w *Wiper) closeArchive() error {
+	w.archiveMu.Lock()
+	defer w.archiveMu.Unlock()
+
+	if w.archiver == nil {
+		return nil
+	}
+	err := w.archiver.close()
+	w.archiver = nil
+	return err
+}
+
+type archiveWriter struct {
+	path       string
+	file       File
+	compressor io.WriteCloser
+	tar        *tar.Writer
+	zip        *zip.Writer
+}
+
+func newArchiveWriter(fsys FileSystem, dir, format string) (*archiveWriter, error) {
+	if err := mkdirAll(fsys, dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("wiper-%s.%s", time.Now().Format("20060102-150405.000"), format)
+	a := &archiveWriter{path: filepath.Join(dir, name)}
+	file, err := fsys.Create(a.path)
+	if err != nil {
+		return nil, err
+	}
+	a.file = file
+
+	switch format {
+	case ArchiveZip:
+		a.zip = zip.NewWriter(file)
+	case ArchiveTarZst:
+		encoder, err := zstd.NewWriter(file)
+		if err != nil {
+			return nil, errors.Join(err, file.Close())
+		}
+		a.compressor = encoder
+		a.tar = tar.NewWriter(encoder)
+	default:
+		a.compressor = gzip.NewWriter(file)
+		a.tar = tar.NewWriter(a.compressor)
+	}
+	return a, nil
+}
+
+// add writes source and, for directories, everything below it as name.
+func (a *archiveWriter) add(fsys FileSystem, name, source string) error {
+	info, err := fsys.Lstat(source)
+	if err != nil {
+		return err
+	}
+
+	link := ""
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = fsys.Readlink(source); err != nil {
+			return err
+		}
+	}
+
+	if a.zip != nil {
+		err = a.addZip(fsys, name, source, link, info)
+	} else {
+		err = a.addTar(fsys, name, source, link, info)
+	}
+	if err != nil || !info.IsDir() {
+		return err
+	}
+
+	entries, err := fsys.ReadDir(source)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := a.add(fsys, filepath.Join(name, entry.Name()), filepath.Join(source, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *archiveWriter) addTar(fsys FileSystem, name, source, link string, info fs.FileInfo) error {
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	if !info.Mode().IsRegular() {
+		return a.tar.WriteHeader(header)
+	}
+
+	// A read error or a file which changed its size since Lstat would leave
+	// a partial entry behind, which breaks the tar stream for the rest of the
+	// run. So files are copied next to the archive first.
+	staged := a.path + ".part"
+	defer func() {
+		_ = fsys.Remove(staged)
+	}()
+	if header.Size, err = stageFile(fsys, staged, source); err != nil {
+		return err
+	}
+	if err := a.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	return copyFile(fsys, a.tar, staged)
+}
+
+// stageFile copies source to staged and returns its size.
+func stageFile(fsys FileSystem, staged, source string) (int64, error) {
+	dst, err := fsys.Create(staged)
+	if err != nil {
+		return 0, err
+	}
+	src, err := fsys.Open(source)
+	if err != nil {
+		return 0, errors.Join(err, dst.Close())
+	}
+	size, err := io.Copy(dst, src)
+	return size, errors.Join(err, src.Close(), dst.Close())
+}
+
+func (a *archiveWriter) addZip(fsys FileSystem, name, source, link string, info fs.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		header.Name += "/"
+	} else {
+		header.Method = zip.Deflate
+	}
+	writer, err := a.zip.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	switch {
+	case link != "":
+		_, err = io.WriteString(writer, link)
+		return err
+	case info.Mode().IsRegular():
+		return copyFile(fsys, writer, source)
+	}
+	return nil
+}
+
+// flush writes everything buffered by the archive and compression writers to
+// the archive file and syncs it, so an entry is on disk before its source is
+// removed.
+func (a *archiveWriter) flush() error {
+	var err error
+	switch {
+	case a.zip != nil:
+		err = a.zip.Flush()
+	case a.tar != nil:
+		err = a.tar.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	if compressor, ok := a.compressor.(interface{ Flush() error }); ok {
+		if err := compressor.Flush(); err != nil {
+			return err
+		}
+	}
+	return a.file.Sync()
+}
+
+func (a *archiveWriter) close() error {
+	var errs []error
+	if a.tar != nil {
+		errs = append(errs, a.tar.Close())
+	}
+	if a.zip != nil {
+		errs = append(errs, a.zip.Close())
+	}
+	if a.compressor != nil {
+		errs = append(errs, a.compressor.Close())
+	}
+	errs = append(errs, a.file.Sync(), a.file.Close())
+	return errors.Join(errs...)
+}
+
+func copyFile(fsys FileSystem, dst io.Writer, source string) error {
+	file, err := fsys.Open(source)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, file)
+	return errors.Join(err, file.Close())
+}
+
+// mkdirAll creates dir and any missing parents on fsys.
+func mkdirAll(fsys FileSystem, dir string, perm fs.FileMode) error {
+	for _, d := range ancestors(filepath.Clean(dir)) {
+		if existsOn(fsys, d) {
+			continue
+		}
+		if err := fsys.Mkdir(d, perm); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package wiper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTarEntries(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+
+	entries := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		entries[header.Name] = string(content)
+	}
+	return entries
+}
+
+func archiveFixture(t *testing.T) string {
+	t.Helper()
+
+	baseDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "project", "logs"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "project", "logs", "app.log"), []byte("log line"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "project", "main.go.orig"), []byte("orig"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "project", "main.go"), []byte("keep"), 0o644))
+	return baseDir
+}
+
+func singleArchive(t *testing.T, dir string) string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	return filepath.Join(dir, entries[0].Name())
+}
+
+func TestArchiveAction(t *testing.T) {
+	t.Run("green case - tar.gz keeps paths relative to base dir", func(t *testing.T) {
+		baseDir := archiveFixture(t)
+		archiveDir := filepath.Join(t.TempDir(), "archives")
+		sut := &Wiper{
+			WipeOutPattern: []string{`.*\.orig$`},
+			WipeOutDirs:    []string{"logs"},
+			BaseDir:        baseDir,
+			Action:         ActionArchive,
+			Archive:        Archive{Dir: archiveDir},
+		}
+
+		events := collectEvents(sut)
+		assert.Empty(t, eventsOfType(events, EventError))
+		archived := eventsOfType(events, EventArchived)
+		require.Len(t, archived, 2)
+
+		archivePath := singleArchive(t, archiveDir)
+		assert.Equal(t, archivePath, archived[0].Destination)
+		assert.Regexp(t, `wiper-.*\.tar\.gz$`, archivePath)
+
+		assert.NoFileExists(t, filepath.Join(baseDir, "project", "main.go.orig"))
+		assert.NoDirExists(t, filepath.Join(baseDir, "project", "logs"))
+		assert.FileExists(t, filepath.Join(baseDir, "project", "main.go"))
+
+		file, err := os.Open(archivePath)
+		require.NoError(t, err)
+		defer file.Close()
+		gz, err := gzip.NewReader(file)
+		require.NoError(t, err)
+
+		entries := readTarEntries(t, gz)
+		assert.Equal(t, "orig", entries["project/main.go.orig"])
+		assert.Equal(t, "log line", entries["project/logs/app.log"])
+		assert.Contains(t, entries, "project/logs/")
+		assert.NotContains(t, entries, "project/main.go")
+	})
+
+	t.Run("tar.zst format", func(t *testing.T) {
+		baseDir := archiveFixture(t)
+		archiveDir := t.TempDir()
+		sut := &Wiper{
+			WipeOutPattern: []string{`.*\.orig$`},
+			BaseDir:        baseDir,
+			Action:         ActionArchive,
+			Archive:        Archive{Dir: archiveDir, Format: ArchiveTarZst},
+		}
+
+		events := collectEvents(sut)
+		assert.Empty(t, eventsOfType(events, EventError))
+
+		file, err := os.Open(singleArchive(t, archiveDir))
+		require.NoError(t, err)
+		defer file.Close()
+		decoder, err := zstd.NewReader(file)
+		require.NoError(t, err)
+		defer decoder.Close()
+
+		entries := readTarEntries(t, decoder)
+		assert.Equal(t, map[string]string{"project/main.go.orig": "orig"}, entries)
+	})
+
+	t.Run("zip format", func(t *testing.T) {
+		baseDir := archiveFixture(t)
+		archiveDir := t.TempDir()
+		sut := &Wiper{
+			WipeOutDirs: []string{"logs"},
+			BaseDir:     baseDir,
+			Action:      ActionArchive,
+			Archive:     Archive{Dir: archiveDir, Format: ArchiveZip},
+		}
+
+		events := collectEvents(sut)
+		assert.Empty(t, eventsOfType(events, EventError))
+
+		reader, err := zip.OpenReader(singleArchive(t, archiveDir))
+		require.NoError(t, err)
+		defer reader.Close()
+
+		names := []string{}
+		for _, file := range reader.File {
+			names = append(names, file.Name)
+		}
+		sort.Strings(names)
+		assert.Equal(t, []string{"project/logs/", "project/logs/app.log"}, names)
+	})
+
+	t.Run("memory file system", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base/sub", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/sub/file.orig", []byte("data"), 0o644))
+		sut := &Wiper{
+			WipeOutPattern: []string{`.*\.orig$`},
+			BaseDir:        "/base",
+			Action:         ActionArchive,
+			Archive:        Archive{Dir: "/archives"},
+			FS:             fsys,
+		}
+
+		events := collectEvents(sut)
+		assert.Empty(t, eventsOfType(events, EventError))
+		assert.False(t, existsOn(fsys, "/base/sub/file.orig"))
+
+		archived := eventsOfType(events, EventArchived)
+		require.Len(t, archived, 1)
+		content, err := fsys.ReadFile(archived[0].Destination)
+		require.NoError(t, err)
+		assert.NotEmpty(t, content)
+	})
+
+	t.Run("red case - entries are written out before their source is removed", func(t *testing.T) {
+		for _, format := range []string{ArchiveTarGz, ArchiveTarZst} {
+			fsys := NewMemFileSystem()
+			require.NoError(t, fsys.MkdirAll("/base", 0o755))
+			content := bytes.Repeat([]byte("x"), 128)
+			require.NoError(t, fsys.WriteFile("/base/file.orig", content, 0o644))
+			sut := &Wiper{BaseDir: "/base", Action: ActionArchive, Archive: Archive{Dir: "/archives", Format: format}, FS: fsys}
+
+			archivePath, err := sut.archiveEntry("/base/file.orig", false)
+			require.NoError(t, err)
+			t.Cleanup(func() { assert.NoError(t, sut.closeArchive()) })
+			assert.False(t, existsOn(fsys, "/base/file.orig"))
+
+			// the run is still going, so the archive is not closed yet
+			archived, err := fsys.ReadFile(archivePath)
+			require.NoError(t, err)
+			var r io.Reader
+			if format == ArchiveTarZst {
+				decoder, err := zstd.NewReader(bytes.NewReader(archived))
+				require.NoError(t, err)
+				defer decoder.Close()
+				r = decoder
+			} else {
+				r, err = gzip.NewReader(bytes.NewReader(archived))
+				require.NoError(t, err)
+			}
+			tr := tar.NewReader(r)
+			header, err := tr.Next()
+			require.NoError(t, err, format)
+			assert.Equal(t, "file.orig", header.Name)
+			read, err := io.ReadAll(tr)
+			require.NoError(t, err, format)
+			assert.Equal(t, content, read, format)
+		}
+	})
+
+	t.Run("archives of earlier runs below base dir are left alone", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base/sub", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/sub/photos.zip", []byte("data"), 0o644))
+		newWiper := func() *Wiper {
+			return &Wiper{
+				WipeOutPattern: []string{`.*\.zip$`},
+				BaseDir:        "/base",
+				Action:         ActionArchive,
+				Archive:        Archive{Dir: "/base/archives", Format: ArchiveZip},
+				FS:             fsys,
+			}
+		}
+
+		first := eventsOfType(collectEvents(newWiper()), EventArchived)
+		require.Len(t, first, 1)
+		events := collectEvents(newWiper())
+		assert.Empty(t, eventsOfType(events, EventArchived))
+		assert.Empty(t, eventsOfType(events, EventError))
+		assert.True(t, existsOn(fsys, first[0].Destination))
+	})
+
+	t.Run("red case - a failed read keeps the archive usable", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/broken.orig", make([]byte, 100), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/fine.orig", []byte("fine"), 0o644))
+		archiver, err := newArchiveWriter(failingReadFS{MemFileSystem: fsys, path: "/base/broken.orig"}, "/archives", ArchiveTarGz)
+		require.NoError(t, err)
+
+		assert.Error(t, archiver.add(failingReadFS{MemFileSystem: fsys, path: "/base/broken.orig"}, "broken.orig", "/base/broken.orig"))
+		require.NoError(t, archiver.add(fsys, "fine.orig", "/base/fine.orig"))
+		require.NoError(t, archiver.close())
+
+		content, err := fsys.ReadFile(archiver.path)
+		require.NoError(t, err)
+		gz, err := gzip.NewReader(bytes.NewReader(content))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"fine.orig": "fine"}, readTarEntries(t, gz))
+		assert.False(t, existsOn(fsys, archiver.path+".part"))
+	})
+
+	t.Run("red case - archive dir cannot be created", func(t *testing.T) {
+		baseDir := archiveFixture(t)
+		blocker := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(blocker, nil, 0o644))
+		sut := &Wiper{
+			WipeOutPattern: []string{`.*\.orig$`},
+			BaseDir:        baseDir,
+			Action:         ActionArchive,
+			Archive:        Archive{Dir: filepath.Join(blocker, "archives")},
+		}
+
+		events := collectEvents(sut)
+		errs := eventsOfType(events, EventError)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "archive", errs[0].Op)
+		assert.FileExists(t, filepath.Join(baseDir, "project", "main.go.orig"), "item must be kept if it could not be archived")
+	})
+}
+
+// failingReadFS fails reading path after its first bytes.
+type failingReadFS struct {
+	*MemFileSystem
+	path string
+}
+
+func (f failingReadFS) Open(name string) (File, error) {
+	file, err := f.MemFileSystem.Open(name)
+	if err != nil || name != f.path {
+		return file, err
+	}
+	return &failingFile{File: file}, nil
+}
+
+type failingFile struct {
+	File
+	read bool
+}
+
+func (f *failingFile) Read(p []byte) (int, error) {
+	if f.read {
+		return 0, errors.New("input/output error")
+	}
+	f.read = true
+	return f.File.Read(p[:min(len(p), 10)])
+}
+
+func TestArchiveDefaults(t *testing.T) {
+	t.Run("default format and dir", func(t *testing.T) {
+		t.Setenv("XDG_DATA_HOME", "/data")
+		sut := Archive{}
+		assert.Equal(t, ArchiveTarGz, sut.format())
+		assert.Equal(t, "/data/wiper/archives", sut.dir())
+		assert.NoError(t, sut.validate())
+	})
+
+	t.Run("red case - unknown format", func(t *testing.T) {
+		sut := Archive{Format: "rar"}
+		assert.Error(t, sut.validate())
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("green case - use_trash maps to trash action", func(t *testing.T) {
+		sut := Wiper{UseTrash: true}
+		assert.Equal(t, ActionTrash, sut.action())
+		assert.NoError(t, sut.Validate())
+	})
+
+	t.Run("explicit action wins over use_trash", func(t *testing.T) {
+		sut := Wiper{UseTrash: true, Action: ActionArchive}
+		assert.Equal(t, ActionArchive, sut.action())
+	})
+
+	t.Run("red case - unknown action", func(t *testing.T) {
+		sut := Wiper{Action: "burn"}
+		assert.Error(t, sut.Validate())
+	})
+}
@@ -0,0 +1,158 @@
+package wiper
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// AuditLog configures the audit log, a JSON Lines file every wiped entry is
+// appended to.
+type AuditLog struct {
+	Path string `json:"path,omitempty" mapstructure:"path" yaml:"path"`
+	// Hash adds the SHA-256 hash of wiped files, which reads them completely.
+	Hash bool `json:"hash,omitempty" mapstructure:"hash" yaml:"hash"`
+}
+
+func (a AuditLog) enabled() bool {
+	return a.Path != ""
+}
+
+// AuditRecord is a line of the audit log.
+type AuditRecord struct {
+	Time        time.Time `json:"time"`
+	User        string    `json:"user"`
+	Host        string    `json:"host"`
+	Path        string    `json:"path"`
+	IsDir       bool      `json:"is_dir,omitempty"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256,omitempty"`
+	Rule        string    `json:"rule,omitempty"`
+	Action      string    `json:"action"`
+	Destination string    `json:"destination,omitempty"`
+	Outcome     string    `json:"outcome"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Outcomes of audited actions
+const (
+	AuditSucceeded = "succeeded"
+	AuditFailed    = "failed"
+)
+
+type auditWriter struct {
+	file File
+	user string
+	host string
+}
+
+// holdsAuditLog reports whether target is the audit log or a directory it
+// lies in, so wiping target would remove the log.
+func (w *Wiper) holdsAuditLog(target string) bool {
+	if !w.AuditLog.enabled() {
+		return false
+	}
+	log, err := filepath.Abs(w.AuditLog.Path)
+	if err != nil {
+		return false
+	}
+	target, err = filepath.Abs(target)
+	return err == nil && (target == log || isWithin(target, log))
+}
+
+// auditRecord prepares the record of an action on target before it is
+// applied, so the log is known to be writable and the content can still be
+// hashed. It returns nil if there is no audit log.
+func (w *Wiper) auditRecord(target, rule, action string, isDir bool, size int64) (*AuditRecord, error) {
+	if !w.AuditLog.enabled() {
+		return nil, nil
+	}
+	w.auditMu.Lock()
+	err := w.openAuditLog()
+	w.auditMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := filepath.Abs(target)
+	if err != nil {
+		path = target
+	}
+	record := &AuditRecord{Path: path, IsDir: isDir, Size: size, Rule: rule, Action: action}
+	if w.AuditLog.Hash && !isDir {
+		if record.SHA256, err = hashFile(w.fs(), target, -1); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// audit completes record with the outcome of the action and appends it to the
+// audit log. It returns once the record is synced to disk.
+func (w *Wiper) audit(record *AuditRecord, destination string, actionErr error) error {
+	if record == nil {
+		return nil
+	}
+	record.Time = time.Now()
+	record.Destination = destination
+	record.Outcome = AuditSucceeded
+	if actionErr != nil {
+		record.Outcome = AuditFailed
+		record.Error = actionErr.Error()
+	}
+
+	w.auditMu.Lock()
+	defer w.auditMu.Unlock()
+	if w.auditor == nil {
+		return errors.New("audit log is closed")
+	}
+	record.User, record.Host = w.auditor.user, w.auditor.host
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := w.auditor.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return w.auditor.file.Sync()
+}
+
+// openAuditLog opens the audit log for appending, unless it is open already.
+// auditMu must be held.
+func (w *Wiper) openAuditLog() error {
+	if w.auditor != nil {
+		return nil
+	}
+	if err := mkdirAll(w.fs(), filepath.Dir(w.AuditLog.Path), 0o700); err != nil {
+		return err
+	}
+	file, err := w.fs().OpenFile(w.AuditLog.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	w.auditor = &auditWriter{file: file, user: currentUserName()}
+	w.auditor.host, _ = os.Hostname()
+	return nil
+}
+
+func (w *Wiper) closeAuditLog() error {
+	w.auditMu.Lock()
+	defer w.auditMu.Unlock()
+	if w.auditor == nil {
+		return nil
+	}
+	err := w.auditor.file.Close()
+	w.auditor = nil
+	return err
+}
+
+func currentUserName() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return strconv.Itoa(os.Geteuid())
+}
@@ -0,0 +1,188 @@
+package wiper
+
+import (
+	"bufio"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	readRecords := func(t *testing.T, fsys *MemFileSystem, path string) []AuditRecord {
+		t.Helper()
+		file, err := fsys.Open(path)
+		require.NoError(t, err)
+		defer file.Close()
+		records := []AuditRecord{}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			record := AuditRecord{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+			records = append(records, record)
+		}
+		return records
+	}
+
+	files := map[string]string{
+		"/base/a.orig":  "hello",
+		"/base/cache/b": "12345678",
+	}
+
+	t.Run("green case - wiped entries are logged", func(t *testing.T) {
+		fsys := memTree(t, files)
+		sut := &Wiper{
+			WipeOutPattern: []string{`\.orig$`},
+			WipeOutDirs:    []string{"cache"},
+			BaseDir:        "/base",
+			AuditLog:       AuditLog{Path: "/var/log/wiper/audit.jsonl", Hash: true},
+			FS:             fsys,
+		}
+		collectEvents(sut)
+		// a second run appends to the log
+		require.NoError(t, fsys.WriteFile("/base/c.orig", nil, 0o644))
+		collectEvents(sut)
+
+		records := readRecords(t, fsys, "/var/log/wiper/audit.jsonl")
+		require.Len(t, records, 3)
+		byPath := map[string]AuditRecord{}
+		for _, record := range records {
+			assert.NotEmpty(t, record.User)
+			assert.False(t, record.Time.IsZero())
+			assert.Equal(t, ActionRemove, record.Action)
+			assert.Equal(t, AuditSucceeded, record.Outcome)
+			byPath[record.Path] = record
+		}
+		assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", byPath["/base/a.orig"].SHA256)
+		assert.Equal(t, int64(5), byPath["/base/a.orig"].Size)
+		assert.Equal(t, "wipe_out_pattern", byPath["/base/a.orig"].Rule)
+		assert.True(t, byPath["/base/cache"].IsDir)
+		assert.Empty(t, byPath["/base/cache"].SHA256)
+		assert.Equal(t, int64(8), byPath["/base/cache"].Size)
+		assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", byPath["/base/c.orig"].SHA256)
+	})
+
+	t.Run("failed actions are logged with their error", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/quarantine", nil, 0o644))
+		sut := &Wiper{
+			WipeOutPattern: []string{`\.orig$`},
+			Action:         ActionQuarantine,
+			QuarantineDir:  "/quarantine",
+			BaseDir:        "/base",
+			AuditLog:       AuditLog{Path: "/audit.jsonl"},
+			FS:             fsys,
+		}
+		errors := eventsOfType(collectEvents(sut), EventError)
+		require.NotEmpty(t, errors)
+
+		records := readRecords(t, fsys, "/audit.jsonl")
+		require.Len(t, records, 1)
+		assert.Equal(t, "/base/a.orig", records[0].Path)
+		assert.Equal(t, ActionQuarantine, records[0].Action)
+		assert.Equal(t, AuditFailed, records[0].Outcome)
+		assert.NotEmpty(t, records[0].Error)
+		assert.Empty(t, records[0].SHA256)
+	})
+
+	t.Run("red case - nothing is wiped if the audit log can't be written", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/var", nil, 0o644))
+		sut := &Wiper{
+			WipeOutPattern: []string{`\.orig$`},
+			BaseDir:        "/base",
+			AuditLog:       AuditLog{Path: "/var/log/audit.jsonl"},
+			FS:             fsys,
+		}
+		errors := eventsOfType(collectEvents(sut), EventError)
+		require.Len(t, errors, 1)
+		assert.Equal(t, "audit", errors[0].Op)
+		assert.True(t, existsOn(fsys, "/base/a.orig"))
+	})
+
+	t.Run("red case - the audit log and its directories are never wiped", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.MkdirAll("/base/logs", 0o755))
+		sut := &Wiper{
+			WipeOutPattern: []string{`\.jsonl$`},
+			WipeOutDirs:    []string{"logs"},
+			BaseDir:        "/base",
+			AuditLog:       AuditLog{Path: "/base/logs/audit.jsonl"},
+			FS:             fsys,
+		}
+		require.NoError(t, fsys.WriteFile("/base/logs/audit.jsonl", nil, 0o600))
+		events := collectEvents(sut)
+		skipped := eventsOfType(events, EventSkipped)
+		require.Len(t, skipped, 2)
+		assert.Equal(t, "/base/logs", skipped[0].Path)
+		assert.Equal(t, "/base/logs/audit.jsonl", skipped[1].Path)
+		for _, event := range skipped {
+			assert.Equal(t, "audit log", event.Reason)
+		}
+		assert.Empty(t, eventsOfType(events, EventError))
+		assert.True(t, existsOn(fsys, "/base/logs/audit.jsonl"))
+	})
+
+	t.Run("green case - items deleted from the trash are logged", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.MkdirAll("/home/user", 0o755))
+		sut := &Wiper{
+			WipeOutPattern: []string{`\.orig$`},
+			BaseDir:        "/base",
+			UseTrash:       true,
+			AuditLog:       AuditLog{Path: "/audit.jsonl"},
+			FS:             fsys,
+		}
+		collectEvents(sut)
+		trash := sut.Trash()
+		items, err := trash.List()
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+
+		purged, err := trash.ApplyRetention(TrashRetention{MaxSize: "1B"})
+		require.NoError(t, err)
+		require.Len(t, purged, 1)
+
+		records := readRecords(t, fsys, "/audit.jsonl")
+		require.Len(t, records, 2)
+		assert.Equal(t, ActionTrash, records[0].Action)
+		assert.Equal(t, trash.Path(items[0]), records[1].Path)
+		assert.Equal(t, "purge", records[1].Action)
+		assert.Equal(t, "trash_retention", records[1].Rule)
+		assert.Equal(t, int64(5), records[1].Size)
+		assert.Equal(t, AuditSucceeded, records[1].Outcome)
+	})
+
+	t.Run("red case - nothing is deleted from the trash if the audit log can't be written", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.MkdirAll("/home/user", 0o755))
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, BaseDir: "/base", UseTrash: true, FS: fsys}
+		collectEvents(sut)
+
+		require.NoError(t, fsys.WriteFile("/var", nil, 0o644))
+		sut.AuditLog = AuditLog{Path: "/var/log/audit.jsonl"}
+		trash := sut.Trash()
+		purged, err := trash.Empty()
+		assert.Error(t, err)
+		assert.Empty(t, purged)
+		items, err := trash.List()
+		require.NoError(t, err)
+		assert.Len(t, items, 1)
+	})
+
+	t.Run("red case - counters skip entries the audit log refused", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/var", nil, 0o644))
+		sut := &Wiper{
+			WipeOutPattern: []string{`\.orig$`},
+			BaseDir:        "/base",
+			AuditLog:       AuditLog{Path: "/var/log/audit.jsonl"},
+			FS:             fsys,
+		}
+		collectEvents(sut)
+		assert.Zero(t, sut.WipedFiles)
+	})
+}
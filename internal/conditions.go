@@ -0,0 +1,117 @@
+package wiper
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// Conditions on the neighbourhood of an entry. All values are lists of glob
+// patterns matched against entry names with filepath.Match.
+type Conditions struct {
+	// RequiresSibling needs one match next to the entry.
+	RequiresSibling []string `json:"requires_sibling,omitempty" mapstructure:"requires_sibling" yaml:"requires_sibling"`
+	// UnlessSibling forbids any match next to the entry.
+	UnlessSibling []string `json:"unless_sibling,omitempty" mapstructure:"unless_sibling" yaml:"unless_sibling"`
+	// RequiresChild needs one match inside the directory.
+	RequiresChild []string `json:"requires_child,omitempty" mapstructure:"requires_child" yaml:"requires_child"`
+	// UnlessChild forbids any match inside the directory.
+	UnlessChild []string `json:"unless_child,omitempty" mapstructure:"unless_child" yaml:"unless_child"`
+	// UnlessParentContains forbids any match in the directories from the
+	// parent of the entry up to base_dir.
+	UnlessParentContains []string `json:"unless_parent_contains,omitempty" mapstructure:"unless_parent_contains" yaml:"unless_parent_contains"`
+}
+
+func (c Conditions) validate(isDir bool) error {
+	if !isDir && (len(c.RequiresChild) > 0 || len(c.UnlessChild) > 0) {
+		return fmt.Errorf("requires_child and unless_child only apply to directories")
+	}
+	for _, patterns := range [][]string{c.RequiresSibling, c.UnlessSibling, c.RequiresChild, c.UnlessChild, c.UnlessParentContains} {
+		for _, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid glob %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matches checks the conditions against the entries around e.
+func (c Conditions) matches(e *entry) (bool, error) {
+	parent := filepath.Dir(e.path)
+	checks := []struct {
+		dir      string
+		patterns []string
+		want     bool
+	}{
+		{dir: parent, patterns: c.RequiresSibling, want: true},
+		{dir: parent, patterns: c.UnlessSibling, want: false},
+		{dir: e.path, patterns: c.RequiresChild, want: true},
+		{dir: e.path, patterns: c.UnlessChild, want: false},
+	}
+	for _, check := range checks {
+		if len(check.patterns) == 0 {
+			continue
+		}
+		found, err := e.dirContains(check.dir, check.patterns)
+		if err != nil || found != check.want {
+			return false, err
+		}
+	}
+
+	if len(c.UnlessParentContains) == 0 {
+		return true, nil
+	}
+	for _, dir := range e.parents() {
+		found, err := e.dirContains(dir, c.UnlessParentContains)
+		if err != nil || found {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// dirContains reports whether dir has an entry other than e matching one of
+// patterns. Directory listings are cached on e.
+func (e *entry) dirContains(dir string, patterns []string) (bool, error) {
+	names, ok := e.listings[dir]
+	if !ok {
+		entries, err := e.fsys.ReadDir(dir)
+		if err != nil {
+			return false, err
+		}
+		names = make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if e.listings == nil {
+			e.listings = map[string][]string{}
+		}
+		e.listings[dir] = names
+	}
+
+	for _, name := range names {
+		if dir == filepath.Dir(e.path) && name == e.name {
+			continue
+		}
+		if slices.ContainsFunc(patterns, func(pattern string) bool {
+			matched, _ := filepath.Match(pattern, name)
+			return matched
+		}) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parents returns the directories from the parent of e up to its base
+// directory, or up to the root if it has none.
+func (e *entry) parents() []string {
+	dirs := []string{}
+	for dir := filepath.Dir(e.path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == e.base || dir == filepath.Dir(dir) {
+			return dirs
+		}
+	}
+}
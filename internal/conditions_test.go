@@ -0,0 +1,110 @@
+package wiper
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditions(t *testing.T) {
+	files := map[string]string{
+		"/base/site/package.json":        "",
+		"/base/site/dist/":               "",
+		"/base/pinned/package.json":      "",
+		"/base/pinned/dist/.wiper-keep":  "",
+		"/base/vendored/package.json":    "",
+		"/base/vendored/VENDORED":        "",
+		"/base/vendored/dist/":           "",
+		"/base/archive/.keep":            "",
+		"/base/archive/old/package.json": "",
+		"/base/archive/old/dist/":        "",
+	}
+
+	t.Run("green case - dist is wiped unless it is protected", func(t *testing.T) {
+		fsys := memTree(t, files)
+		sut := &Wiper{
+			Rules: []Rule{{
+				Name:  "dist",
+				Names: []string{"dist"},
+				Dirs:  true,
+				Conditions: Conditions{
+					RequiresSibling:      []string{"package.json"},
+					UnlessSibling:        []string{"VENDORED"},
+					UnlessChild:          []string{".wiper-keep"},
+					UnlessParentContains: []string{".keep"},
+				},
+			}},
+			BaseDir: "/base",
+			FS:      fsys,
+		}
+
+		wiped := eventsOfType(collectEvents(sut), EventWiped)
+		require.Len(t, wiped, 1)
+		assert.Equal(t, "/base/site/dist", wiped[0].Path)
+	})
+
+	t.Run("requires_child", func(t *testing.T) {
+		rule := Rule{Names: []string{"dist"}, Dirs: true, Conditions: Conditions{RequiresChild: []string{".wiper-*"}}}
+		require.NoError(t, rule.compile())
+		fsys := memTree(t, files)
+
+		matched, err := rule.matches(newEntry(fsys, "/base/pinned/dist", true))
+		require.NoError(t, err)
+		assert.True(t, matched)
+		matched, err = rule.matches(newEntry(fsys, "/base/site/dist", true))
+		require.NoError(t, err)
+		assert.False(t, matched)
+	})
+
+	t.Run("an entry is not its own sibling", func(t *testing.T) {
+		rule := Rule{Names: []string{"package.json"}, Conditions: Conditions{RequiresSibling: []string{"*.json"}}}
+		require.NoError(t, rule.compile())
+
+		matched, err := rule.matches(newEntry(memTree(t, files), "/base/site/package.json", false))
+		require.NoError(t, err)
+		assert.False(t, matched)
+	})
+
+	t.Run("unless_parent_contains stops at base_dir", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/.keep", nil, 0o644))
+		sut := &Wiper{BaseDir: "/base", FS: fsys}
+		conditions := Conditions{UnlessParentContains: []string{".keep"}}
+
+		matched, err := conditions.matches(sut.newEntry("/base/site/dist", true, nil))
+		require.NoError(t, err)
+		assert.True(t, matched)
+		matched, err = conditions.matches(newEntry(fsys, "/base/site/dist", true))
+		require.NoError(t, err)
+		assert.False(t, matched)
+	})
+
+	t.Run("conditions are read from the config", func(t *testing.T) {
+		viper.Reset()
+		t.Cleanup(viper.Reset)
+		viper.Set("rules", []map[string]any{{
+			"names":            []string{"dist"},
+			"dirs":             true,
+			"requires_sibling": "package.json",
+			"unless_child":     []string{".wiper-keep"},
+		}})
+		require.NoError(t, refreshInstanceFromViper())
+
+		rules := GetInstance().Rules
+		require.Len(t, rules, 1)
+		assert.Equal(t, []string{"package.json"}, rules[0].RequiresSibling)
+		assert.Equal(t, []string{".wiper-keep"}, rules[0].UnlessChild)
+	})
+
+	t.Run("red case - invalid conditions", func(t *testing.T) {
+		for _, rule := range []Rule{
+			{Names: []string{"x"}, Conditions: Conditions{RequiresChild: []string{"y"}}},
+			{Names: []string{"x"}, Conditions: Conditions{UnlessSibling: []string{"[x"}}},
+		} {
+			sut := Wiper{Rules: []Rule{rule}}
+			assert.Error(t, sut.Validate(), rule)
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package wiper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// sniffLen is the number of bytes MIME detection looks at.
+	sniffLen = 512
+	// defaultContentMaxBytes is how much of a file content_pattern searches
+	// unless the rule sets content_max_bytes.
+	defaultContentMaxBytes = 64 << 10
+	// maxContentBytes caps content_max_bytes so content rules stay cheap on
+	// large trees.
+	maxContentBytes = 1 << 20
+)
+
+// entry is a directory entry being matched against the rules. Its content and
+// the listings of the directories around it are only read if a rule asks for
+// them, and then at most once.
+type entry struct {
+	fsys     FileSystem
+	base     string
+	path     string
+	name     string
+	isDir    bool
+	info     fs.FileInfo
+	head     []byte
+	read     int64
+	listings map[string][]string
+	scope    *scope
+}
+
+func newEntry(fsys FileSystem, target string, isDir bool) *entry {
+	return &entry{fsys: fsys, path: target, name: path.Base(target), isDir: isDir}
+}
+
+// newEntry returns the entry for target below BaseDir in the scope sc.
+func (w *Wiper) newEntry(target string, isDir bool, sc *scope) *entry {
+	e := newEntry(w.fs(), target, isDir)
+	e.base = filepath.Clean(w.BaseDir)
+	e.scope = sc
+	return e
+}
+
+// stat returns the Lstat result of the entry, which is only read once.
+func (e *entry) stat() (fs.FileInfo, error) {
+	if e.info == nil {
+		info, err := e.fsys.Lstat(e.path)
+		if err != nil {
+			return nil, err
+		}
+		e.info = info
+	}
+	return e.info, nil
+}
+
+// content returns up to limit bytes from the start of the entry. Only regular
+// files are read, anything else has no content.
+func (e *entry) content(limit int64) ([]byte, error) {
+	if limit <= e.read {
+		return e.head[:min(limit, int64(len(e.head)))], nil
+	}
+	if e.isDir {
+		return nil, nil
+	}
+	info, err := e.stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		e.read = limit
+		return nil, nil
+	}
+
+	file, err := e.fsys.Open(e.path)
+	if err != nil {
+		return nil, err
+	}
+	head, err := io.ReadAll(io.LimitReader(file, limit))
+	if err = errors.Join(err, file.Close()); err != nil {
+		return nil, err
+	}
+	e.head, e.read = head, limit
+	return e.head, nil
+}
+
+// mimeType returns the MIME type of the entry without parameters, or an empty
+// string if it has no content.
+func (e *entry) mimeType() (string, error) {
+	head, err := e.content(sniffLen)
+	if err != nil || len(head) == 0 {
+		return "", err
+	}
+	return detectMimeType(head), nil
+}
+
+// detectMimeType knows a few binary formats net/http does not and falls back
+// to http.DetectContentType for everything else.
+func detectMimeType(head []byte) string {
+	for _, magic := range magics {
+		if bytes.HasPrefix(head, magic.prefix) {
+			if magic.detect != nil {
+				return magic.detect(head)
+			}
+			return magic.mimeType
+		}
+	}
+	mimeType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return mimeType
+}
+
+var magics = []struct {
+	prefix   []byte
+	mimeType string
+	detect   func(head []byte) string
+}{
+	{prefix: []byte("\x7fELF"), detect: elfMimeType},
+	{prefix: []byte("SQLite format 3\x00"), mimeType: "application/vnd.sqlite3"},
+	{prefix: []byte("\x28\xb5\x2f\xfd"), mimeType: "application/zstd"},
+	{prefix: []byte("\xfd7zXZ\x00"), mimeType: "application/x-xz"},
+	{prefix: []byte("BZh"), mimeType: "application/x-bzip2"},
+	{prefix: []byte("7z\xbc\xaf\x27\x1c"), mimeType: "application/x-7z-compressed"},
+}
+
+// elfMimeType tells core dumps, executables, shared libraries and object
+// files apart by the e_type field of the ELF header.
+func elfMimeType(head []byte) string {
+	if len(head) < 18 {
+		return "application/x-elf"
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if head[5] == 2 {
+		order = binary.BigEndian
+	}
+	switch order.Uint16(head[16:18]) {
+	case 1:
+		return "application/x-object"
+	case 2:
+		return "application/x-executable"
+	case 3:
+		return "application/x-sharedlib"
+	case 4:
+		return "application/x-coredump"
+	}
+	return "application/x-elf"
+}
+
+// matchMimeType reports whether mimeType matches pattern, which is either a
+// full type like "text/plain" or a wildcard like "image/*".
+func matchMimeType(pattern, mimeType string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mimeType, prefix+"/")
+	}
+	return pattern == mimeType
+}
+
+// contentMaxBytes returns how much of a file content_pattern searches.
+func (r *Rule) contentMaxBytes() (int64, error) {
+	if r.ContentMaxBytes == "" {
+		return defaultContentMaxBytes, nil
+	}
+	size, err := ParseSize(r.ContentMaxBytes)
+	if err != nil {
+		return 0, fmt.Errorf("content_max_bytes: %w", err)
+	}
+	if size <= 0 || size > maxContentBytes {
+		return 0, fmt.Errorf("content_max_bytes must be between 1 and %s, got %s", FormatSize(maxContentBytes), r.ContentMaxBytes)
+	}
+	return size, nil
+}
+
+// hasContentConditions reports whether the rule needs to read files.
+func (r *Rule) hasContentConditions() bool {
+	return len(r.MimeTypes) > 0 || r.ContentPattern != ""
+}
+
+// matchesContent checks the mime_types and content_pattern conditions.
+func (r *Rule) matchesContent(e *entry) (bool, error) {
+	if len(r.MimeTypes) > 0 {
+		mimeType, err := e.mimeType()
+		if err != nil || mimeType == "" {
+			return false, err
+		}
+		found := false
+		for _, pattern := range r.MimeTypes {
+			found = found || matchMimeType(pattern, mimeType)
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	if r.contentPattern != nil {
+		head, err := e.content(r.contentLimit)
+		if err != nil {
+			return false, err
+		}
+		return r.contentPattern.Match(head), nil
+	}
+	return true, nil
+}
@@ -0,0 +1,128 @@
+package wiper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// elfHeader returns the start of a little endian ELF file of the given type.
+func elfHeader(elfType byte) []byte {
+	header := make([]byte, 64)
+	copy(header, "\x7fELF\x02\x01\x01")
+	header[16] = elfType
+	return header
+}
+
+func TestDetectMimeType(t *testing.T) {
+	tests := []struct {
+		name     string
+		head     []byte
+		expected string
+	}{
+		{name: "core dump", head: elfHeader(4), expected: "application/x-coredump"},
+		{name: "executable", head: elfHeader(2), expected: "application/x-executable"},
+		{name: "sqlite", head: []byte("SQLite format 3\x00..."), expected: "application/vnd.sqlite3"},
+		{name: "png", head: []byte("\x89PNG\r\n\x1a\n"), expected: "image/png"},
+		{name: "text", head: []byte("<<<<<<< HEAD\n"), expected: "text/plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, detectMimeType(tt.head))
+		})
+	}
+
+	assert.True(t, matchMimeType("image/*", "image/png"))
+	assert.False(t, matchMimeType("image/*", "imagery/png"))
+	assert.False(t, matchMimeType("text/plain", "text/html"))
+}
+
+func TestContentRules(t *testing.T) {
+	files := map[string]string{
+		"/base/core/":         "",
+		"/base/crash":         string(elfHeader(4)),
+		"/base/tool":          string(elfHeader(2)),
+		"/base/merged.go.bak": "package x\n<<<<<<< HEAD\n",
+		"/base/clean.go.bak":  "package x\n",
+	}
+
+	t.Run("green case - core dumps are wiped regardless of name", func(t *testing.T) {
+		sut := &Wiper{
+			Rules:   []Rule{{Name: "cores", MimeTypes: []string{"application/x-coredump"}}},
+			BaseDir: "/base",
+			FS:      memTree(t, files),
+		}
+
+		wiped := eventsOfType(collectEvents(sut), EventWiped)
+		require.Len(t, wiped, 1)
+		assert.Equal(t, "/base/crash", wiped[0].Path)
+		assert.Equal(t, "cores", wiped[0].Rule)
+	})
+
+	t.Run("green case - name and content must both match", func(t *testing.T) {
+		sut := &Wiper{
+			Rules:   []Rule{{Patterns: []string{`\.bak$`}, ContentPattern: `(?m)^<<<<<<< `}},
+			BaseDir: "/base",
+			FS:      memTree(t, files),
+		}
+
+		wiped := eventsOfType(collectEvents(sut), EventWiped)
+		require.Len(t, wiped, 1)
+		assert.Equal(t, "/base/merged.go.bak", wiped[0].Path)
+	})
+
+	t.Run("content_pattern only searches content_max_bytes", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base", 0o755))
+		content := append(bytes.Repeat([]byte("x"), 2048), []byte("SECRET")...)
+		require.NoError(t, fsys.WriteFile("/base/big.txt", content, 0o644))
+		rule := Rule{ContentPattern: "SECRET", ContentMaxBytes: "1KiB"}
+		require.NoError(t, rule.compile())
+
+		matched, err := rule.matches(newEntry(fsys, "/base/big.txt", false))
+		require.NoError(t, err)
+		assert.False(t, matched)
+
+		rule = Rule{ContentPattern: "SECRET", ContentMaxBytes: "4KiB"}
+		require.NoError(t, rule.compile())
+		matched, err = rule.matches(newEntry(fsys, "/base/big.txt", false))
+		require.NoError(t, err)
+		assert.True(t, matched)
+	})
+
+	t.Run("content is read once per entry", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/file", []byte("hello"), 0o644))
+		e := newEntry(fsys, "/base/file", false)
+
+		head, err := e.content(sniffLen)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(head))
+		require.NoError(t, fsys.Remove("/base/file"))
+		head, err = e.content(3)
+		require.NoError(t, err)
+		assert.Equal(t, "hel", string(head))
+	})
+
+	t.Run("red case - unreadable content is reported", func(t *testing.T) {
+		sut := &Wiper{Rules: []Rule{{MimeTypes: []string{"text/plain"}}}, FS: NewMemFileSystem()}
+		rule, err := sut.matchingRule(newEntry(sut.fs(), "/missing", false))
+		assert.Nil(t, rule)
+		assert.Error(t, err)
+	})
+
+	t.Run("red case - invalid content conditions", func(t *testing.T) {
+		for _, rule := range []Rule{
+			{ContentPattern: "("},
+			{ContentPattern: "x", ContentMaxBytes: "1GB"},
+			{ContentPattern: "x", ContentMaxBytes: "lots"},
+			{MimeTypes: []string{"text/plain"}, Dirs: true},
+		} {
+			sut := Wiper{Rules: []Rule{rule}}
+			assert.Error(t, sut.Validate(), rule)
+		}
+	})
+}
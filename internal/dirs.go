@@ -0,0 +1,24 @@
+package wiper
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// dataDir returns $XDG_DATA_HOME/wiper, falling back to ~/.local/share/wiper.
+func dataDir() string {
+	return xdgDir("XDG_DATA_HOME", ".local", "share")
+}
+
+// stateDir returns $XDG_STATE_HOME/wiper, falling back to ~/.local/state/wiper.
+func stateDir() string {
+	return xdgDir("XDG_STATE_HOME", ".local", "state")
+}
+
+func xdgDir(env string, fallback ...string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return filepath.Join(dir, "wiper")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(append(append([]string{home}, fallback...), "wiper")...)
+}
@@ -0,0 +1,272 @@
+package wiper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Keep policies of the dupes command
+const (
+	KeepOldest       = "oldest"
+	KeepNewest       = "newest"
+	KeepShortestPath = "shortest_path"
+	KeepPriority     = "priority"
+)
+
+var keepPolicies = []string{KeepOldest, KeepNewest, KeepShortestPath, KeepPriority}
+
+// partialHashSize is how much of each file is hashed before files of the same
+// size are hashed in full.
+const partialHashSize = 4 << 10
+
+// dupesRule is the rule name reported for removed duplicates.
+const dupesRule = "dupes"
+
+// Dupes configures duplicate detection.
+type Dupes struct {
+	Keep     string   `json:"keep,omitempty" mapstructure:"keep" yaml:"keep"`
+	Priority []string `json:"priority,omitempty" mapstructure:"priority" yaml:"priority"`
+	MinSize  string   `json:"min_size,omitempty" mapstructure:"min_size" yaml:"min_size"`
+}
+
+func (d Dupes) keep() string {
+	if d.Keep == "" {
+		return KeepOldest
+	}
+	return d.Keep
+}
+
+func (d Dupes) minSize() (int64, error) {
+	if d.MinSize == "" {
+		return 1, nil
+	}
+	size, err := ParseSize(d.MinSize)
+	if err != nil {
+		return 0, fmt.Errorf("dupes.min_size: %w", err)
+	}
+	return max(size, 1), nil
+}
+
+func (d Dupes) validate() error {
+	if !slices.Contains(keepPolicies, d.keep()) {
+		return fmt.Errorf("unknown dupes.keep %q, expected one of %v", d.Keep, keepPolicies)
+	}
+	if d.keep() == KeepPriority && len(d.Priority) == 0 {
+		return fmt.Errorf("dupes.keep %q requires dupes.priority to be set", KeepPriority)
+	}
+	_, err := d.minSize()
+	return err
+}
+
+// DuplicateFile is a file with at least one identical copy.
+type DuplicateFile struct {
+	Path    string
+	ModTime time.Time
+	scope   *scope
+	info    fs.FileInfo
+}
+
+// DuplicateGroup is a set of files with identical content. Keep is the copy
+// chosen by the keep policy, Remove holds the others.
+type DuplicateGroup struct {
+	Size   int64
+	Hash   string
+	Keep   DuplicateFile
+	Remove []DuplicateFile
+}
+
+// FindDuplicates walks BaseDir and groups regular files with identical
+// content. Files are bucketed by size first, then by a hash of their first
+// bytes and only then hashed in full. Hard links to a file already found are
+// left out. It skips the same entries as WipeFiles, and .git directories.
+// Entries which can't be read are reported on events and left out.
+func (w *Wiper) FindDuplicates(events chan Event) ([]DuplicateGroup, error) {
+	minSize, err := w.Dupes.minSize()
+	if err != nil {
+		return nil, err
+	}
+
+	bySize := map[int64][]DuplicateFile{}
+	w.collectFiles(w.BaseDir, w.rootScope(w.BaseDir, events), minSize, bySize, events)
+
+	groups := []DuplicateGroup{}
+	for size, files := range bySize {
+		if len(files) < 2 {
+			continue
+		}
+		for partial, candidates := range w.bucketByHash(files, partialHashSize, events) {
+			if len(candidates) < 2 {
+				continue
+			}
+			byFull := map[string][]DuplicateFile{partial: candidates}
+			if size > partialHashSize {
+				byFull = w.bucketByHash(candidates, -1, events)
+			}
+			for hash, copies := range byFull {
+				if len(copies) > 1 {
+					groups = append(groups, w.Dupes.group(size, hash, copies))
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(groups, func(a, b DuplicateGroup) int {
+		return strings.Compare(a.Keep.Path, b.Keep.Path)
+	})
+	return groups, nil
+}
+
+// collectFiles buckets the files below dir by size. It walks the tree like
+// wipeDir and skips the same entries, and .git directories as well. parent
+// is the scope of the closest parent directory with a .wiperignore or
+// .wiper.yaml.
+func (w *Wiper) collectFiles(dir string, parent *scope, minSize int64, bySize map[int64][]DuplicateFile, events chan Event) {
+	entries, sc, ok := w.listDir(context.Background(), dir, parent, events)
+	if !ok {
+		return
+	}
+
+	for _, entry := range entries {
+		target := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			if reason := w.skipDir(sc, target); reason != "" {
+				events <- Event{Type: EventSkipped, Path: target, IsDir: true, Reason: reason}
+				continue
+			}
+			// objects of a repository are often identical, removing them
+			// would break it
+			if entry.Name() != gitDirName {
+				w.collectFiles(target, sc, minSize, bySize, events)
+			}
+			continue
+		}
+
+		w.mu.Lock()
+		w.InspectedFiles++
+		w.mu.Unlock()
+		if reason := w.skipFile(sc, target); reason != "" {
+			events <- Event{Type: EventSkipped, Path: target, Reason: reason}
+			continue
+		}
+		info, err := w.fs().Lstat(target)
+		if err != nil {
+			events <- errorEvent("stat", target, false, err)
+			continue
+		}
+		if w.OnlyOwnedByCurrentUser {
+			if uid, _, ok := fileOwner(info); !ok || uid != currentUID() {
+				continue
+			}
+		}
+		if !info.Mode().IsRegular() || info.Size() < minSize {
+			continue
+		}
+		// a hard link is another name of a file already collected, not a
+		// copy of it, wiping it would destroy the kept content as well
+		if slices.ContainsFunc(bySize[info.Size()], func(file DuplicateFile) bool { return os.SameFile(file.info, info) }) {
+			continue
+		}
+		bySize[info.Size()] = append(bySize[info.Size()], DuplicateFile{Path: target, ModTime: info.ModTime(), scope: sc, info: info})
+	}
+}
+
+// bucketByHash groups files by the sha256 of their first limit bytes, or of
+// their whole content if limit is negative. Files which can't be read are
+// reported and left out.
+func (w *Wiper) bucketByHash(files []DuplicateFile, limit int64, events chan Event) map[string][]DuplicateFile {
+	buckets := map[string][]DuplicateFile{}
+	for _, file := range files {
+		hash, err := hashFile(w.fs(), file.Path, limit)
+		if err != nil {
+			events <- errorEvent("hash", file.Path, false, err)
+			continue
+		}
+		buckets[hash] = append(buckets[hash], file)
+	}
+	return buckets
+}
+
+func hashFile(fsys FileSystem, name string, limit int64) (string, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	var reader io.Reader = file
+	if limit >= 0 {
+		reader = io.LimitReader(file, limit)
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, reader)
+	if err = errors.Join(err, file.Close()); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// group sorts copies by the keep policy and keeps the first one.
+func (d Dupes) group(size int64, hash string, copies []DuplicateFile) DuplicateGroup {
+	slices.SortFunc(copies, func(a, b DuplicateFile) int {
+		var order int
+		switch d.keep() {
+		case KeepNewest:
+			order = b.ModTime.Compare(a.ModTime)
+		case KeepShortestPath:
+			order = len(a.Path) - len(b.Path)
+		case KeepPriority:
+			order = d.priority(a.Path) - d.priority(b.Path)
+			if order == 0 {
+				order = a.ModTime.Compare(b.ModTime)
+			}
+		default:
+			order = a.ModTime.Compare(b.ModTime)
+		}
+		if order == 0 {
+			order = strings.Compare(a.Path, b.Path)
+		}
+		return order
+	})
+	return DuplicateGroup{Size: size, Hash: hash, Keep: copies[0], Remove: copies[1:]}
+}
+
+// priority returns the index of the first priority directory containing
+// path, or len(Priority) if there is none.
+func (d Dupes) priority(path string) int {
+	for i, dir := range d.Priority {
+		dir = filepath.Clean(dir)
+		if path == dir || isWithin(dir, path) {
+			return i
+		}
+	}
+	return len(d.Priority)
+}
+
+// WipeDuplicates applies the configured action to every copy in groups which
+// is not kept, unless it is protected like the matches of WipeFiles. Like
+// WipeFiles it reports each step on events and closes it when done.
+func (w *Wiper) WipeDuplicates(groups []DuplicateGroup, events chan Event) {
+	defer w.finishRun(events)
+	ctx, span := tracer.Start(context.Background(), "WipeDuplicates")
+	defer span.End()
+
+	trash := initTrash(w)
+	rule := &Rule{Name: dupesRule}
+	for _, group := range groups {
+		for _, file := range group.Remove {
+			if reason := w.protected(file.scope, file.Path, false); reason != "" {
+				events <- Event{Type: EventSkipped, Path: file.Path, Rule: dupesRule, Reason: reason}
+				continue
+			}
+			w.wipe(ctx, file.Path, trash, rule, false, events)
+		}
+	}
+}
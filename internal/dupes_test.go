@@ -0,0 +1,264 @@
+package wiper
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// findDuplicates runs FindDuplicates and returns the events it reported.
+func findDuplicates(t *testing.T, sut *Wiper) ([]DuplicateGroup, []Event) {
+	t.Helper()
+	events := make(chan Event)
+	collected := make(chan []Event)
+	go func() {
+		all := []Event{}
+		for event := range events {
+			all = append(all, event)
+		}
+		collected <- all
+	}()
+	groups, err := sut.FindDuplicates(events)
+	close(events)
+	require.NoError(t, err)
+	return groups, <-collected
+}
+
+// failingReadDirFS fails listing dir.
+type failingReadDirFS struct {
+	*MemFileSystem
+	dir string
+}
+
+func (f failingReadDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == f.dir {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return f.MemFileSystem.ReadDir(name)
+}
+
+func TestDupes(t *testing.T) {
+	large := strings.Repeat("a", 3*partialHashSize)
+
+	files := map[string]string{
+		"/home/user/":                       "",
+		"/base/Downloads/report (1).pdf":    "report",
+		"/base/Documents/report.pdf":        "report",
+		"/base/Downloads/nested/report.pdf": "report",
+		"/base/Downloads/other.pdf":         "rePORT",
+		"/base/Downloads/big.iso":           large,
+		"/base/Downloads/big (1).iso":       large,
+		"/base/Downloads/big-variant.iso":   large[:len(large)-1] + "b",
+		"/base/Downloads/empty":             "",
+		"/base/Downloads/empty (1)":         "",
+		"/base/Library/report.pdf":          "report",
+	}
+
+	t.Run("green case - identical files are grouped", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		sut := &Wiper{BaseDir: "/base", ExcludeDir: []string{"Library"}, Dupes: Dupes{Keep: KeepShortestPath}, FS: memTree(t, files)}
+
+		groups, _ := findDuplicates(t, sut)
+		require.Len(t, groups, 2)
+
+		assert.Equal(t, "/base/Documents/report.pdf", groups[0].Keep.Path)
+		require.Len(t, groups[0].Remove, 2)
+		assert.Equal(t, "/base/Downloads/report (1).pdf", groups[0].Remove[0].Path)
+		assert.Equal(t, "/base/Downloads/nested/report.pdf", groups[0].Remove[1].Path)
+
+		assert.Equal(t, "/base/Downloads/big.iso", groups[1].Keep.Path)
+		assert.Equal(t, int64(len(large)), groups[1].Size)
+		require.Len(t, groups[1].Remove, 1)
+		assert.Equal(t, "/base/Downloads/big (1).iso", groups[1].Remove[0].Path)
+	})
+
+	t.Run("keep policies", func(t *testing.T) {
+		now := time.Now()
+		copies := func() []DuplicateFile {
+			return []DuplicateFile{
+				{Path: "/base/Downloads/a/report.pdf", ModTime: now.Add(-time.Hour)},
+				{Path: "/base/Documents/report.pdf", ModTime: now},
+				{Path: "/base/x.pdf", ModTime: now.Add(-2 * time.Hour)},
+			}
+		}
+
+		assert.Equal(t, "/base/x.pdf", Dupes{}.group(1, "h", copies()).Keep.Path)
+		assert.Equal(t, "/base/Documents/report.pdf", Dupes{Keep: KeepNewest}.group(1, "h", copies()).Keep.Path)
+		assert.Equal(t, "/base/x.pdf", Dupes{Keep: KeepShortestPath}.group(1, "h", copies()).Keep.Path)
+
+		prioritized := Dupes{Keep: KeepPriority, Priority: []string{"/base/Documents", "/base/Downloads"}}.group(1, "h", copies())
+		assert.Equal(t, "/base/Documents/report.pdf", prioritized.Keep.Path)
+		assert.Equal(t, "/base/Downloads/a/report.pdf", prioritized.Remove[0].Path)
+		assert.Equal(t, "/base/x.pdf", prioritized.Remove[1].Path)
+	})
+
+	t.Run("WipeDuplicates removes all but the kept copies", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := memTree(t, files)
+		sut := &Wiper{BaseDir: "/base", ExcludeDir: []string{"Library"}, FS: fsys}
+		groups, _ := findDuplicates(t, sut)
+
+		events := make(chan Event)
+		go sut.WipeDuplicates(groups, events)
+		wiped := []Event{}
+		for event := range events {
+			require.NotEqual(t, EventError, event.Type, event.String())
+			if event.Type == EventWiped {
+				wiped = append(wiped, event)
+			}
+		}
+
+		assert.Len(t, wiped, 3)
+		assert.Equal(t, dupesRule, wiped[0].Rule)
+		for _, group := range groups {
+			assert.True(t, existsOn(fsys, group.Keep.Path))
+			for _, file := range group.Remove {
+				assert.False(t, existsOn(fsys, file.Path))
+			}
+		}
+		assert.True(t, existsOn(fsys, "/base/Downloads/empty (1)"))
+	})
+
+	t.Run("red case - hard links are no duplicates", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		base := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(base, "a.pdf"), []byte("report"), 0o644))
+		require.NoError(t, os.Link(filepath.Join(base, "a.pdf"), filepath.Join(base, "b.pdf")))
+		require.NoError(t, os.WriteFile(filepath.Join(base, "c.pdf"), []byte("report"), 0o644))
+		sut := &Wiper{BaseDir: base, Action: ActionShred, Dupes: Dupes{Keep: KeepShortestPath}, FS: OSFileSystem{}}
+
+		groups, _ := findDuplicates(t, sut)
+		require.Len(t, groups, 1)
+		require.Len(t, groups[0].Remove, 1)
+		assert.NotEqual(t, "b.pdf", filepath.Base(groups[0].Remove[0].Path))
+
+		events := make(chan Event)
+		go sut.WipeDuplicates(groups, events)
+		for range events {
+		}
+		content, err := os.ReadFile(groups[0].Keep.Path)
+		require.NoError(t, err)
+		assert.Equal(t, "report", string(content))
+	})
+
+	t.Run("red case - duplicates tracked by git are kept with respect_git", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base/repo/.git", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/repo/.git/index", gitIndex(2, "report.pdf"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/repo/report.pdf", []byte("report"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/report.pdf", []byte("report"), 0o644))
+		sut := &Wiper{BaseDir: "/base", RespectGit: true, Dupes: Dupes{Keep: KeepShortestPath}, FS: fsys}
+
+		groups, _ := findDuplicates(t, sut)
+		require.Len(t, groups, 1)
+		require.Len(t, groups[0].Remove, 1)
+		assert.Equal(t, "/base/repo/report.pdf", groups[0].Remove[0].Path)
+
+		events := make(chan Event)
+		go sut.WipeDuplicates(groups, events)
+		collected := []Event{}
+		for event := range events {
+			collected = append(collected, event)
+		}
+		assert.Empty(t, eventsOfType(collected, EventWiped))
+		skipped := eventsOfType(collected, EventSkipped)
+		require.Len(t, skipped, 1)
+		assert.Equal(t, "tracked by git", skipped[0].Reason)
+		assert.True(t, existsOn(fsys, "/base/repo/report.pdf"))
+	})
+
+	t.Run("only_owned_by_current_user ignores files of other users", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.Chown("/base/Downloads/big (1).iso", 4242, 4242))
+		sut := &Wiper{BaseDir: "/base", ExcludeDir: []string{"Library"}, OnlyOwnedByCurrentUser: true, FS: fsys}
+
+		groups, _ := findDuplicates(t, sut)
+		require.Len(t, groups, 1)
+		assert.Equal(t, int64(len("report")), groups[0].Size)
+	})
+
+	t.Run("git internals and wiper's own directories are skipped", func(t *testing.T) {
+		t.Setenv("HOME", "/base")
+		t.Setenv("XDG_STATE_HOME", "")
+		fsys := NewMemFileSystem()
+		for _, dir := range []string{"/base/repo/.git/refs/heads", "/base/.Trash", "/base/quarantine/run", "/base/.local/share/wiper/archives", "/base/.local/state/wiper/history"} {
+			require.NoError(t, fsys.MkdirAll(dir, 0o755))
+		}
+		for _, file := range []string{
+			"/base/repo/.git/refs/heads/master", "/base/repo/.git/refs/heads/feature",
+			"/base/.Trash/a", "/base/.Trash/b",
+			"/base/quarantine/run/a", "/base/quarantine/run/b",
+			"/base/.local/share/wiper/archives/a", "/base/.local/state/wiper/history/a",
+		} {
+			require.NoError(t, fsys.WriteFile(file, []byte("0123abcd"), 0o644))
+		}
+		sut := &Wiper{BaseDir: "/base", QuarantineDir: "/base/quarantine", FS: fsys}
+
+		groups, events := findDuplicates(t, sut)
+		assert.Empty(t, groups)
+		assert.Empty(t, eventsOfType(events, EventError))
+	})
+
+	t.Run(".wiperignore files are honoured", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/base/Downloads/.wiperignore", []byte("nested/\nbig (1).iso\n"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/Documents/.wiperignore", []byte("nested/\nbig (1).iso\n"), 0o644))
+		sut := &Wiper{BaseDir: "/base", ExcludeDir: []string{"Library"}, Dupes: Dupes{Keep: KeepShortestPath}, FS: fsys}
+
+		groups, events := findDuplicates(t, sut)
+		assert.Empty(t, eventsOfType(events, EventError))
+		require.Len(t, groups, 1)
+		assert.Equal(t, "/base/Documents/report.pdf", groups[0].Keep.Path)
+		require.Len(t, groups[0].Remove, 1)
+		assert.Equal(t, "/base/Downloads/report (1).pdf", groups[0].Remove[0].Path)
+	})
+
+	t.Run("entries are skipped like by wipe runs", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/base/Downloads/.wiperignore", []byte("nested/\nbig (1).iso\n"), 0o644))
+		sut := &Wiper{BaseDir: "/base", ExcludeDir: []string{"Library"}, ExcludeFile: []string{"other.pdf"}, DryRun: true, FS: fsys}
+
+		_, events := findDuplicates(t, sut)
+		skipped := eventsOfType(events, EventSkipped)
+		assert.Len(t, skipped, 4)
+		assert.ElementsMatch(t, eventsOfType(collectEvents(sut), EventSkipped), skipped)
+	})
+
+	t.Run("red case - unreadable entries are reported and skipped", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := memTree(t, files)
+		sut := &Wiper{BaseDir: "/base", ExcludeDir: []string{"Library"}, FS: failingReadDirFS{MemFileSystem: fsys, dir: "/base/Downloads/nested"}}
+
+		groups, events := findDuplicates(t, sut)
+		errs := eventsOfType(events, EventError)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "readdir", errs[0].Op)
+		assert.Equal(t, "/base/Downloads/nested", errs[0].Path)
+		require.Len(t, groups, 2)
+		assert.Len(t, groups[0].Remove, 1)
+
+		sut.FS = failingReadFS{MemFileSystem: fsys, path: "/base/Downloads/big (1).iso"}
+		groups, events = findDuplicates(t, sut)
+		errs = eventsOfType(events, EventError)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "hash", errs[0].Op)
+		assert.Len(t, groups, 1)
+	})
+
+	t.Run("red case - invalid dupes settings", func(t *testing.T) {
+		for _, dupes := range []Dupes{{Keep: "largest"}, {Keep: KeepPriority}, {MinSize: "big"}} {
+			sut := Wiper{Dupes: dupes}
+			assert.Error(t, sut.Validate(), dupes)
+		}
+	})
+}
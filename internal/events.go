@@ -0,0 +1,148 @@
+package wiper
+
+import "fmt"
+
+// EventType identifies what happened to an entry during a wipe run.
+type EventType int
+
+const (
+	EventDirEntered EventType = iota
+	EventMatched
+	EventSkipped
+	EventWiped
+	EventTrashed
+	EventError
+	EventHook
+	EventArchived
+	EventQuarantined
+	EventShredded
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventDirEntered:
+		return "entered"
+	case EventMatched:
+		return "matched"
+	case EventSkipped:
+		return "skipped"
+	case EventWiped:
+		return "wiped"
+	case EventTrashed:
+		return "trashed"
+	case EventError:
+		return "error"
+	case EventHook:
+		return "hook"
+	case EventArchived:
+		return "archived"
+	case EventQuarantined:
+		return "quarantined"
+	case EventShredded:
+		return "shredded"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is sent by WipeFiles for every step of a run. Fields that do not
+// apply to the Type are left empty.
+type Event struct {
+	Type        EventType
+	Path        string
+	IsDir       bool
+	Rule        string // config key of the rule that matched the entry
+	Reason      string // why the entry was skipped, or a warning for shredded entries
+	Destination string // where the entry was moved to
+	Size        int64  // bytes of the wiped entry, including its content for directories
+	Op          string // operation that failed
+	Err         error
+	Hook        *HookResult // result of the on_match hook
+}
+
+func (e Event) String() string {
+	switch e.Type {
+	case EventMatched, EventWiped:
+		return fmt.Sprintf("%s %s (%s)", e.Type, e.Path, e.Rule)
+	case EventSkipped:
+		return fmt.Sprintf("%s %s: %s", e.Type, e.Path, e.Reason)
+	case EventShredded:
+		if e.Reason != "" {
+			return fmt.Sprintf("%s %s (%s): %s", e.Type, e.Path, e.Rule, e.Reason)
+		}
+		return fmt.Sprintf("%s %s (%s)", e.Type, e.Path, e.Rule)
+	case EventTrashed, EventArchived, EventQuarantined:
+		return fmt.Sprintf("%s %s -> %s (%s)", e.Type, e.Path, e.Destination, e.Rule)
+	case EventError:
+		return fmt.Sprintf("%s %s: %s", e.Op, e.Path, e.Err)
+	case EventHook:
+		return fmt.Sprintf("%s for %s", e.Hook, e.Path)
+	}
+	return fmt.Sprintf("%s %s", e.Type, e.Path)
+}
+
+// Report collects the outcome of a run from its events.
+type Report struct {
+	Wiped       []Event
+	Trashed     []Event
+	Archived    []Event
+	Quarantined []Event
+	Shredded    []Event
+	Skipped     []Event
+	Errors      []Event
+	Hooks       []*HookResult
+}
+
+func (r *Report) Add(e Event) {
+	switch e.Type {
+	case EventWiped:
+		r.Wiped = append(r.Wiped, e)
+	case EventTrashed:
+		r.Trashed = append(r.Trashed, e)
+	case EventArchived:
+		r.Archived = append(r.Archived, e)
+	case EventQuarantined:
+		r.Quarantined = append(r.Quarantined, e)
+	case EventShredded:
+		r.Shredded = append(r.Shredded, e)
+	case EventSkipped:
+		r.Skipped = append(r.Skipped, e)
+	case EventError:
+		r.Errors = append(r.Errors, e)
+	case EventHook:
+		r.AddHook(e.Hook)
+	}
+}
+
+// AddHook records the result of a hook. Nil results are ignored.
+func (r *Report) AddHook(result *HookResult) {
+	if result != nil {
+		r.Hooks = append(r.Hooks, result)
+	}
+}
+
+// FailedHooks returns the hook executions that exited with an error.
+func (r *Report) FailedHooks() []*HookResult {
+	failed := []*HookResult{}
+	for _, result := range r.Hooks {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// ShredWarnings returns the shredded entries whose content may still be
+// recoverable.
+func (r *Report) ShredWarnings() []Event {
+	warnings := []Event{}
+	for _, e := range r.Shredded {
+		if e.Reason != "" {
+			warnings = append(warnings, e)
+		}
+	}
+	return warnings
+}
+
+func errorEvent(op, path string, isDir bool, err error) Event {
+	return Event{Type: EventError, Path: path, IsDir: isDir, Op: op, Err: err}
+}
@@ -0,0 +1,151 @@
+package wiper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collectEvents(sut *Wiper) []Event {
+	events := make(chan Event)
+	go sut.WipeFiles(nil, "", events)
+
+	collected := []Event{}
+	for event := range events {
+		collected = append(collected, event)
+	}
+	return collected
+}
+
+func eventsOfType(events []Event, eventType EventType) []Event {
+	filtered := []Event{}
+	for _, event := range events {
+		if event.Type == eventType {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
+func TestWipeFilesEvents(t *testing.T) {
+	files := map[string]string{
+		"/home/user/":         "",
+		"/base/sub/file.orig": "",
+		"/base/Library/":      "",
+		"/base/keep.orig":     "",
+	}
+
+	t.Run("green case - matched and wiped entries are reported with their rule", func(t *testing.T) {
+		sut := &Wiper{
+			WipeOutPattern: []string{`.*\.orig$`},
+			ExcludeFile:    []string{"keep.orig"},
+			ExcludeDir:     []string{"Library"},
+			BaseDir:        "/base",
+			FS:             memTree(t, files),
+		}
+
+		events := collectEvents(sut)
+
+		entered := eventsOfType(events, EventDirEntered)
+		assert.Len(t, entered, 2)
+
+		matched := eventsOfType(events, EventMatched)
+		require.Len(t, matched, 1)
+		assert.Equal(t, "/base/sub/file.orig", matched[0].Path)
+		assert.Equal(t, "wipe_out_pattern", matched[0].Rule)
+
+		wiped := eventsOfType(events, EventWiped)
+		require.Len(t, wiped, 1)
+		assert.Equal(t, "/base/sub/file.orig", wiped[0].Path)
+
+		skipped := eventsOfType(events, EventSkipped)
+		require.Len(t, skipped, 2)
+		reasons := map[string]string{}
+		for _, event := range skipped {
+			reasons[event.Path] = event.Reason
+		}
+		assert.Equal(t, "exclude_dir", reasons["/base/Library"])
+		assert.Equal(t, "exclude_file", reasons["/base/keep.orig"])
+
+		assert.Empty(t, eventsOfType(events, EventError))
+	})
+
+	t.Run("trashed entries carry their destination", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		sut := &Wiper{
+			WipeOutDirs: []string{"sub"},
+			BaseDir:     "/base",
+			UseTrash:    true,
+			FS:          memTree(t, files),
+		}
+
+		events := collectEvents(sut)
+
+		trashed := eventsOfType(events, EventTrashed)
+		require.Len(t, trashed, 1)
+		assert.Equal(t, "/base/sub", trashed[0].Path)
+		assert.True(t, trashed[0].IsDir)
+		assert.Equal(t, "wipe_out_dirs", trashed[0].Rule)
+		assert.Equal(t, "/home/user/.Trash/sub", trashed[0].Destination)
+		assert.Empty(t, eventsOfType(events, EventWiped))
+	})
+}
+
+func TestReport(t *testing.T) {
+	t.Run("green case - events are sorted into the report", func(t *testing.T) {
+		sut := Report{}
+		sut.Add(Event{Type: EventDirEntered, Path: "/base"})
+		sut.Add(Event{Type: EventMatched, Path: "/base/a"})
+		sut.Add(Event{Type: EventWiped, Path: "/base/a"})
+		sut.Add(Event{Type: EventTrashed, Path: "/base/b"})
+		sut.Add(Event{Type: EventSkipped, Path: "/base/c"})
+		sut.Add(errorEvent("remove", "/base/d", false, errors.New("boom")))
+
+		assert.Len(t, sut.Wiped, 1)
+		assert.Len(t, sut.Trashed, 1)
+		assert.Len(t, sut.Skipped, 1)
+		assert.Len(t, sut.Errors, 1)
+	})
+}
+
+func TestEventString(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		expected string
+	}{
+		{
+			name:     "wiped",
+			event:    Event{Type: EventWiped, Path: "/base/a.orig", Rule: "wipe_out_pattern"},
+			expected: "wiped /base/a.orig (wipe_out_pattern)",
+		},
+		{
+			name:     "trashed",
+			event:    Event{Type: EventTrashed, Path: "/base/a", Destination: "/trash/a", Rule: "wipe_out"},
+			expected: "trashed /base/a -> /trash/a (wipe_out)",
+		},
+		{
+			name:     "skipped",
+			event:    Event{Type: EventSkipped, Path: "/base/Library", Reason: "exclude_dir"},
+			expected: "skipped /base/Library: exclude_dir",
+		},
+		{
+			name:     "error",
+			event:    errorEvent("readdir", "/base", true, errors.New("permission denied")),
+			expected: "readdir /base: permission denied",
+		},
+		{
+			name:     "entered",
+			event:    Event{Type: EventDirEntered, Path: "/base"},
+			expected: "entered /base",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.event.String())
+		})
+	}
+}
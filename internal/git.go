@@ -0,0 +1,262 @@
+package wiper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	gitDirName        = ".git"
+	gitIgnoreFileName = ".gitignore"
+)
+
+// gitRepo is a git work tree as far as wiper needs it: the paths tracked in
+// its index and its info/exclude patterns. It is read from the files in .git
+// directly, git itself is not required.
+type gitRepo struct {
+	root        string
+	tracked     map[string]bool // slash separated, relative to root
+	trackedDirs map[string]bool // directories containing tracked paths
+	exclude     *ignoreList
+	unreadable  bool // the index could not be read, everything counts as tracked
+}
+
+// openGitRepo reads the repository whose work tree is root. .git may be a
+// directory or, for worktrees and submodules, a file pointing to it.
+func openGitRepo(fsys FileSystem, root string) (*gitRepo, error) {
+	gitDir, err := resolveGitDir(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &gitRepo{root: root, tracked: map[string]bool{}, trackedDirs: map[string]bool{}}
+	index, err := readFile(fsys, filepath.Join(gitDir, "index"))
+	if err != nil && existsOn(fsys, filepath.Join(gitDir, "index")) {
+		return nil, err
+	}
+	if err == nil {
+		paths, err := parseGitIndex(index, gitHashSize(fsys, gitDir))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Join(gitDir, "index"), err)
+		}
+		for _, p := range paths {
+			p = strings.TrimSuffix(p, "/")
+			repo.tracked[p] = true
+			for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+				repo.trackedDirs[dir] = true
+			}
+		}
+	}
+
+	if exclude, err := readFile(fsys, filepath.Join(gitDir, "info", "exclude")); err == nil {
+		repo.exclude, _ = parseIgnore(bytes.NewReader(exclude))
+	}
+	return repo, nil
+}
+
+func resolveGitDir(fsys FileSystem, root string) (string, error) {
+	gitDir := filepath.Join(root, gitDirName)
+	info, err := fsys.Stat(gitDir)
+	if err != nil || info.IsDir() {
+		return gitDir, err
+	}
+
+	content, err := readFile(fsys, gitDir)
+	if err != nil {
+		return "", err
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("%s: not a gitdir file", gitDir)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	return target, nil
+}
+
+// gitHashSize returns the size of object ids, which depends on the object
+// format of the repository.
+func gitHashSize(fsys FileSystem, gitDir string) int {
+	config, err := readFile(fsys, filepath.Join(gitDir, "config"))
+	if err != nil {
+		return 20
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(config))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if found && strings.EqualFold(strings.TrimSpace(key), "objectformat") && strings.TrimSpace(value) == "sha256" {
+			return 32
+		}
+	}
+	return 20
+}
+
+// parseGitIndex returns the paths in a git index of version 2, 3 or 4.
+func parseGitIndex(data []byte, hashSize int) ([]string, error) {
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, errors.New("not a git index")
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported git index version %d", version)
+	}
+	count := binary.BigEndian.Uint32(data[8:12])
+
+	// ctime, mtime, dev, ino, mode, uid, gid and size precede the object id
+	headerSize := 40 + hashSize
+	paths := make([]string, 0, count)
+	previous := ""
+	offset := 12
+	for i := uint32(0); i < count; i++ {
+		start := offset
+		if offset+headerSize+2 > len(data) {
+			return nil, errors.New("truncated git index")
+		}
+		flags := binary.BigEndian.Uint16(data[offset+headerSize:])
+		offset += headerSize + 2
+		if version >= 3 && flags&0x4000 != 0 {
+			offset += 2
+		}
+
+		var name string
+		if version == 4 {
+			strip, n := gitVarint(data[offset:])
+			if n == 0 || strip > len(previous) {
+				return nil, errors.New("corrupt git index")
+			}
+			offset += n
+			end := bytes.IndexByte(data[offset:], 0)
+			if end < 0 {
+				return nil, errors.New("truncated git index")
+			}
+			name = previous[:len(previous)-strip] + string(data[offset:offset+end])
+			offset += end + 1
+		} else {
+			end := bytes.IndexByte(data[offset:], 0)
+			if end < 0 {
+				return nil, errors.New("truncated git index")
+			}
+			name = string(data[offset : offset+end])
+			// entries are NUL padded to a multiple of eight bytes
+			offset += end + 1
+			offset += (8 - (offset-start)%8) % 8
+		}
+		paths = append(paths, name)
+		previous = name
+	}
+	return paths, nil
+}
+
+// gitVarint decodes the offset encoding git uses for path prefixes in
+// version 4 indexes. It returns the value and the number of bytes read.
+func gitVarint(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	value := int(data[0] & 0x7f)
+	n := 1
+	for data[n-1]&0x80 != 0 {
+		if n >= len(data) {
+			return 0, 0
+		}
+		value = ((value + 1) << 7) | int(data[n]&0x7f)
+		n++
+	}
+	return value, n
+}
+
+// isTracked reports whether target, or anything below it, is in the index.
+func (g *gitRepo) isTracked(target string, isDir bool) bool {
+	rel, err := filepath.Rel(g.root, target)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	if g.unreadable {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	return g.tracked[rel] || (isDir && g.trackedDirs[rel])
+}
+
+// gitRepo returns the repository of the closest scope which has one.
+func (s *scope) gitRepo() *gitRepo {
+	for ; s != nil; s = s.parent {
+		if s.repo != nil {
+			return s.repo
+		}
+	}
+	return nil
+}
+
+// gitIgnored reports whether target is ignored by the .gitignore files of its
+// repository, either itself or because one of its parent directories is.
+func (s *scope) gitIgnored(target string, isDir bool) bool {
+	repo := s.gitRepo()
+	if repo == nil {
+		return false
+	}
+
+	type list struct {
+		dir    string
+		ignore *ignoreList
+	}
+	lists := []list{}
+	for sc := s; sc != nil; sc = sc.parent {
+		if sc.gitignore != nil {
+			lists = append([]list{{dir: sc.dir, ignore: sc.gitignore}}, lists...)
+		}
+		if sc.repo != nil {
+			break
+		}
+	}
+	if repo.exclude != nil {
+		lists = append([]list{{dir: repo.root, ignore: repo.exclude}}, lists...)
+	}
+
+	rel, err := filepath.Rel(repo.root, target)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := range parts {
+		p := filepath.Join(repo.root, filepath.Join(parts[:i+1]...))
+		pIsDir := isDir || i < len(parts)-1
+		ignored := false
+		for _, l := range lists {
+			if l.dir != repo.root && !isWithin(l.dir, p) {
+				continue
+			}
+			listRel, _ := filepath.Rel(l.dir, p)
+			if result, found := l.ignore.match(filepath.ToSlash(listRel), pIsDir); found {
+				ignored = result
+			}
+		}
+		if ignored {
+			return true
+		}
+	}
+	return false
+}
+
+// gitProtected returns why respect_git forbids wiping target, or an empty
+// string if it may be wiped.
+func (w *Wiper) gitProtected(sc *scope, target string, isDir bool) string {
+	repo := sc.gitRepo()
+	if !w.RespectGit || repo == nil {
+		return ""
+	}
+	if repo.isTracked(target, isDir) {
+		return "tracked by git"
+	}
+	if w.GitIgnoredOnly && !sc.gitIgnored(target, isDir) {
+		return "not ignored by git"
+	}
+	return ""
+}
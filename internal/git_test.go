@@ -0,0 +1,166 @@
+package wiper
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitIndex builds a git index of the given version containing paths. Version
+// 3 entries carry the extended flag, version 4 entries are prefix compressed.
+func gitIndex(version uint32, paths ...string) []byte {
+	data := []byte("DIRC")
+	data = binary.BigEndian.AppendUint32(data, version)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(paths)))
+	previous := ""
+	for _, p := range paths {
+		entry := make([]byte, 40+20)
+		flags := uint16(len(p))
+		if version == 3 {
+			flags |= 0x4000
+		}
+		entry = binary.BigEndian.AppendUint16(entry, flags)
+		if version == 3 {
+			entry = append(entry, 0, 0)
+		}
+		if version == 4 {
+			common := 0
+			for common < len(previous) && common < len(p) && previous[common] == p[common] {
+				common++
+			}
+			entry = append(entry, byte(len(previous)-common))
+			entry = append(entry, p[common:]...)
+			entry = append(entry, 0)
+		} else {
+			entry = append(entry, p...)
+			entry = append(entry, make([]byte, 8-len(entry)%8)...)
+		}
+		data = append(data, entry...)
+		previous = p
+	}
+	return data
+}
+
+func TestParseGitIndex(t *testing.T) {
+	paths := []string{"README.md", "internal/git.go", "internal/git_test.go", "main.go"}
+	for _, version := range []uint32{2, 3, 4} {
+		t.Run(fmt.Sprintf("green case - version %d", version), func(t *testing.T) {
+			parsed, err := parseGitIndex(gitIndex(version, paths...), 20)
+			require.NoError(t, err)
+			assert.Equal(t, paths, parsed)
+		})
+	}
+
+	t.Run("red case - invalid index", func(t *testing.T) {
+		_, err := parseGitIndex([]byte("PACK"), 20)
+		assert.Error(t, err)
+		_, err = parseGitIndex(gitIndex(5, "a"), 20)
+		assert.Error(t, err)
+		index := gitIndex(2, "a", "b")
+		_, err = parseGitIndex(index[:len(index)-10], 20)
+		assert.Error(t, err)
+	})
+}
+
+func TestRespectGit(t *testing.T) {
+	files := map[string]string{
+		"/base/a.orig":                "",
+		"/base/repo/.git/b.orig":      "",
+		"/base/repo/.git/index":       string(gitIndex(2, "logs/keep.log", "src/main.go", "tracked.orig")),
+		"/base/repo/tracked.orig":     "",
+		"/base/repo/untracked.orig":   "",
+		"/base/repo/src/main.go":      "",
+		"/base/repo/src/main.go.orig": "",
+		"/base/repo/src/gen/c.orig":   "",
+		"/base/repo/logs/run.log":     "",
+		"/base/repo/logs/keep.log":    "",
+	}
+
+	wipedPaths := func(events []Event) []string {
+		paths := []string{}
+		for _, event := range eventsOfType(events, EventWiped) {
+			paths = append(paths, event.Path)
+		}
+		return paths
+	}
+
+	t.Run("green case - tracked files are never wiped", func(t *testing.T) {
+		fsys := memTree(t, files)
+		sut := &Wiper{WipeOutPattern: []string{`\.(orig|log)$`}, RespectGit: true, BaseDir: "/base", FS: fsys}
+
+		events := collectEvents(sut)
+		assert.ElementsMatch(t, []string{
+			"/base/a.orig",
+			"/base/repo/untracked.orig",
+			"/base/repo/src/main.go.orig",
+			"/base/repo/src/gen/c.orig",
+			"/base/repo/logs/run.log",
+		}, wipedPaths(events))
+		assert.Contains(t, eventsOfType(events, EventSkipped), Event{Type: EventSkipped, Path: "/base/repo/tracked.orig", Rule: "wipe_out_pattern", Reason: "tracked by git"})
+		assert.True(t, existsOn(fsys, "/base/repo/.git/b.orig"))
+	})
+
+	t.Run("directories containing tracked files are walked into", func(t *testing.T) {
+		fsys := memTree(t, files)
+		sut := &Wiper{WipeOutDirs: []string{"logs", "gen"}, RespectGit: true, BaseDir: "/base", FS: fsys}
+
+		events := collectEvents(sut)
+		assert.Equal(t, []string{"/base/repo/src/gen"}, wipedPaths(events))
+		assert.Contains(t, eventsOfType(events, EventSkipped), Event{Type: EventSkipped, Path: "/base/repo/logs", IsDir: true, Rule: "wipe_out_dirs", Reason: "tracked by git"})
+		assert.True(t, existsOn(fsys, "/base/repo/logs/run.log"))
+	})
+
+	t.Run("git_ignored_only", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/base/repo/.gitignore", []byte("*.log\ngen/\n"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/repo/logs/.gitignore", []byte("!run.log\n"), 0o644))
+		require.NoError(t, fsys.MkdirAll("/base/repo/.git/info", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/repo/.git/info/exclude", []byte("*.go.orig\n"), 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`\.(orig|log)$`}, RespectGit: true, GitIgnoredOnly: true, BaseDir: "/base", FS: fsys}
+
+		events := collectEvents(sut)
+		assert.ElementsMatch(t, []string{
+			"/base/a.orig",
+			"/base/repo/src/main.go.orig",
+			"/base/repo/src/gen/c.orig",
+		}, wipedPaths(events))
+		assert.Contains(t, eventsOfType(events, EventSkipped), Event{Type: EventSkipped, Path: "/base/repo/logs/run.log", Rule: "wipe_out_pattern", Reason: "not ignored by git"})
+	})
+
+	t.Run("base_dir inside a work tree", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/base/repo/.gitignore", []byte("gen/\n"), 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`\.(go|orig)$`}, RespectGit: true, GitIgnoredOnly: true, BaseDir: "/base/repo/src", FS: fsys}
+
+		events := collectEvents(sut)
+		assert.Equal(t, []string{"/base/repo/src/gen/c.orig"}, wipedPaths(events))
+		assert.Contains(t, eventsOfType(events, EventSkipped), Event{Type: EventSkipped, Path: "/base/repo/src/main.go", Rule: "wipe_out_pattern", Reason: "tracked by git"})
+	})
+
+	t.Run(".git file pointing to the git dir", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.MkdirAll("/base/worktree", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/worktree/.git", []byte("gitdir: ../repo/.git\n"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/worktree/tracked.orig", nil, 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, RespectGit: true, BaseDir: "/base/worktree", FS: fsys}
+
+		events := collectEvents(sut)
+		assert.Empty(t, wipedPaths(events))
+		assert.Len(t, eventsOfType(events, EventSkipped), 1)
+	})
+
+	t.Run("red case - unreadable index protects the whole work tree", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/base/repo/.git/index", []byte("garbage"), 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, RespectGit: true, BaseDir: "/base", FS: fsys}
+
+		events := collectEvents(sut)
+		assert.Equal(t, []string{"/base/a.orig"}, wipedPaths(events))
+		errs := eventsOfType(events, EventError)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "git", errs[0].Op)
+	})
+}
@@ -0,0 +1,26 @@
+package wiper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memTree builds an in-memory tree from files, which maps paths to their
+// content. Paths ending in a slash are directories, parent directories are
+// created as needed.
+func memTree(t *testing.T, files map[string]string) *MemFileSystem {
+	t.Helper()
+	fsys := NewMemFileSystem()
+	for name, content := range files {
+		if strings.HasSuffix(name, "/") {
+			require.NoError(t, fsys.MkdirAll(name, 0o755))
+			continue
+		}
+		require.NoError(t, fsys.MkdirAll(filepath.Dir(name), 0o755))
+		require.NoError(t, fsys.WriteFile(name, []byte(content), 0o644))
+	}
+	return fsys
+}
@@ -0,0 +1,266 @@
+package wiper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/steffakasid/eslog"
+)
+
+// historyIDFormat is the layout of the start time used as ID of a run.
+const historyIDFormat = "20060102-150405"
+
+// historyLimit is the number of runs kept in the history.
+const historyLimit = 500
+
+// HistoryRun is the record of a wiper run kept in the history.
+type HistoryRun struct {
+	ID             string         `json:"id"`
+	Started        time.Time      `json:"started"`
+	Finished       time.Time      `json:"finished"`
+	ConfigFile     string         `json:"config_file,omitempty"`
+	ConfigHash     string         `json:"config_hash"`
+	BaseDirs       []string       `json:"base_dirs"`
+	InspectedFiles int            `json:"inspected_files"`
+	InspectedDirs  int            `json:"inspected_dirs"`
+	WipedFiles     int            `json:"wiped_files"`
+	WipedDirs      int            `json:"wiped_dirs"`
+	WipedBytes     int64          `json:"wiped_bytes"`
+	Errors         []string       `json:"errors,omitempty"`
+	Entries        []HistoryEntry `json:"entries,omitempty"`
+}
+
+// HistoryEntry is an entry affected by a run.
+type HistoryEntry struct {
+	Path        string `json:"path"`
+	IsDir       bool   `json:"is_dir,omitempty"`
+	Action      string `json:"action"`
+	Rule        string `json:"rule,omitempty"`
+	Size        int64  `json:"size"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// Duration returns how long the run took.
+func (r HistoryRun) Duration() time.Duration {
+	return r.Finished.Sub(r.Started)
+}
+
+// Affected returns the entries of the run which are path or lie below it.
+func (r HistoryRun) Affected(path string) []HistoryEntry {
+	path = filepath.Clean(path)
+	affected := []HistoryEntry{}
+	for _, entry := range r.Entries {
+		if entry.Path == path || isWithin(path, entry.Path) {
+			affected = append(affected, entry)
+		}
+	}
+	return affected
+}
+
+// NewHistoryRun builds the history record of a run which started at started
+// and whose events were collected by report.
+func (w *Wiper) NewHistoryRun(started time.Time, report *Report) HistoryRun {
+	base, err := filepath.Abs(w.BaseDir)
+	if err != nil {
+		base = w.BaseDir
+	}
+	w.mu.Lock()
+	run := HistoryRun{
+		ID:             started.Format(historyIDFormat),
+		Started:        started,
+		Finished:       time.Now(),
+		ConfigFile:     viper.ConfigFileUsed(),
+		ConfigHash:     w.configHash(),
+		BaseDirs:       []string{base},
+		InspectedFiles: w.InspectedFiles,
+		InspectedDirs:  w.InspectedDirs,
+		WipedFiles:     w.WipedFiles,
+		WipedDirs:      w.WipedDirs,
+		WipedBytes:     w.WipedBytes,
+	}
+	w.mu.Unlock()
+
+	for _, events := range [][]Event{report.Wiped, report.Trashed, report.Archived, report.Quarantined, report.Shredded} {
+		for _, e := range events {
+			run.Entries = append(run.Entries, HistoryEntry{
+				Path:        e.Path,
+				IsDir:       e.IsDir,
+				Action:      e.Type.String(),
+				Rule:        e.Rule,
+				Size:        e.Size,
+				Destination: e.Destination,
+			})
+		}
+	}
+	slices.SortFunc(run.Entries, func(a, b HistoryEntry) int { return strings.Compare(a.Path, b.Path) })
+	for _, e := range report.Errors {
+		run.Errors = append(run.Errors, e.String())
+	}
+	return run
+}
+
+// AddPurged adds the items permanently deleted from trash to the run. rule
+// names the setting which purged them, if any.
+func (r *HistoryRun) AddPurged(trash *Trash, purged []TrashItem, rule string) {
+	for _, item := range purged {
+		if item.IsDir {
+			r.WipedDirs++
+		} else {
+			r.WipedFiles++
+		}
+		r.WipedBytes += item.Size
+		r.Entries = append(r.Entries, HistoryEntry{
+			Path:   trash.Path(item),
+			IsDir:  item.IsDir,
+			Action: "purged",
+			Rule:   rule,
+			Size:   item.Size,
+		})
+	}
+	slices.SortFunc(r.Entries, func(a, b HistoryEntry) int { return strings.Compare(a.Path, b.Path) })
+}
+
+// configHash returns a hash of the effective settings, so runs with the same
+// config can be recognized.
+func (w *Wiper) configHash() string {
+	content, err := json.Marshal(w)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
+
+// historyDir returns $XDG_STATE_HOME/wiper/history.
+func historyDir() string {
+	return filepath.Join(stateDir(), "history")
+}
+
+// SaveHistoryRun adds run to the history. If another run started in the same
+// second, the ID gets a suffix. The run is written to a temporary file first,
+// so the history never contains a partly written run. Only the latest
+// historyLimit runs are kept.
+func SaveHistoryRun(run *HistoryRun) error {
+	dir := historyDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	base := run.ID
+	for i := 2; ; i++ {
+		err := writeHistoryRun(dir, run)
+		if !errors.Is(err, os.ErrExist) {
+			if err != nil {
+				return err
+			}
+			return pruneHistory(dir, historyLimit)
+		}
+		run.ID = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// writeHistoryRun writes run to a temporary file and links it to its name in
+// dir. Unlike a rename, the link fails if the name is taken already.
+func writeHistoryRun(dir string, run *HistoryRun) error {
+	temp, err := os.CreateTemp(dir, ".run-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	err = json.NewEncoder(temp).Encode(run)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if err := errors.Join(err, temp.Close()); err != nil {
+		return err
+	}
+	return os.Link(temp.Name(), filepath.Join(dir, run.ID+".json"))
+}
+
+// pruneHistory removes the oldest runs in dir, so at most limit are left. The
+// IDs of runs sort by their start time.
+func pruneHistory(dir string, limit int) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) <= limit {
+		return err
+	}
+	slices.SortFunc(files, func(a, b string) int { return compareHistoryIDs(historyID(a), historyID(b)) })
+	errs := []error{}
+	for _, file := range files[:len(files)-limit] {
+		errs = append(errs, os.Remove(file))
+	}
+	return errors.Join(errs...)
+}
+
+func historyID(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), ".json")
+}
+
+// compareHistoryIDs orders the IDs of runs which started in the same second
+// by their suffix, so run-10 comes after run-9.
+func compareHistoryIDs(a, b string) int {
+	if c := strings.Compare(a[:min(len(a), len(historyIDFormat))], b[:min(len(b), len(historyIDFormat))]); c != 0 {
+		return c
+	}
+	if c := len(a) - len(b); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// LoadHistory returns the recorded runs, oldest first. Runs which can't be
+// read are skipped with a warning.
+func LoadHistory() ([]HistoryRun, error) {
+	files, err := filepath.Glob(filepath.Join(historyDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	runs := []HistoryRun{}
+	for _, file := range files {
+		run, err := readHistoryRun(file)
+		if err != nil {
+			eslog.Warnf("Skipping run %s of the history: %s", historyID(file), err)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	slices.SortFunc(runs, func(a, b HistoryRun) int {
+		if c := a.Started.Compare(b.Started); c != 0 {
+			return c
+		}
+		return compareHistoryIDs(a.ID, b.ID)
+	})
+	return runs, nil
+}
+
+// LoadHistoryRun returns the run with the id.
+func LoadHistoryRun(id string) (HistoryRun, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return HistoryRun{}, fmt.Errorf("invalid run id %q", id)
+	}
+	run, err := readHistoryRun(filepath.Join(historyDir(), id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return run, fmt.Errorf("no run %s in the history", id)
+	}
+	return run, err
+}
+
+func readHistoryRun(path string) (HistoryRun, error) {
+	run := HistoryRun{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return run, err
+	}
+	if err := json.Unmarshal(content, &run); err != nil {
+		return run, fmt.Errorf("%s: %w", path, err)
+	}
+	return run, nil
+}
@@ -0,0 +1,150 @@
+package wiper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	newRun := func(t *testing.T) (*Wiper, *Report, time.Time) {
+		t.Helper()
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base/cache/sub", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/a.orig", make([]byte, 10), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/cache/sub/b", make([]byte, 20), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/keep.txt", make([]byte, 5), 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, WipeOutDirs: []string{"cache"}, BaseDir: "/base", FS: fsys}
+
+		started := time.Now()
+		report := &Report{}
+		for _, event := range collectEvents(sut) {
+			report.Add(event)
+		}
+		report.Add(errorEvent("remove", "/base/locked", false, errors.New("permission denied")))
+		return sut, report, started
+	}
+
+	t.Run("green case - run is recorded with its entries", func(t *testing.T) {
+		sut, report, started := newRun(t)
+		run := sut.NewHistoryRun(started, report)
+
+		assert.Equal(t, started.Format(historyIDFormat), run.ID)
+		assert.Equal(t, []string{"/base"}, run.BaseDirs)
+		assert.NotEmpty(t, run.ConfigHash)
+		assert.Equal(t, 1, run.WipedFiles)
+		assert.Equal(t, 1, run.WipedDirs)
+		assert.Equal(t, int64(30), run.WipedBytes)
+		assert.Equal(t, []HistoryEntry{
+			{Path: "/base/a.orig", Action: "wiped", Rule: "wipe_out_pattern", Size: 10},
+			{Path: "/base/cache", IsDir: true, Action: "wiped", Rule: "wipe_out_dirs", Size: 20},
+		}, run.Entries)
+		assert.Equal(t, []string{"remove /base/locked: permission denied"}, run.Errors)
+
+		assert.Len(t, run.Affected("/base/cache/sub/b"), 0)
+		assert.Len(t, run.Affected("/base/cache"), 1)
+		assert.Len(t, run.Affected("/base"), 2)
+	})
+
+	t.Run("green case - runs are saved and loaded", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		sut, report, started := newRun(t)
+		first := sut.NewHistoryRun(started, report)
+		second := sut.NewHistoryRun(started, &Report{})
+		require.NoError(t, SaveHistoryRun(&first))
+		require.NoError(t, SaveHistoryRun(&second))
+		assert.Equal(t, first.ID+"-2", second.ID)
+
+		runs, err := LoadHistory()
+		require.NoError(t, err)
+		require.Len(t, runs, 2)
+		assert.Equal(t, first.ID, runs[0].ID)
+		assert.Equal(t, second.ID, runs[1].ID)
+		assert.Equal(t, first.Entries, runs[0].Entries)
+		assert.Empty(t, runs[1].Entries)
+
+		loaded, err := LoadHistoryRun(second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, second.WipedBytes, loaded.WipedBytes)
+	})
+
+	t.Run("config hash changes with the config", func(t *testing.T) {
+		assert.Equal(t, (&Wiper{BaseDir: "/a"}).configHash(), (&Wiper{BaseDir: "/a"}).configHash())
+		assert.NotEqual(t, (&Wiper{BaseDir: "/a"}).configHash(), (&Wiper{BaseDir: "/b"}).configHash())
+	})
+
+	t.Run("red case - unknown run", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		runs, err := LoadHistory()
+		require.NoError(t, err)
+		assert.Empty(t, runs)
+
+		_, err = LoadHistoryRun("20240101-000000")
+		assert.ErrorContains(t, err, "no run 20240101-000000")
+		_, err = LoadHistoryRun("../locks/x")
+		assert.Error(t, err)
+	})
+
+	t.Run("red case - unreadable runs are skipped", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		sut, report, started := newRun(t)
+		run := sut.NewHistoryRun(started, report)
+		require.NoError(t, SaveHistoryRun(&run))
+		require.NoError(t, os.WriteFile(filepath.Join(historyDir(), "20240101-000000.json"), []byte(`{"id":`), 0o600))
+
+		runs, err := LoadHistory()
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, run.ID, runs[0].ID)
+	})
+
+	t.Run("green case - no temporary files are left behind", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		sut, report, started := newRun(t)
+		run := sut.NewHistoryRun(started, report)
+		require.NoError(t, SaveHistoryRun(&run))
+
+		entries, err := os.ReadDir(historyDir())
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, run.ID+".json", entries[0].Name())
+	})
+
+	t.Run("green case - the oldest runs are pruned", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		dir := historyDir()
+		require.NoError(t, os.MkdirAll(dir, 0o700))
+		for _, id := range []string{"20240101-000000-10", "20240101-000000-9", "20231231-235959", "20240101-000000", "20240102-000000"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, id+".json"), []byte("{}"), 0o600))
+		}
+
+		require.NoError(t, pruneHistory(dir, 3))
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		require.NoError(t, err)
+		ids := []string{}
+		for _, file := range files {
+			ids = append(ids, historyID(file))
+		}
+		assert.ElementsMatch(t, []string{"20240101-000000-9", "20240101-000000-10", "20240102-000000"}, ids)
+	})
+
+	t.Run("green case - purged trash items are recorded", func(t *testing.T) {
+		trash := &Trash{Dir: "/home/.Trash"}
+		run := HistoryRun{WipedFiles: 1, WipedBytes: 10, Entries: []HistoryEntry{{Path: "/base/a.orig", Action: "wiped", Size: 10}}}
+		run.AddPurged(trash, []TrashItem{{Name: "old", IsDir: true, Size: 5}, {Name: "b.orig", Size: 3}}, "trash_retention")
+
+		assert.Equal(t, 2, run.WipedFiles)
+		assert.Equal(t, 1, run.WipedDirs)
+		assert.Equal(t, int64(18), run.WipedBytes)
+		assert.Equal(t, []HistoryEntry{
+			{Path: "/base/a.orig", Action: "wiped", Size: 10},
+			{Path: "/home/.Trash/b.orig", Action: "purged", Rule: "trash_retention", Size: 3},
+			{Path: "/home/.Trash/old", IsDir: true, Action: "purged", Rule: "trash_retention", Size: 5},
+		}, run.Entries)
+	})
+}
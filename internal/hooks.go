@@ -0,0 +1,133 @@
+package wiper
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Hook stages
+const (
+	HookBefore  = "before"
+	HookAfter   = "after"
+	HookOnMatch = "on_match"
+)
+
+// Hooks are external commands given as argument lists. Arguments may contain
+// the placeholders {path}, {name}, {dir}, {rule} and {base_dir}; the same
+// values are exported as WIPER_PATH, WIPER_NAME, WIPER_DIR, WIPER_RULE,
+// WIPER_IS_DIR and WIPER_BASE_DIR.
+type Hooks struct {
+	Before  []string `json:"before,omitempty" mapstructure:"before" yaml:"before"`
+	After   []string `json:"after,omitempty" mapstructure:"after" yaml:"after"`
+	OnMatch []string `json:"on_match,omitempty" mapstructure:"on_match" yaml:"on_match"`
+}
+
+// HookResult describes one execution of a hook command.
+type HookResult struct {
+	Stage    string
+	Command  []string
+	Path     string
+	ExitCode int
+	Output   string
+	Err      error
+}
+
+func (r *HookResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s hook %q failed: %s", r.Stage, strings.Join(r.Command, " "), r.Err)
+	}
+	return fmt.Sprintf("%s hook %q succeeded", r.Stage, strings.Join(r.Command, " "))
+}
+
+// RunHook runs the before or after hook. It returns nil if the stage has no
+// command configured.
+func (w *Wiper) RunHook(stage string) *HookResult {
+	var command []string
+	switch stage {
+	case HookBefore:
+		command = w.Hooks.Before
+	case HookAfter:
+		command = w.Hooks.After
+	}
+	if len(command) == 0 {
+		return nil
+	}
+	return w.runHook(stage, command, hookVars{baseDir: w.BaseDir})
+}
+
+// runMatchHook runs the on_match hook for target. A failing hook vetoes the
+// wipe of target.
+func (w *Wiper) runMatchHook(target, rule string, isDir bool) *HookResult {
+	if len(w.Hooks.OnMatch) == 0 {
+		return nil
+	}
+	return w.runHook(HookOnMatch, w.Hooks.OnMatch, hookVars{
+		path:    target,
+		rule:    rule,
+		isDir:   isDir,
+		baseDir: w.BaseDir,
+	})
+}
+
+type hookVars struct {
+	path    string
+	rule    string
+	isDir   bool
+	baseDir string
+}
+
+func (v hookVars) replacer() *strings.Replacer {
+	return strings.NewReplacer(
+		"{path}", v.path,
+		"{name}", v.name(),
+		"{dir}", v.dir(),
+		"{rule}", v.rule,
+		"{base_dir}", v.baseDir,
+	)
+}
+
+func (v hookVars) name() string {
+	if v.path == "" {
+		return ""
+	}
+	return filepath.Base(v.path)
+}
+
+func (v hookVars) dir() string {
+	if v.path == "" {
+		return ""
+	}
+	return filepath.Dir(v.path)
+}
+
+func (v hookVars) env() []string {
+	return []string{
+		"WIPER_PATH=" + v.path,
+		"WIPER_NAME=" + v.name(),
+		"WIPER_DIR=" + v.dir(),
+		"WIPER_RULE=" + v.rule,
+		"WIPER_IS_DIR=" + strconv.FormatBool(v.isDir),
+		"WIPER_BASE_DIR=" + v.baseDir,
+	}
+}
+
+func (w *Wiper) runHook(stage string, command []string, vars hookVars) *HookResult {
+	replacer := vars.replacer()
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = replacer.Replace(arg)
+	}
+
+	result := &HookResult{Stage: stage, Command: args, Path: vars.path}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), vars.env()...)
+	output, err := cmd.CombinedOutput()
+	result.Output = strings.TrimSpace(string(output))
+	result.Err = err
+	result.ExitCode = cmd.ProcessState.ExitCode()
+	return result
+}
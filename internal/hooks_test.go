@@ -0,0 +1,104 @@
+package wiper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHook(t *testing.T) {
+	t.Run("green case - before hook runs with base dir placeholder", func(t *testing.T) {
+		sut := Wiper{
+			BaseDir: "/base",
+			Hooks:   Hooks{Before: []string{"echo", "scanning {base_dir}"}},
+		}
+
+		result := sut.RunHook(HookBefore)
+		require.NotNil(t, result)
+		assert.NoError(t, result.Err)
+		assert.Equal(t, HookBefore, result.Stage)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Equal(t, "scanning /base", result.Output)
+	})
+
+	t.Run("red case - after hook exit code reported", func(t *testing.T) {
+		sut := Wiper{
+			Hooks: Hooks{After: []string{"sh", "-c", "exit 3"}},
+		}
+
+		result := sut.RunHook(HookAfter)
+		require.NotNil(t, result)
+		assert.Error(t, result.Err)
+		assert.Equal(t, 3, result.ExitCode)
+	})
+
+	t.Run("unconfigured stage returns nil", func(t *testing.T) {
+		sut := Wiper{}
+		assert.Nil(t, sut.RunHook(HookBefore))
+		assert.Nil(t, sut.RunHook(HookAfter))
+	})
+
+	t.Run("red case - missing executable", func(t *testing.T) {
+		sut := Wiper{
+			Hooks: Hooks{Before: []string{"wiper-hook-that-does-not-exist"}},
+		}
+
+		result := sut.RunHook(HookBefore)
+		require.NotNil(t, result)
+		assert.Error(t, result.Err)
+		assert.Equal(t, -1, result.ExitCode)
+	})
+}
+
+func TestMatchHook(t *testing.T) {
+	t.Run("green case - placeholders and environment are provided", func(t *testing.T) {
+		sut := Wiper{
+			BaseDir: "/base",
+			Hooks: Hooks{OnMatch: []string{
+				"sh", "-c", `echo "$1 $2 $WIPER_NAME $WIPER_DIR $WIPER_IS_DIR"`, "hook", "{path}", "{rule}",
+			}},
+		}
+
+		result := sut.runMatchHook("/base/sub/file.orig", "wipe_out_pattern", false)
+		require.NotNil(t, result)
+		require.NoError(t, result.Err)
+		assert.Equal(t, "/base/sub/file.orig wipe_out_pattern file.orig /base/sub false", result.Output)
+		assert.Equal(t, "/base/sub/file.orig", result.Path)
+	})
+
+	t.Run("failing hook vetoes the wipe", func(t *testing.T) {
+		testDir := t.TempDir()
+		keep := filepath.Join(testDir, "keep.orig")
+		wipe := filepath.Join(testDir, "wipe.orig")
+		require.NoError(t, os.WriteFile(keep, nil, 0o644))
+		require.NoError(t, os.WriteFile(wipe, nil, 0o644))
+
+		sut := &Wiper{
+			WipeOutPattern: []string{`.*\.orig$`},
+			BaseDir:        testDir,
+			Hooks:          Hooks{OnMatch: []string{"sh", "-c", `test "$WIPER_NAME" != keep.orig`}},
+		}
+
+		events := collectEvents(sut)
+
+		assert.FileExists(t, keep)
+		assert.NoFileExists(t, wipe)
+		assert.Equal(t, 1, sut.WipedFiles)
+		assert.Len(t, eventsOfType(events, EventHook), 2)
+
+		skipped := eventsOfType(events, EventSkipped)
+		require.Len(t, skipped, 1)
+		assert.Equal(t, keep, skipped[0].Path)
+		assert.Contains(t, skipped[0].Reason, "vetoed by on_match hook")
+
+		report := Report{}
+		for _, event := range events {
+			report.Add(event)
+		}
+		assert.Len(t, report.Hooks, 2)
+		assert.Len(t, report.FailedHooks(), 1)
+	})
+}
@@ -0,0 +1,122 @@
+package wiper
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ignorePattern is one line of a file in gitignore syntax.
+type ignorePattern struct {
+	matcher *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreList holds the patterns of one file in gitignore syntax. Paths are
+// matched relative to the directory of that file.
+type ignoreList struct {
+	patterns []ignorePattern
+}
+
+// parseIgnore reads patterns in gitignore syntax. Invalid patterns are
+// skipped like git does.
+func parseIgnore(r io.Reader) (*ignoreList, error) {
+	list := &ignoreList{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if pattern, ok := compileIgnorePattern(scanner.Text()); ok {
+			list.patterns = append(list.patterns, pattern)
+		}
+	}
+	return list, scanner.Err()
+}
+
+func compileIgnorePattern(line string) (ignorePattern, bool) {
+	pattern := ignorePattern{}
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern, false
+	}
+	if strings.HasPrefix(line, "!") {
+		pattern.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		pattern.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return pattern, false
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	prefix := "^(?:.*/)?"
+	if anchored {
+		prefix = "^"
+	}
+	matcher, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+	if err != nil {
+		return pattern, false
+	}
+	pattern.matcher = matcher
+	return pattern, true
+}
+
+// globToRegexp translates a gitignore glob. * and ? don't match a slash, **
+// matches across directories.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// match returns whether rel is ignored by the last matching pattern. found is
+// false if no pattern matched.
+func (l *ignoreList) match(rel string, isDir bool) (ignored, found bool) {
+	for _, pattern := range l.patterns {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+		if pattern.matcher.MatchString(rel) {
+			ignored, found = !pattern.negate, true
+		}
+	}
+	return ignored, found
+}
@@ -0,0 +1,58 @@
+package wiper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreList(t *testing.T) {
+	content := `# build output
+*.log
+!keep.log
+/dist
+cache/
+docs/**/*.pdf
+**/tmp/scratch
+file\#1
+trailing
+[!a]bc
+`
+	list, err := parseIgnore(strings.NewReader(content))
+	require.NoError(t, err)
+
+	tests := []struct {
+		rel     string
+		isDir   bool
+		ignored bool
+		found   bool
+	}{
+		{rel: "app.log", ignored: true, found: true},
+		{rel: "sub/dir/app.log", ignored: true, found: true},
+		{rel: "keep.log", ignored: false, found: true},
+		{rel: "dist", isDir: true, ignored: true, found: true},
+		{rel: "sub/dist", isDir: true},
+		{rel: "cache", isDir: true, ignored: true, found: true},
+		{rel: "sub/cache", isDir: true, ignored: true, found: true},
+		{rel: "cache"},
+		{rel: "docs/a/b/manual.pdf", ignored: true, found: true},
+		{rel: "docs/manual.pdf", ignored: true, found: true},
+		{rel: "other/docs/manual.pdf"},
+		{rel: "tmp/scratch", ignored: true, found: true},
+		{rel: "a/tmp/scratch", ignored: true, found: true},
+		{rel: "file#1", ignored: true, found: true},
+		{rel: "trailing", ignored: true, found: true},
+		{rel: "xbc", ignored: true, found: true},
+		{rel: "abc"},
+		{rel: "main.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			ignored, found := list.match(tt.rel, tt.isDir)
+			assert.Equal(t, tt.ignored, ignored)
+			assert.Equal(t, tt.found, found)
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package wiper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockPollInterval is how often AcquireLock checks a held lock while it
+// waits.
+const lockPollInterval = 250 * time.Millisecond
+
+// errLockHeld is returned by lockFile if another process holds the lock.
+var errLockHeld = errors.New("lock is held by another process")
+
+// RunLock is held by a wiper run, so two runs never work on the same tree at
+// the same time.
+type RunLock struct {
+	path  string
+	file  *os.File
+	owner lockOwner
+}
+
+// lockOwner is written to the lock file.
+type lockOwner struct {
+	PID     int       `json:"pid"`
+	BaseDir string    `json:"base_dir"`
+	Started time.Time `json:"started"`
+}
+
+// LockedError is returned by AcquireLock if another run holds the lock.
+type LockedError struct {
+	Path    string
+	PID     int
+	BaseDir string
+	Started time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s is already wiped by process %d since %s (lock %s)", e.BaseDir, e.PID, e.Started.Format(time.RFC3339), e.Path)
+}
+
+// lockBase returns the absolute path of baseDir with symlinks resolved, so
+// every spelling of a directory gets the same lock.
+func lockBase(baseDir string) (string, error) {
+	base, err := filepath.Abs(baseDir)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(base); err == nil {
+		base = resolved
+	}
+	return base, nil
+}
+
+// LockPath returns the lock file of base_dir in the state directory.
+func (w *Wiper) LockPath() string {
+	base, err := lockBase(w.BaseDir)
+	if err != nil {
+		base = filepath.Clean(w.BaseDir)
+	}
+	sum := sha256.Sum256([]byte(base))
+	return filepath.Join(stateDir(), "locks", hex.EncodeToString(sum[:8])+".lock")
+}
+
+// Lock acquires the lock of base_dir, waiting up to wait for a running wiper
+// to finish.
+func (w *Wiper) Lock(wait time.Duration) (*RunLock, error) {
+	base, err := lockBase(w.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+	return AcquireLock(w.LockPath(), base, wait)
+}
+
+// AcquireLock locks the lock file at path for baseDir. The file is locked
+// with flock(2), or LockFileEx on Windows, so the lock of a run which crashed
+// is released by the system. As runs on a directory and on one of its
+// subdirectories must not overlap either, the other lock files next to path
+// are checked as well. If a run holds one of them, AcquireLock retries until
+// wait passed and returns a *LockedError then.
+func AcquireLock(path, baseDir string, wait time.Duration) (*RunLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	owner := lockOwner{PID: os.Getpid(), BaseDir: baseDir, Started: time.Now()}
+	deadline := time.Now().Add(wait)
+	for {
+		lock, err := tryLock(path, owner)
+		var locked *LockedError
+		if !errors.As(err, &locked) || time.Now().After(deadline) {
+			return lock, err
+		}
+		// the jitter keeps two runs which block each other from retrying in
+		// lockstep
+		pause := lockPollInterval + rand.N(lockPollInterval/4)
+		time.Sleep(min(pause, time.Until(deadline)+time.Millisecond))
+	}
+}
+
+// tryLock locks path once without waiting.
+func tryLock(path string, owner lockOwner) (*RunLock, error) {
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(file); err != nil {
+			file.Close()
+			if errors.Is(err, errLockHeld) {
+				holder, _ := readLockOwner(path)
+				return nil, &LockedError{Path: path, PID: holder.PID, BaseDir: holder.BaseDir, Started: holder.Started}
+			}
+			return nil, err
+		}
+		// the previous holder removes the file before it unlocks it, so a
+		// file which is no longer at path was locked too late
+		if !isLockedPath(file, path) {
+			file.Close()
+			continue
+		}
+
+		lock := &RunLock{path: path, file: file, owner: owner}
+		if err := lock.write(); err != nil {
+			return nil, errors.Join(err, lock.Release())
+		}
+		if holder, path, held := overlappingLock(lock); held {
+			return nil, errors.Join(&LockedError{Path: path, PID: holder.PID, BaseDir: holder.BaseDir, Started: holder.Started}, lock.Release())
+		}
+		return lock, nil
+	}
+}
+
+func isLockedPath(file *os.File, path string) bool {
+	locked, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	return err == nil && os.SameFile(locked, current)
+}
+
+// write replaces the content of the lock file with its owner.
+func (l *RunLock) write() error {
+	content, err := json.Marshal(l.owner)
+	if err != nil {
+		return err
+	}
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.WriteAt(content, 0); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// overlappingLock returns the owner and path of a held lock next to l whose
+// base dir contains the one of l or lies below it. Lock files left behind
+// by crashed runs are removed.
+func overlappingLock(l *RunLock) (lockOwner, string, bool) {
+	others, _ := filepath.Glob(filepath.Join(filepath.Dir(l.path), "*.lock"))
+	for _, other := range others {
+		if other == l.path {
+			continue
+		}
+		holder, err := readLockOwner(other)
+		if err != nil || !isWithin(holder.BaseDir, l.owner.BaseDir) && !isWithin(l.owner.BaseDir, holder.BaseDir) {
+			continue
+		}
+		file, err := os.OpenFile(other, os.O_RDWR, 0)
+		if err != nil {
+			continue
+		}
+		err = lockFile(file)
+		if err == nil && isLockedPath(file, other) {
+			_ = os.Remove(other)
+		}
+		file.Close()
+		if errors.Is(err, errLockHeld) {
+			return holder, other, true
+		}
+	}
+	return lockOwner{}, "", false
+}
+
+// Release removes and unlocks the lock file. Removing it is best effort, a
+// lock file left behind is not locked by anyone.
+func (l *RunLock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	_ = os.Remove(l.path)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func readLockOwner(path string) (lockOwner, error) {
+	owner := lockOwner{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return owner, err
+	}
+	err = json.Unmarshal(content, &owner)
+	return owner, err
+}
@@ -0,0 +1,32 @@
+//go:build !(unix && !aix) && !windows
+
+package wiper
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// lockFile has no file locks to use on this platform, so it only checks
+// whether the process written to file is still running. Where FindProcess
+// can't tell, the lock file of a crashed run has to be removed by hand.
+func lockFile(file *os.File) error {
+	owner := lockOwner{}
+	if json.NewDecoder(file).Decode(&owner) == nil && processAlive(owner.PID) {
+		return errLockHeld
+	}
+	return nil
+}
+
+// processAlive reports whether a process with the pid may exist.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
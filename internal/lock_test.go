@@ -0,0 +1,130 @@
+package wiper
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	t.Run("green case - lock is held until released", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		sut := &Wiper{BaseDir: t.TempDir()}
+
+		lock, err := sut.Lock(0)
+		require.NoError(t, err)
+		assert.FileExists(t, sut.LockPath())
+
+		_, err = sut.Lock(0)
+		var locked *LockedError
+		require.ErrorAs(t, err, &locked)
+		assert.Equal(t, os.Getpid(), locked.PID)
+		assert.Equal(t, sut.BaseDir, locked.BaseDir)
+
+		other := &Wiper{BaseDir: t.TempDir()}
+		otherLock, err := other.Lock(0)
+		require.NoError(t, err)
+		require.NoError(t, otherLock.Release())
+
+		require.NoError(t, lock.Release())
+		assert.NoFileExists(t, sut.LockPath())
+		lock, err = sut.Lock(0)
+		require.NoError(t, err)
+		require.NoError(t, lock.Release())
+	})
+
+	t.Run("green case - wait for the running wiper", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		sut := &Wiper{BaseDir: t.TempDir()}
+		lock, err := sut.Lock(0)
+		require.NoError(t, err)
+		time.AfterFunc(300*time.Millisecond, func() { assert.NoError(t, lock.Release()) })
+
+		start := time.Now()
+		waited, err := sut.Lock(5 * time.Second)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+		require.NoError(t, waited.Release())
+	})
+
+	t.Run("green case - stale lock of a dead process is taken over", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		sut := &Wiper{BaseDir: t.TempDir()}
+
+		dead := exec.Command(os.Args[0], "-test.run=^$")
+		require.NoError(t, dead.Run())
+		content, err := json.Marshal(lockOwner{PID: dead.Process.Pid, BaseDir: sut.BaseDir, Started: time.Now()})
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(sut.LockPath()), 0o700))
+		require.NoError(t, os.WriteFile(sut.LockPath(), content, 0o600))
+
+		lock, err := sut.Lock(0)
+		require.NoError(t, err)
+		holder, err := readLockOwner(sut.LockPath())
+		require.NoError(t, err)
+		assert.Equal(t, os.Getpid(), holder.PID)
+		require.NoError(t, lock.Release())
+	})
+
+	t.Run("red case - nested base dirs share the lock", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		home := t.TempDir()
+		projects := filepath.Join(home, "projects")
+		require.NoError(t, os.Mkdir(projects, 0o755))
+		require.NoError(t, os.Symlink(projects, filepath.Join(home, "link")))
+
+		lock, err := (&Wiper{BaseDir: home}).Lock(0)
+		require.NoError(t, err)
+		for _, baseDir := range []string{projects, filepath.Join(home, "link"), home + "/."} {
+			_, err = (&Wiper{BaseDir: baseDir}).Lock(0)
+			var locked *LockedError
+			require.ErrorAs(t, err, &locked, baseDir)
+			assert.Equal(t, home, locked.BaseDir)
+		}
+		require.NoError(t, lock.Release())
+
+		lock, err = (&Wiper{BaseDir: projects}).Lock(0)
+		require.NoError(t, err)
+		_, err = (&Wiper{BaseDir: home}).Lock(0)
+		assert.ErrorContains(t, err, projects+" is already wiped by process")
+		require.NoError(t, lock.Release())
+
+		entries, err := os.ReadDir(filepath.Join(os.Getenv("XDG_STATE_HOME"), "wiper", "locks"))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("lock files of crashed runs on other base dirs are cleaned up", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		home := t.TempDir()
+		crashed := &Wiper{BaseDir: filepath.Join(home, "projects")}
+		content, err := json.Marshal(lockOwner{PID: os.Getpid(), BaseDir: crashed.BaseDir, Started: time.Now()})
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(crashed.LockPath()), 0o700))
+		require.NoError(t, os.WriteFile(crashed.LockPath(), content, 0o600))
+
+		lock, err := (&Wiper{BaseDir: home}).Lock(0)
+		require.NoError(t, err)
+		assert.NoFileExists(t, crashed.LockPath())
+		require.NoError(t, lock.Release())
+	})
+
+	t.Run("red case - lock held past the wait", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		sut := &Wiper{BaseDir: t.TempDir()}
+		lock, err := sut.Lock(0)
+		require.NoError(t, err)
+		t.Cleanup(func() { assert.NoError(t, lock.Release()) })
+
+		start := time.Now()
+		_, err = sut.Lock(300 * time.Millisecond)
+		assert.ErrorContains(t, err, "already wiped by process")
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	})
+}
@@ -0,0 +1,446 @@
+package wiper
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFileSystem is an in-memory FileSystem. It is meant for tests and for
+// evaluating a configuration against a fixture tree without touching disk.
+// Paths are cleaned with filepath.Clean; the root "/" always exists.
+type MemFileSystem struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	mode     fs.FileMode
+	modTime  time.Time
+	data     []byte
+	uid, gid uint32
+}
+
+// newMemNode returns a node owned by the current user.
+func newMemNode(mode fs.FileMode, data []byte) *memNode {
+	return &memNode{mode: mode, modTime: time.Now(), data: data, uid: currentUID(), gid: uint32(os.Getegid())}
+}
+
+type memFileInfo struct {
+	name string
+	node memNode
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i memFileInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i memFileInfo) ModTime() time.Time { return i.node.modTime }
+func (i memFileInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i memFileInfo) Sys() any           { return ownerInfo{uid: i.node.uid, gid: i.node.gid} }
+
+func NewMemFileSystem() *MemFileSystem {
+	return &MemFileSystem{
+		nodes: map[string]*memNode{
+			"/": newMemNode(fs.ModeDir|0o755, nil),
+		},
+	}
+}
+
+// MkdirAll creates a directory together with any missing parents.
+func (m *MemFileSystem) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	for _, dir := range ancestors(name) {
+		if node, ok := m.nodes[dir]; ok {
+			if !node.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: fs.ErrExist}
+			}
+			continue
+		}
+		m.nodes[dir] = newMemNode(fs.ModeDir|perm.Perm(), nil)
+	}
+	return nil
+}
+
+// WriteFile creates or replaces a regular file. The parent directory must exist.
+func (m *MemFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	if err := m.checkParent("open", name); err != nil {
+		return err
+	}
+	if node, ok := m.nodes[name]; ok && node.mode.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	m.nodes[name] = newMemNode(perm.Perm(), slices.Clone(data))
+	return nil
+}
+
+// Chtimes sets the modification time of name. MemFileSystem keeps no access
+// times, atime is ignored.
+func (m *MemFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.nodes[filepath.Clean(name)]
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+	node.modTime = mtime
+	return nil
+}
+
+// Chown sets the owner and group of name.
+func (m *MemFileSystem) Chown(name string, uid, gid int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.nodes[filepath.Clean(name)]
+	if !ok {
+		return &fs.PathError{Op: "chown", Path: name, Err: fs.ErrNotExist}
+	}
+	node.uid, node.gid = uint32(uid), uint32(gid)
+	return nil
+}
+
+// Chmod sets the permission bits of name, including the setuid, setgid and
+// sticky bits.
+func (m *MemFileSystem) Chmod(name string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.nodes[filepath.Clean(name)]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	const changeable = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+	node.mode = node.mode&^changeable | mode&changeable
+	return nil
+}
+
+// ReadFile returns the content of a regular file.
+func (m *MemFileSystem) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.nodes[filepath.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return slices.Clone(node.data), nil
+}
+
+func (m *MemFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: fs.ErrInvalid}
+	}
+
+	entries := []fs.DirEntry{}
+	for p, child := range m.nodes {
+		if p != name && filepath.Dir(p) == name {
+			entries = append(entries, fs.FileInfoToDirEntry(memFileInfo{name: filepath.Base(p), node: *child}))
+		}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+func (m *MemFileSystem) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memFileInfo{name: filepath.Base(name), node: *node}, nil
+}
+
+// Lstat is identical to Stat because MemFileSystem has no symlinks.
+func (m *MemFileSystem) Lstat(name string) (fs.FileInfo, error) {
+	return m.Stat(name)
+}
+
+func (m *MemFileSystem) Mkdir(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	if _, ok := m.nodes[name]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := m.checkParent("mkdir", name); err != nil {
+		return err
+	}
+	m.nodes[name] = newMemNode(fs.ModeDir|perm.Perm(), nil)
+	return nil
+}
+
+func (m *MemFileSystem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	node, ok := m.nodes[name]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() && m.hasChildren(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+func (m *MemFileSystem) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	for p := range m.nodes {
+		if p == name || isWithin(name, p) {
+			delete(m.nodes, p)
+		}
+	}
+	return nil
+}
+
+func (m *MemFileSystem) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldpath = filepath.Clean(oldpath)
+	newpath = filepath.Clean(newpath)
+	if _, ok := m.nodes[oldpath]; !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if err := m.checkParent("rename", newpath); err != nil {
+		return err
+	}
+	if isWithin(oldpath, newpath) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	if target, ok := m.nodes[newpath]; ok && target.mode.IsDir() && m.hasChildren(newpath) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrExist}
+	}
+
+	moved := map[string]*memNode{}
+	for p, node := range m.nodes {
+		if p == oldpath || isWithin(oldpath, p) {
+			moved[newpath+strings.TrimPrefix(p, oldpath)] = node
+			delete(m.nodes, p)
+		}
+	}
+	for p, node := range moved {
+		m.nodes[p] = node
+	}
+	return nil
+}
+
+// Readlink always fails because MemFileSystem has no symlinks.
+func (m *MemFileSystem) Readlink(name string) (string, error) {
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+}
+
+func (m *MemFileSystem) Open(name string) (File, error) {
+	info, err := m.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memFile{fsys: m, name: filepath.Clean(name), dir: info.IsDir()}, nil
+}
+
+// Create truncates or creates a regular file, like os.Create.
+func (m *MemFileSystem) Create(name string) (File, error) {
+	if err := m.WriteFile(name, nil, 0o666); err != nil {
+		return nil, err
+	}
+	return &memFile{fsys: m, name: filepath.Clean(name), writable: true}, nil
+}
+
+// OpenFile supports the os.O_CREATE, os.O_EXCL, os.O_TRUNC and os.O_APPEND
+// flags. Files are writable unless opened with os.O_RDONLY.
+func (m *MemFileSystem) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	info, err := m.Stat(name)
+	switch {
+	case err != nil && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err != nil:
+		if err := m.WriteFile(name, nil, perm); err != nil {
+			return nil, err
+		}
+		info, _ = m.Stat(name)
+	case flag&os.O_TRUNC != 0:
+		if err := m.Truncate(name, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	file := &memFile{fsys: m, name: filepath.Clean(name), dir: info.IsDir(), writable: flag&(os.O_WRONLY|os.O_RDWR) != 0}
+	if flag&os.O_APPEND != 0 {
+		file.offset = int(info.Size())
+	}
+	return file, nil
+}
+
+func (m *MemFileSystem) Truncate(name string, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.nodes[filepath.Clean(name)]
+	if !ok {
+		return &fs.PathError{Op: "truncate", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() {
+		return &fs.PathError{Op: "truncate", Path: name, Err: fs.ErrInvalid}
+	}
+	if size < int64(len(node.data)) {
+		node.data = node.data[:size]
+	} else {
+		node.data = append(node.data, make([]byte, size-int64(len(node.data)))...)
+	}
+	node.modTime = time.Now()
+	return nil
+}
+
+// memFile reads and writes the node of its MemFileSystem directly.
+type memFile struct {
+	fsys     *MemFileSystem
+	name     string
+	offset   int
+	dir      bool
+	writable bool
+	closed   bool
+}
+
+func (f *memFile) node(op string) (*memNode, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	node, ok := f.fsys.nodes[f.name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: f.name, Err: fs.ErrNotExist}
+	}
+	if f.dir {
+		return nil, &fs.PathError{Op: op, Path: f.name, Err: fs.ErrInvalid}
+	}
+	return node, nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+
+	node, err := f.node("read")
+	if err != nil {
+		return 0, err
+	}
+	if f.offset >= len(node.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, node.data[f.offset:])
+	f.offset += n
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+
+	node, err := f.node("write")
+	if err != nil {
+		return 0, err
+	}
+	if !f.writable {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+	}
+	if end := f.offset + len(p); end > len(node.data) {
+		node.data = append(node.data, make([]byte, end-len(node.data))...)
+	}
+	n := copy(node.data[f.offset:], p)
+	f.offset += n
+	node.modTime = time.Now()
+	return n, nil
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	return f.fsys.Stat(f.name)
+}
+
+func (f *memFile) Sync() error {
+	return nil
+}
+
+func (f *memFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+func (m *MemFileSystem) checkParent(op, name string) error {
+	parent, ok := m.nodes[filepath.Dir(name)]
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+func (m *MemFileSystem) hasChildren(name string) bool {
+	for p := range m.nodes {
+		if isWithin(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWithin reports whether p lies strictly below dir.
+func isWithin(dir, p string) bool {
+	if dir == "/" {
+		return p != "/"
+	}
+	return strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+// ancestors returns name and all of its parents, outermost first.
+func ancestors(name string) []string {
+	dirs := []string{}
+	for dir := name; ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	slices.Reverse(dirs)
+	return dirs
+}
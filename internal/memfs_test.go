@@ -0,0 +1,172 @@
+package wiper
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemFileSystem(t *testing.T) {
+	t.Run("green case - MkdirAll and WriteFile build a tree", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		require.NoError(t, sut.MkdirAll("/base/sub", 0o755))
+		require.NoError(t, sut.WriteFile("/base/sub/file.txt", []byte("content"), 0o644))
+
+		info, err := sut.Stat("/base/sub/file.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "file.txt", info.Name())
+		assert.Equal(t, int64(7), info.Size())
+		assert.False(t, info.IsDir())
+
+		info, err = sut.Lstat("/base/sub")
+		require.NoError(t, err)
+		assert.True(t, info.IsDir())
+	})
+
+	t.Run("ReadDir returns sorted direct children only", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		require.NoError(t, sut.MkdirAll("/base/b/nested", 0o755))
+		require.NoError(t, sut.WriteFile("/base/c.txt", nil, 0o644))
+		require.NoError(t, sut.WriteFile("/base/a.txt", nil, 0o644))
+
+		entries, err := sut.ReadDir("/base")
+		require.NoError(t, err)
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(t, []string{"a.txt", "b", "c.txt"}, names)
+		assert.True(t, entries[1].IsDir())
+	})
+
+	t.Run("red case - ReadDir on missing directory", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		_, err := sut.ReadDir("/missing")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("red case - WriteFile without parent", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		err := sut.WriteFile("/missing/file.txt", nil, 0o644)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("Remove refuses non-empty directories", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		require.NoError(t, sut.MkdirAll("/base", 0o755))
+		require.NoError(t, sut.WriteFile("/base/file.txt", nil, 0o644))
+
+		assert.Error(t, sut.Remove("/base"))
+		require.NoError(t, sut.Remove("/base/file.txt"))
+		require.NoError(t, sut.Remove("/base"))
+		assert.False(t, existsOn(sut, "/base"))
+	})
+
+	t.Run("RemoveAll removes the subtree but not siblings with a common prefix", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		require.NoError(t, sut.MkdirAll("/base/dir/nested", 0o755))
+		require.NoError(t, sut.MkdirAll("/base/dir2", 0o755))
+
+		require.NoError(t, sut.RemoveAll("/base/dir"))
+		assert.False(t, existsOn(sut, "/base/dir"))
+		assert.False(t, existsOn(sut, "/base/dir/nested"))
+		assert.True(t, existsOn(sut, "/base/dir2"))
+	})
+
+	t.Run("Rename moves a directory with its content", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		require.NoError(t, sut.MkdirAll("/base/src/nested", 0o755))
+		require.NoError(t, sut.WriteFile("/base/src/nested/file.txt", []byte("data"), 0o644))
+		require.NoError(t, sut.Mkdir("/trash", 0o700))
+
+		require.NoError(t, sut.Rename("/base/src", "/trash/src"))
+		assert.False(t, existsOn(sut, "/base/src"))
+		content, err := sut.ReadFile("/trash/src/nested/file.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(content))
+	})
+
+	t.Run("red case - Rename into own subtree", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		require.NoError(t, sut.MkdirAll("/base/src", 0o755))
+		assert.Error(t, sut.Rename("/base", "/base/src/base"))
+	})
+
+	t.Run("Create and Open read back written content", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		require.NoError(t, sut.MkdirAll("/base", 0o755))
+
+		file, err := sut.Create("/base/file.txt")
+		require.NoError(t, err)
+		_, err = file.Write([]byte("hello "))
+		require.NoError(t, err)
+		_, err = file.Write([]byte("world"))
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		file, err = sut.Open("/base/file.txt")
+		require.NoError(t, err)
+		content, err := io.ReadAll(file)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(content))
+		_, err = file.Write([]byte("read only"))
+		assert.ErrorIs(t, err, fs.ErrPermission)
+		require.NoError(t, file.Close())
+	})
+
+	t.Run("OpenFile writes in place and Truncate shortens", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		require.NoError(t, sut.MkdirAll("/base", 0o755))
+		require.NoError(t, sut.WriteFile("/base/file.txt", []byte("hello world"), 0o600))
+
+		file, err := sut.OpenFile("/base/file.txt", os.O_WRONLY, 0)
+		require.NoError(t, err)
+		_, err = file.Write([]byte("HELLO"))
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+		content, err := sut.ReadFile("/base/file.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "HELLO world", string(content))
+
+		require.NoError(t, sut.Truncate("/base/file.txt", 5))
+		content, err = sut.ReadFile("/base/file.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "HELLO", string(content))
+		info, err := sut.Stat("/base/file.txt")
+		require.NoError(t, err)
+		assert.Equal(t, fs.FileMode(0o600), info.Mode())
+
+		_, err = sut.OpenFile("/base/file.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		assert.ErrorIs(t, err, fs.ErrExist)
+		_, err = sut.OpenFile("/base/missing.txt", os.O_WRONLY, 0)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("Chown and Chmod change the metadata", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		require.NoError(t, sut.WriteFile("/file.txt", nil, 0o644))
+		require.NoError(t, sut.Chown("/file.txt", 4242, 42))
+		require.NoError(t, sut.Chmod("/file.txt", fs.ModeSetuid|0o755))
+
+		info, err := sut.Lstat("/file.txt")
+		require.NoError(t, err)
+		assert.Equal(t, fs.ModeSetuid|0o755, info.Mode())
+		uid, gid, ok := fileOwner(info)
+		assert.True(t, ok)
+		assert.Equal(t, uint32(4242), uid)
+		assert.Equal(t, uint32(42), gid)
+		assert.ErrorIs(t, sut.Chown("/missing", 0, 0), fs.ErrNotExist)
+	})
+
+	t.Run("red case - Open missing file and Readlink", func(t *testing.T) {
+		sut := NewMemFileSystem()
+		_, err := sut.Open("/missing")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = sut.Readlink("/")
+		assert.Error(t, err)
+	})
+}
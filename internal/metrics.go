@@ -0,0 +1,131 @@
+package wiper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lastSuccessMetric = "wiper_last_success_timestamp_seconds"
+
+// Metrics is the outcome of a run in the format of the node_exporter textfile
+// collector.
+type Metrics struct {
+	// Profile is the name of the config file, see ProfileName.
+	Profile string
+	Run     HistoryRun
+	// Errors counts the errors of the run by operation, see Report.ErrorsByOp.
+	Errors  map[string]int
+	Success bool
+}
+
+// ProfileName returns the profile of a config file, i.e. its name without
+// extension. The default config file and no config file are the profile
+// "default".
+func ProfileName(configFile string) string {
+	name := strings.TrimSuffix(filepath.Base(configFile), filepath.Ext(configFile))
+	if configFile == "" || name == configFileName {
+		return "default"
+	}
+	return name
+}
+
+// ErrorsByOp counts the errors by the operation that failed.
+func (r *Report) ErrorsByOp() map[string]int {
+	counts := map[string]int{}
+	for _, e := range r.Errors {
+		counts[e.Op]++
+	}
+	return counts
+}
+
+// WriteFile replaces path with the metrics. The file is written next to path
+// and renamed, so the collector never reads a partial file. The time of the
+// last successful run is kept from the previous file if the run failed.
+func (m Metrics) WriteFile(path string) error {
+	lastSuccess := float64(0)
+	if m.Success {
+		lastSuccess = unixSeconds(m.Run.Finished)
+	} else if previous, ok := readMetric(path, lastSuccessMetric); ok {
+		lastSuccess = previous
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	out := bufio.NewWriter(tmp)
+	m.write(out, lastSuccess)
+	if err := errors.Join(out.Flush(), tmp.Chmod(0o644), tmp.Close()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (m Metrics) write(out *bufio.Writer, lastSuccess float64) {
+	labels := fmt.Sprintf(`profile="%s",base_dir="%s"`, escapeLabel(m.Profile), escapeLabel(strings.Join(m.Run.BaseDirs, ",")))
+	metric := func(name, kind, help string, value float64) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n%s{%s} %s\n", name, help, name, kind, name, labels, formatValue(value))
+	}
+	metric("wiper_inspected_files", "gauge", "Files inspected by the last run.", float64(m.Run.InspectedFiles))
+	metric("wiper_inspected_dirs", "gauge", "Directories inspected by the last run.", float64(m.Run.InspectedDirs))
+	metric("wiper_wiped_files", "gauge", "Files wiped by the last run.", float64(m.Run.WipedFiles))
+	metric("wiper_wiped_dirs", "gauge", "Directories wiped by the last run.", float64(m.Run.WipedDirs))
+	metric("wiper_reclaimed_bytes", "gauge", "Bytes reclaimed by the last run.", float64(m.Run.WipedBytes))
+
+	fmt.Fprintln(out, "# HELP wiper_errors Errors of the last run by operation.")
+	fmt.Fprintln(out, "# TYPE wiper_errors gauge")
+	for _, op := range slices.Sorted(maps.Keys(m.Errors)) {
+		fmt.Fprintf(out, "wiper_errors{%s,op=\"%s\"} %d\n", labels, escapeLabel(op), m.Errors[op])
+	}
+
+	metric("wiper_run_duration_seconds", "gauge", "Duration of the last run.", m.Run.Duration().Seconds())
+	success := 0.0
+	if m.Success {
+		success = 1
+	}
+	metric("wiper_last_run_success", "gauge", "Whether the last run succeeded.", success)
+	metric("wiper_last_run_timestamp_seconds", "gauge", "Time the last run finished.", unixSeconds(m.Run.Finished))
+	metric(lastSuccessMetric, "gauge", "Time the last successful run finished.", lastSuccess)
+}
+
+// readMetric returns the value of the first sample of name in the textfile
+// at path.
+func readMetric(path, name string) (float64, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, name+"{") && !strings.HasPrefix(line, name+" ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		value, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		return value, err == nil
+	}
+	return 0, false
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
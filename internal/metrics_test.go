@@ -0,0 +1,80 @@
+package wiper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	finished := time.Date(2024, 3, 5, 10, 15, 0, 500_000_000, time.UTC)
+	run := HistoryRun{
+		Started:        finished.Add(-1500 * time.Millisecond),
+		Finished:       finished,
+		BaseDirs:       []string{`/home/me/"projects"`},
+		InspectedFiles: 10,
+		InspectedDirs:  3,
+		WipedFiles:     2,
+		WipedDirs:      1,
+		WipedBytes:     4096,
+	}
+
+	t.Run("green case - textfile of a successful run", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "wiper.prom")
+		require.NoError(t, Metrics{Profile: "projects", Run: run, Errors: map[string]int{}, Success: true}.WriteFile(path))
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		labels := `{profile="projects",base_dir="/home/me/\"projects\""}`
+		for _, sample := range []string{
+			"wiper_inspected_files" + labels + " 10\n",
+			"wiper_inspected_dirs" + labels + " 3\n",
+			"wiper_wiped_files" + labels + " 2\n",
+			"wiper_wiped_dirs" + labels + " 1\n",
+			"wiper_reclaimed_bytes" + labels + " 4096\n",
+			"wiper_run_duration_seconds" + labels + " 1.5\n",
+			"wiper_last_run_success" + labels + " 1\n",
+			"wiper_last_run_timestamp_seconds" + labels + " 1709633700.5\n",
+			"wiper_last_success_timestamp_seconds" + labels + " 1709633700.5\n",
+			"# TYPE wiper_errors gauge\n",
+		} {
+			assert.Contains(t, string(content), sample)
+		}
+		assert.NotContains(t, string(content), "wiper_errors{")
+		entries, err := os.ReadDir(filepath.Dir(path))
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("failed run keeps the last success", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "wiper.prom")
+		require.NoError(t, Metrics{Profile: "default", Run: run, Success: true}.WriteFile(path))
+
+		failed := run
+		failed.Finished = finished.Add(time.Hour)
+		require.NoError(t, Metrics{Profile: "default", Run: failed, Errors: map[string]int{"remove": 2, "trash": 1}}.WriteFile(path))
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), `wiper_errors{profile="default",base_dir="/home/me/\"projects\"",op="remove"} 2`)
+		assert.Contains(t, string(content), `wiper_errors{profile="default",base_dir="/home/me/\"projects\"",op="trash"} 1`)
+		assert.Contains(t, string(content), `wiper_last_run_success{profile="default",base_dir="/home/me/\"projects\""} 0`)
+		assert.Contains(t, string(content), `wiper_last_run_timestamp_seconds{profile="default",base_dir="/home/me/\"projects\""} 1709637300.5`)
+		assert.Contains(t, string(content), `wiper_last_success_timestamp_seconds{profile="default",base_dir="/home/me/\"projects\""} 1709633700.5`)
+	})
+
+	t.Run("profile names", func(t *testing.T) {
+		assert.Equal(t, "default", ProfileName(""))
+		assert.Equal(t, "default", ProfileName("/home/me/.config/wiper/config.yaml"))
+		assert.Equal(t, "projects", ProfileName("/home/me/.config/wiper/projects.yml"))
+	})
+
+	t.Run("red case - directory of the file is missing", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing", "wiper.prom")
+		assert.Error(t, Metrics{Run: run, Success: true}.WriteFile(path))
+	})
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package wiper
+
+const ownersSupported = false
+
+func sysOwner(any) (uint32, uint32, bool) {
+	return 0, 0, false
+}
+
+func sysLinks(any) (uint64, bool) {
+	return 0, false
+}
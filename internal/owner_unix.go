@@ -0,0 +1,24 @@
+//go:build unix
+
+package wiper
+
+import "syscall"
+
+const ownersSupported = true
+
+func sysOwner(sys any) (uint32, uint32, bool) {
+	stat, ok := sys.(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return stat.Uid, stat.Gid, true
+}
+
+// sysLinks returns the number of hard links of a file, if sys tells it.
+func sysLinks(sys any) (uint64, bool) {
+	stat, ok := sys.(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Nlink), true
+}
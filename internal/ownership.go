@@ -0,0 +1,231 @@
+package wiper
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// modeBits are the names accepted besides octal numbers in requires_mode and
+// unless_mode.
+var modeBits = map[string]fs.FileMode{
+	"setuid":         fs.ModeSetuid,
+	"setgid":         fs.ModeSetgid,
+	"sticky":         fs.ModeSticky,
+	"user_writable":  0o200,
+	"group_writable": 0o020,
+	"world_writable": 0o002,
+	"world_readable": 0o004,
+	"executable":     0o111,
+}
+
+// Ownership conditions on the owner, group and permission bits of an entry.
+type Ownership struct {
+	// Owner needs the entry to be owned by one of these user names or uids.
+	Owner []string `json:"owner,omitempty" mapstructure:"owner" yaml:"owner"`
+	// Group needs the entry to belong to one of these group names or gids.
+	Group []string `json:"group,omitempty" mapstructure:"group" yaml:"group"`
+	// RequiresMode needs all of these bits to be set.
+	RequiresMode []string `json:"requires_mode,omitempty" mapstructure:"requires_mode" yaml:"requires_mode"`
+	// UnlessMode forbids any of these bits to be set.
+	UnlessMode   []string `json:"unless_mode,omitempty" mapstructure:"unless_mode" yaml:"unless_mode"`
+	uids         []uint32
+	gids         []uint32
+	requiredBits fs.FileMode
+	forbidden    fs.FileMode
+}
+
+// ownerInfo is what Sys returns for the files of a MemFileSystem.
+type ownerInfo struct {
+	uid, gid uint32
+}
+
+func (o *Ownership) compile() error {
+	var err error
+	if o.uids, err = lookupIDs(o.Owner, func(name string) (string, error) {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", err
+		}
+		return u.Uid, nil
+	}); err != nil {
+		return fmt.Errorf("owner: %w", err)
+	}
+	if o.gids, err = lookupIDs(o.Group, func(name string) (string, error) {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return "", err
+		}
+		return g.Gid, nil
+	}); err != nil {
+		return fmt.Errorf("group: %w", err)
+	}
+	if (len(o.uids) > 0 || len(o.gids) > 0) && !ownersSupported {
+		return fmt.Errorf("owner and group are not supported on this platform")
+	}
+	if o.requiredBits, err = parseModeBits(o.RequiresMode); err != nil {
+		return fmt.Errorf("requires_mode: %w", err)
+	}
+	if o.forbidden, err = parseModeBits(o.UnlessMode); err != nil {
+		return fmt.Errorf("unless_mode: %w", err)
+	}
+	return nil
+}
+
+func lookupIDs(names []string, lookup func(string) (string, error)) ([]uint32, error) {
+	ids := make([]uint32, 0, len(names))
+	for _, name := range names {
+		id, err := strconv.ParseUint(name, 10, 32)
+		if err != nil {
+			resolved, lookupErr := lookup(name)
+			if lookupErr != nil {
+				return nil, lookupErr
+			}
+			if id, err = strconv.ParseUint(resolved, 10, 32); err != nil {
+				return nil, fmt.Errorf("%s has no numeric id", name)
+			}
+		}
+		ids = append(ids, uint32(id))
+	}
+	return ids, nil
+}
+
+// parseModeBits combines octal permission bits such as 0002 or 4000 and the
+// names of modeBits into one FileMode.
+func parseModeBits(values []string) (fs.FileMode, error) {
+	var mode fs.FileMode
+	for _, value := range values {
+		if bits, ok := modeBits[value]; ok {
+			mode |= bits
+			continue
+		}
+		octal, err := strconv.ParseUint(value, 8, 32)
+		if err != nil || octal > 0o7777 {
+			names := slices.Sorted(maps.Keys(modeBits))
+			return 0, fmt.Errorf("invalid mode %q, use octal bits or one of %s", value, strings.Join(names, ", "))
+		}
+		mode |= fs.FileMode(octal & 0o777)
+		if octal&0o4000 != 0 {
+			mode |= fs.ModeSetuid
+		}
+		if octal&0o2000 != 0 {
+			mode |= fs.ModeSetgid
+		}
+		if octal&0o1000 != 0 {
+			mode |= fs.ModeSticky
+		}
+	}
+	return mode, nil
+}
+
+func (o *Ownership) isEmpty() bool {
+	return len(o.uids) == 0 && len(o.gids) == 0 && o.requiredBits == 0 && o.forbidden == 0
+}
+
+// matches checks the conditions against the metadata of e.
+func (o *Ownership) matches(e *entry) (bool, error) {
+	if o.isEmpty() {
+		return true, nil
+	}
+	info, err := e.stat()
+	if err != nil {
+		return false, err
+	}
+	mode := info.Mode()
+	if mode&o.requiredBits != o.requiredBits || mode&o.forbidden != 0 {
+		return false, nil
+	}
+	if len(o.uids) == 0 && len(o.gids) == 0 {
+		return true, nil
+	}
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		return false, nil
+	}
+	if len(o.uids) > 0 && !slices.Contains(o.uids, uid) {
+		return false, nil
+	}
+	return len(o.gids) == 0 || slices.Contains(o.gids, gid), nil
+}
+
+// fileOwner returns the uid and gid of info, if the file system knows them.
+func fileOwner(info fs.FileInfo) (uint32, uint32, bool) {
+	if owner, ok := info.Sys().(ownerInfo); ok {
+		return owner.uid, owner.gid, true
+	}
+	return sysOwner(info.Sys())
+}
+
+// currentUID returns the effective uid of the process.
+func currentUID() uint32 {
+	return uint32(os.Geteuid())
+}
+
+// ownerProtected returns why only_owned_by_current_user forbids wiping
+// target, or an empty string if it may be wiped. Directories are only wiped
+// if everything inside them is owned by the current user as well.
+func (w *Wiper) ownerProtected(target string, isDir bool) string {
+	if !w.OnlyOwnedByCurrentUser {
+		return ""
+	}
+	uid := currentUID()
+	check := func(name string) string {
+		info, err := w.fs().Lstat(name)
+		if err != nil {
+			return fmt.Sprintf("owner of %s unknown: %s", name, err)
+		}
+		owner, _, ok := fileOwner(info)
+		switch {
+		case !ok:
+			return fmt.Sprintf("owner of %s unknown", name)
+		case owner != uid:
+			if name == target {
+				return "not owned by current user"
+			}
+			return fmt.Sprintf("%s not owned by current user", name)
+		}
+		return ""
+	}
+	if reason := check(target); reason != "" || !isDir {
+		return reason
+	}
+
+	var walk func(dir string) string
+	walk = func(dir string) string {
+		entries, err := w.fs().ReadDir(dir)
+		if err != nil {
+			return fmt.Sprintf("owner of %s unknown: %s", dir, err)
+		}
+		for _, entry := range entries {
+			name := filepath.Join(dir, entry.Name())
+			if reason := check(name); reason != "" {
+				return reason
+			}
+			if entry.IsDir() {
+				if reason := walk(name); reason != "" {
+					return reason
+				}
+			}
+		}
+		return ""
+	}
+	return walk(target)
+}
+
+// protected returns why target must not be wiped although a rule matched it,
+// or an empty string if it may be wiped.
+func (w *Wiper) protected(sc *scope, target string, isDir bool) string {
+	if w.holdsAuditLog(target) {
+		return "audit log"
+	}
+	if reason := w.gitProtected(sc, target, isDir); reason != "" {
+		return reason
+	}
+	return w.ownerProtected(target, isDir)
+}
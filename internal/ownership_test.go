@@ -0,0 +1,149 @@
+package wiper
+
+import (
+	"io/fs"
+	"os/user"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReadDirFS counts the directories listed.
+type countingReadDirFS struct {
+	*MemFileSystem
+	listed *int
+}
+
+func (f countingReadDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	*f.listed++
+	return f.MemFileSystem.ReadDir(name)
+}
+
+func TestOwnership(t *testing.T) {
+	const otherUID = 4242
+	me := int(currentUID())
+
+	files := map[string]string{
+		"/base/mine.log":           "",
+		"/base/theirs.log":         "",
+		"/base/world.log":          "",
+		"/base/suid.log":           "",
+		"/base/mine/build/out.o":   "",
+		"/base/shared/build/out.o": "",
+	}
+
+	wipedPaths := func(events []Event) []string {
+		paths := []string{}
+		for _, event := range eventsOfType(events, EventWiped) {
+			paths = append(paths, event.Path)
+		}
+		return paths
+	}
+
+	t.Run("green case - owner and mode conditions", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.Chown("/base/theirs.log", otherUID, otherUID))
+		require.NoError(t, fsys.Chmod("/base/theirs.log", 0o666))
+		require.NoError(t, fsys.Chmod("/base/world.log", 0o666))
+		require.NoError(t, fsys.Chmod("/base/suid.log", fs.ModeSetuid|0o766))
+		sut := &Wiper{
+			Rules: []Rule{{
+				Name:     "world-writable",
+				Patterns: []string{`\.log$`},
+				Ownership: Ownership{
+					Owner:        []string{strconv.Itoa(me)},
+					RequiresMode: []string{"world_writable"},
+					UnlessMode:   []string{"setuid"},
+				},
+			}},
+			BaseDir: "/base",
+			FS:      fsys,
+		}
+		assert.Equal(t, []string{"/base/world.log"}, wipedPaths(collectEvents(sut)))
+	})
+
+	t.Run("owner by uid and group", func(t *testing.T) {
+		rule := Rule{Patterns: []string{`\.log$`}, Ownership: Ownership{Owner: []string{"4242"}, Group: []string{"4242"}}}
+		require.NoError(t, rule.compile())
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.Chown("/base/theirs.log", otherUID, otherUID))
+
+		matched, err := rule.matches(newEntry(fsys, "/base/theirs.log", false))
+		require.NoError(t, err)
+		assert.True(t, matched)
+		matched, err = rule.matches(newEntry(fsys, "/base/mine.log", false))
+		require.NoError(t, err)
+		assert.False(t, matched)
+	})
+
+	t.Run("owner by name", func(t *testing.T) {
+		current, err := user.Current()
+		if err != nil {
+			t.Skip("current user unknown:", err)
+		}
+		rule := Rule{Names: []string{"mine.log"}, Ownership: Ownership{Owner: []string{current.Username}}}
+		require.NoError(t, rule.compile())
+
+		matched, err := rule.matches(newEntry(memTree(t, files), "/base/mine.log", false))
+		require.NoError(t, err)
+		assert.True(t, matched)
+	})
+
+	t.Run("only_owned_by_current_user", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.Chown("/base/theirs.log", otherUID, otherUID))
+		require.NoError(t, fsys.Chown("/base/shared/build/out.o", otherUID, otherUID))
+		sut := &Wiper{
+			WipeOutPattern:         []string{`\.log$`},
+			WipeOutDirs:            []string{"build"},
+			OnlyOwnedByCurrentUser: true,
+			BaseDir:                "/base",
+			FS:                     fsys,
+		}
+
+		events := collectEvents(sut)
+		assert.ElementsMatch(t, []string{"/base/mine.log", "/base/world.log", "/base/suid.log", "/base/mine/build"}, wipedPaths(events))
+		skipped := eventsOfType(events, EventSkipped)
+		assert.Contains(t, skipped, Event{Type: EventSkipped, Path: "/base/theirs.log", Rule: "wipe_out_pattern", Reason: "not owned by current user"})
+		assert.Contains(t, skipped, Event{Type: EventSkipped, Path: "/base/shared/build", IsDir: true, Rule: "wipe_out_dirs", Reason: "/base/shared/build/out.o not owned by current user"})
+		assert.True(t, existsOn(fsys, "/base/shared/build/out.o"))
+	})
+
+	t.Run("only_owned_by_current_user lists unmatched directories once", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		deep := "/base"
+		for i := 0; i < 30; i++ {
+			deep = path.Join(deep, "d"+strconv.Itoa(i))
+		}
+		require.NoError(t, fsys.MkdirAll(deep, 0o755))
+		listed := 0
+		sut := &Wiper{WipeOutDirs: []string{"build"}, OnlyOwnedByCurrentUser: true, BaseDir: "/base", FS: countingReadDirFS{fsys, &listed}}
+
+		collectEvents(sut)
+		assert.Equal(t, 31, listed)
+	})
+
+	t.Run("parseModeBits", func(t *testing.T) {
+		mode, err := parseModeBits([]string{"4000", "0002"})
+		require.NoError(t, err)
+		assert.Equal(t, fs.ModeSetuid|0o002, mode)
+		mode, err = parseModeBits([]string{"sticky", "executable"})
+		require.NoError(t, err)
+		assert.Equal(t, fs.ModeSticky|0o111, mode)
+	})
+
+	t.Run("red case - invalid ownership conditions", func(t *testing.T) {
+		for _, rule := range []Rule{
+			{Names: []string{"x"}, Ownership: Ownership{Owner: []string{"no-such-user-for-wiper"}}},
+			{Names: []string{"x"}, Ownership: Ownership{Group: []string{"no-such-group-for-wiper"}}},
+			{Names: []string{"x"}, Ownership: Ownership{RequiresMode: []string{"rwx"}}},
+			{Names: []string{"x"}, Ownership: Ownership{UnlessMode: []string{"17777"}}},
+		} {
+			sut := Wiper{Rules: []Rule{rule}}
+			assert.Error(t, sut.Validate(), rule)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package wiper
+
+import (
+	"fmt"
+	"sort"
+)
+
+// presets are the built-in rule sets which can be enabled with preset. Their
+// rules only match next to, or around, the marker files of the project type,
+// so common names like target or build are left alone elsewhere.
+var presets = map[string][]Rule{
+	"node": {
+		{Names: []string{"node_modules", ".next", ".nuxt", ".parcel-cache", ".turbo"}, Dirs: true, Conditions: Conditions{RequiresSibling: []string{"package.json"}}},
+	},
+	"rust": {
+		{Names: []string{"target"}, Dirs: true, Conditions: Conditions{RequiresSibling: []string{"Cargo.toml"}}},
+	},
+	"go": {
+		{Patterns: []string{`\.test$`, `^(cover|coverage|profile)\.out$`, `^(cpu|mem|block|mutex)\.prof$`}, Conditions: Conditions{RequiresSibling: []string{"*.go", "go.mod"}}},
+	},
+	"python": {
+		{Names: []string{"__pycache__", ".pytest_cache", ".mypy_cache", ".ruff_cache"}, Dirs: true},
+		{Names: []string{".venv", "venv"}, Dirs: true, Conditions: Conditions{RequiresChild: []string{"pyvenv.cfg"}}},
+		{Names: []string{".tox", ".nox"}, Dirs: true, Conditions: Conditions{RequiresSibling: []string{"tox.ini", "noxfile.py", "pyproject.toml", "setup.cfg", "setup.py"}}},
+		{Patterns: []string{`\.egg-info$`}, Dirs: true, Conditions: Conditions{RequiresSibling: []string{"pyproject.toml", "setup.cfg", "setup.py"}}},
+	},
+	"java-maven": {
+		{Names: []string{"target"}, Dirs: true, Conditions: Conditions{RequiresSibling: []string{"pom.xml"}}},
+	},
+	"gradle": {
+		{Names: []string{"build", ".gradle"}, Dirs: true, Conditions: Conditions{RequiresSibling: []string{"build.gradle", "build.gradle.kts", "settings.gradle", "settings.gradle.kts"}}},
+	},
+	"xcode": {
+		{Names: []string{"build", "DerivedData"}, Dirs: true, Conditions: Conditions{RequiresSibling: []string{"*.xcodeproj", "*.xcworkspace"}}},
+	},
+}
+
+// PresetNames returns the names of the built-in presets.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func validatePresets(names []string) error {
+	for _, name := range names {
+		if _, ok := presets[name]; !ok {
+			return fmt.Errorf("unknown preset %q, expected one of %v", name, PresetNames())
+		}
+	}
+	return nil
+}
+
+// presetRules returns the rules of the enabled presets, named after their
+// preset.
+func (w *Wiper) presetRules() []*Rule {
+	rules := []*Rule{}
+	for _, name := range w.Presets {
+		for _, rule := range presets[name] {
+			rule.Name = name
+			rules = append(rules, &rule)
+		}
+	}
+	return rules
+}
@@ -0,0 +1,97 @@
+package wiper
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresets(t *testing.T) {
+	files := map[string]string{
+		"/home/user/":                      "",
+		"/base/web/package.json":           "",
+		"/base/web/node_modules/left-pad/": "",
+		"/base/notes/node_modules/":        "",
+		"/base/crate/Cargo.toml":           "",
+		"/base/crate/target/debug/":        "",
+		"/base/photos/target/":             "",
+		"/base/service/pom.xml":            "",
+		"/base/service/target/classes/":    "",
+		"/base/tool/.venv/bin/":            "",
+		"/base/tool/.venv/pyvenv.cfg":      "",
+		"/base/tool/venv/":                 "",
+		"/base/tool/__pycache__/":          "",
+		"/base/tool/main.py":               "",
+		"/base/app/build/":                 "",
+		"/base/app/App.xcodeproj/":         "",
+		"/base/cli/main.go":                "",
+		"/base/cli/cli.test":               "",
+		"/base/scripts/run.test":           "",
+	}
+
+	wipedPaths := func(events []Event) []string {
+		paths := []string{}
+		for _, event := range eventsOfType(events, EventWiped) {
+			paths = append(paths, event.Path)
+		}
+		return paths
+	}
+
+	t.Run("green case - artifacts are only wiped next to their markers", func(t *testing.T) {
+		fsys := memTree(t, files)
+		sut := &Wiper{Presets: []string{"node", "rust", "python", "go"}, BaseDir: "/base", FS: fsys}
+
+		wiped := wipedPaths(collectEvents(sut))
+		assert.ElementsMatch(t, []string{
+			"/base/web/node_modules",
+			"/base/crate/target",
+			"/base/tool/.venv",
+			"/base/tool/__pycache__",
+			"/base/cli/cli.test",
+		}, wiped)
+		assert.True(t, existsOn(fsys, "/base/notes/node_modules"))
+		assert.True(t, existsOn(fsys, "/base/photos/target"))
+		assert.True(t, existsOn(fsys, "/base/service/target"))
+		assert.True(t, existsOn(fsys, "/base/tool/venv"))
+		assert.True(t, existsOn(fsys, "/base/scripts/run.test"))
+	})
+
+	t.Run("presets compose with rules", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := memTree(t, files)
+		sut := &Wiper{
+			Presets: []string{"java-maven", "xcode"},
+			Rules:   []Rule{{Name: "xcode-build", Names: []string{"build"}, Dirs: true, Action: ActionTrash}},
+			BaseDir: "/base",
+			FS:      fsys,
+		}
+
+		events := collectEvents(sut)
+		assert.Equal(t, []string{"/base/service/target"}, wipedPaths(events))
+		trashed := eventsOfType(events, EventTrashed)
+		require.Len(t, trashed, 1)
+		assert.Equal(t, "xcode-build", trashed[0].Rule)
+		matched := eventsOfType(events, EventMatched)
+		assert.Contains(t, matched, Event{Type: EventMatched, Path: "/base/service/target", IsDir: true, Rule: "java-maven"})
+	})
+
+	t.Run("a single preset can be given as string", func(t *testing.T) {
+		viper.Reset()
+		t.Cleanup(viper.Reset)
+		viper.Set("preset", "node")
+		require.NoError(t, refreshInstanceFromViper())
+		assert.Equal(t, []string{"node"}, GetInstance().Presets)
+
+		viper.Set("preset", []string{"rust", "gradle"})
+		require.NoError(t, refreshInstanceFromViper())
+		assert.Equal(t, []string{"rust", "gradle"}, GetInstance().Presets)
+	})
+
+	t.Run("red case - unknown preset", func(t *testing.T) {
+		sut := Wiper{Presets: []string{"cobol"}}
+		assert.ErrorContains(t, sut.Validate(), `unknown preset "cobol"`)
+		assert.Contains(t, PresetNames(), "java-maven")
+	})
+}
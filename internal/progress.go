@@ -0,0 +1,27 @@
+package wiper
+
+// Progress is a snapshot of the counters of a running wipe.
+type Progress struct {
+	InspectedFiles int
+	InspectedDirs  int
+	WipedFiles     int
+	WipedDirs      int
+	WipedBytes     int64
+	// CurrentDir is the directory entered last.
+	CurrentDir string
+}
+
+// Progress returns the current counters. It is safe to call while WipeFiles
+// runs.
+func (w *Wiper) Progress() Progress {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return Progress{
+		InspectedFiles: w.InspectedFiles,
+		InspectedDirs:  w.InspectedDirs,
+		WipedFiles:     w.WipedFiles,
+		WipedDirs:      w.WipedDirs,
+		WipedBytes:     w.WipedBytes,
+		CurrentDir:     w.currentDir,
+	}
+}
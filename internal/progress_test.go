@@ -0,0 +1,28 @@
+package wiper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	t.Run("green case - counters of the run", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base/sub", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/sub/a.orig", []byte("abcd"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/b.txt", nil, 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, BaseDir: "/base", FS: fsys}
+		assert.Equal(t, Progress{}, sut.Progress())
+
+		collectEvents(sut)
+		assert.Equal(t, Progress{
+			InspectedFiles: 2,
+			InspectedDirs:  2,
+			WipedFiles:     1,
+			WipedBytes:     4,
+			CurrentDir:     "/base/sub",
+		}, sut.Progress())
+	})
+}
@@ -0,0 +1,152 @@
+package wiper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// QuarantineEntry is one line of the manifest written next to the directory
+// of a quarantine run.
+type QuarantineEntry struct {
+	OriginalPath   string    `json:"original_path"`
+	QuarantinePath string    `json:"quarantine_path"`
+	Rule           string    `json:"rule"`
+	IsDir          bool      `json:"is_dir"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+type quarantineRun struct {
+	dir      string
+	manifest File
+	encoder  *json.Encoder
+}
+
+// quarantineEntry moves target below the directory of the current run in
+// QuarantineDir, keeping its path relative to BaseDir, and records the move in
+// the manifest of the run. If the path was quarantined before in the same run,
+// e.g. by watch, the new entry gets a numbered suffix. It returns the new
+// location of target.
+func (w *Wiper) quarantineEntry(target, rule string, isDir bool) (string, error) {
+	name, err := filepath.Rel(w.BaseDir, target)
+	if err != nil {
+		return "", err
+	}
+
+	w.quarantineMu.Lock()
+	defer w.quarantineMu.Unlock()
+
+	if w.quarantine == nil {
+		if w.quarantine, err = newQuarantineRun(w.fs(), w.QuarantineDir); err != nil {
+			return "", err
+		}
+	}
+
+	destination := freeDestination(w.fs(), filepath.Join(w.quarantine.dir, name), isDir)
+	if err := mkdirAll(w.fs(), filepath.Dir(destination), 0o700); err != nil {
+		return "", err
+	}
+	if err := moveEntry(w.fs(), target, destination); err != nil {
+		return "", err
+	}
+
+	return destination, w.quarantine.encoder.Encode(QuarantineEntry{
+		OriginalPath:   target,
+		QuarantinePath: destination,
+		Rule:           rule,
+		IsDir:          isDir,
+		Timestamp:      time.Now(),
+	})
+}
+
+// freeDestination returns destination, or the first name with a numbered
+// suffix nothing exists at if it is taken.
+func freeDestination(fsys FileSystem, destination string, isDir bool) string {
+	candidate := destination
+	for i := 1; ; i++ {
+		if _, err := fsys.Lstat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+		candidate = filepath.Join(filepath.Dir(destination), trashNameWithPostfix(filepath.Base(destination), strconv.Itoa(i), isDir))
+	}
+}
+
+// moveEntry renames source to destination. If destination is on another
+// mount, source is copied and removed afterwards.
+func moveEntry(fsys FileSystem, source, destination string) error {
+	err := fsys.Rename(source, destination)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyEntry(fsys, source, destination); err != nil {
+		return errors.Join(err, fsys.RemoveAll(destination))
+	}
+	return fsys.RemoveAll(source)
+}
+
+// copyEntry copies a regular file or a directory tree of them.
+func copyEntry(fsys FileSystem, source, destination string) error {
+	info, err := fsys.Lstat(source)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.IsDir():
+		if err := fsys.Mkdir(destination, 0o700); err != nil {
+			return err
+		}
+		entries, err := fsys.ReadDir(source)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyEntry(fsys, filepath.Join(source, entry.Name()), filepath.Join(destination, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	case info.Mode().IsRegular():
+		dst, err := fsys.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		err = copyFile(fsys, dst, source)
+		return errors.Join(err, dst.Sync(), dst.Close())
+	}
+	return fmt.Errorf("%s can't be copied to another mount, it is a %s", source, info.Mode().Type())
+}
+
+// closeQuarantine flushes the manifest of the current run, if one was started.
+func (w *Wiper) closeQuarantine() error {
+	w.quarantineMu.Lock()
+	defer w.quarantineMu.Unlock()
+
+	if w.quarantine == nil {
+		return nil
+	}
+	manifest := w.quarantine.manifest
+	w.quarantine = nil
+	return errors.Join(manifest.Sync(), manifest.Close())
+}
+
+// newQuarantineRun creates <dir>/<timestamp> and the manifest
+// <dir>/<timestamp>.manifest.jsonl beside it, so the run directory can be
+// synced back to base_dir as it is.
+func newQuarantineRun(fsys FileSystem, dir string) (*quarantineRun, error) {
+	runDir := filepath.Join(dir, time.Now().Format("20060102-150405.000"))
+	if err := mkdirAll(fsys, runDir, 0o700); err != nil {
+		return nil, err
+	}
+
+	manifest, err := fsys.Create(runDir + ".manifest.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	return &quarantineRun{dir: runDir, manifest: manifest, encoder: json.NewEncoder(manifest)}, nil
+}
@@ -0,0 +1,210 @@
+package wiper
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantineAction(t *testing.T) {
+	t.Run("green case - tree layout is mirrored and recorded in the manifest", func(t *testing.T) {
+		baseDir := t.TempDir()
+		quarantineDir := filepath.Join(t.TempDir(), "quarantine")
+		require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "a", "node_modules", "pkg"), 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "b"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(baseDir, "a", "node_modules", "pkg", "index.js"), []byte("js"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(baseDir, "a", "file.orig"), []byte("a"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(baseDir, "b", "file.orig"), []byte("b"), 0o644))
+
+		sut := &Wiper{
+			WipeOutPattern: []string{`.*\.orig$`},
+			WipeOutDirs:    []string{"node_modules"},
+			BaseDir:        baseDir,
+			Action:         ActionQuarantine,
+			QuarantineDir:  quarantineDir,
+		}
+
+		events := collectEvents(sut)
+		assert.Empty(t, eventsOfType(events, EventError))
+		quarantined := eventsOfType(events, EventQuarantined)
+		require.Len(t, quarantined, 3)
+
+		entries, err := os.ReadDir(quarantineDir)
+		require.NoError(t, err)
+		require.Len(t, entries, 2, "expected the run directory and its manifest")
+		runDir := filepath.Join(quarantineDir, entries[0].Name())
+		manifestPath := filepath.Join(quarantineDir, entries[1].Name())
+		assert.True(t, entries[0].IsDir())
+		assert.Equal(t, runDir+".manifest.jsonl", manifestPath)
+
+		content, err := os.ReadFile(filepath.Join(runDir, "a", "file.orig"))
+		require.NoError(t, err)
+		assert.Equal(t, "a", string(content))
+		content, err = os.ReadFile(filepath.Join(runDir, "b", "file.orig"))
+		require.NoError(t, err)
+		assert.Equal(t, "b", string(content))
+		assert.FileExists(t, filepath.Join(runDir, "a", "node_modules", "pkg", "index.js"))
+		assert.NoDirExists(t, filepath.Join(baseDir, "a", "node_modules"))
+		assert.NoFileExists(t, filepath.Join(baseDir, "a", "file.orig"))
+
+		manifest, err := os.Open(manifestPath)
+		require.NoError(t, err)
+		defer manifest.Close()
+		records := map[string]QuarantineEntry{}
+		scanner := bufio.NewScanner(manifest)
+		for scanner.Scan() {
+			record := QuarantineEntry{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+			records[record.OriginalPath] = record
+		}
+		require.Len(t, records, 3)
+
+		record := records[filepath.Join(baseDir, "a", "node_modules")]
+		assert.Equal(t, filepath.Join(runDir, "a", "node_modules"), record.QuarantinePath)
+		assert.Equal(t, "wipe_out_dirs", record.Rule)
+		assert.True(t, record.IsDir)
+		assert.False(t, record.Timestamp.IsZero())
+		assert.Equal(t, "wipe_out_pattern", records[filepath.Join(baseDir, "b", "file.orig")].Rule)
+	})
+
+	t.Run("memory file system", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base/sub", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/sub/file.orig", []byte("data"), 0o644))
+
+		sut := &Wiper{
+			WipeOutPattern: []string{`.*\.orig$`},
+			BaseDir:        "/base",
+			Action:         ActionQuarantine,
+			QuarantineDir:  "/quarantine",
+			FS:             fsys,
+		}
+
+		events := collectEvents(sut)
+		assert.Empty(t, eventsOfType(events, EventError))
+		quarantined := eventsOfType(events, EventQuarantined)
+		require.Len(t, quarantined, 1)
+		assert.True(t, strings.HasPrefix(quarantined[0].Destination, "/quarantine/"))
+		assert.True(t, strings.HasSuffix(quarantined[0].Destination, "/sub/file.orig"))
+
+		content, err := fsys.ReadFile(quarantined[0].Destination)
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(content))
+	})
+
+	t.Run("red case - a path quarantined twice in a run keeps both entries", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base/sub", 0o755))
+		sut := &Wiper{BaseDir: "/base", Action: ActionQuarantine, QuarantineDir: "/quarantine", FS: fsys}
+
+		destinations := []string{}
+		for _, content := range []string{"first", "second", "third"} {
+			require.NoError(t, fsys.WriteFile("/base/sub/file.orig", []byte(content), 0o644))
+			destination, err := sut.quarantineEntry("/base/sub/file.orig", "wipe_out", false)
+			require.NoError(t, err)
+			destinations = append(destinations, destination)
+		}
+		require.NoError(t, sut.closeQuarantine())
+
+		runDir := strings.TrimSuffix(destinations[0], "/sub/file.orig")
+		assert.Equal(t, []string{runDir + "/sub/file.orig", runDir + "/sub/file-1.orig", runDir + "/sub/file-2.orig"}, destinations)
+		for i, content := range []string{"first", "second", "third"} {
+			read, err := fsys.ReadFile(destinations[i])
+			require.NoError(t, err)
+			assert.Equal(t, content, string(read))
+		}
+
+		manifest, err := fsys.ReadFile(runDir + ".manifest.jsonl")
+		require.NoError(t, err)
+		recorded := []string{}
+		for _, line := range strings.Split(strings.TrimSpace(string(manifest)), "\n") {
+			entry := QuarantineEntry{}
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			recorded = append(recorded, entry.QuarantinePath)
+		}
+		assert.Equal(t, destinations, recorded)
+	})
+
+	t.Run("earlier runs in a quarantine_dir below base dir are left alone", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/first.orig", []byte("first"), 0o644))
+		newWiper := func() *Wiper {
+			return &Wiper{
+				WipeOutPattern: []string{`.*\.orig$`},
+				BaseDir:        "/base",
+				Action:         ActionQuarantine,
+				QuarantineDir:  "/base/quarantine",
+				FS:             fsys,
+			}
+		}
+
+		first := eventsOfType(collectEvents(newWiper()), EventQuarantined)
+		require.Len(t, first, 1)
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, fsys.WriteFile("/base/second.orig", []byte("second"), 0o644))
+		second := eventsOfType(collectEvents(newWiper()), EventQuarantined)
+		require.Len(t, second, 1)
+		assert.Equal(t, "/base/second.orig", second[0].Path)
+		assert.True(t, existsOn(fsys, first[0].Destination))
+	})
+
+	t.Run("entries are copied to a quarantine_dir on another mount", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base/node_modules/pkg", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/node_modules/pkg/index.js", []byte("js"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/file.orig", []byte("orig"), 0o600))
+
+		sut := &Wiper{
+			WipeOutPattern: []string{`.*\.orig$`},
+			WipeOutDirs:    []string{"node_modules"},
+			BaseDir:        "/base",
+			Action:         ActionQuarantine,
+			QuarantineDir:  "/mnt/quarantine",
+			FS:             crossMountFS{fsys},
+		}
+
+		events := collectEvents(sut)
+		assert.Empty(t, eventsOfType(events, EventError))
+		quarantined := eventsOfType(events, EventQuarantined)
+		require.Len(t, quarantined, 2)
+		assert.False(t, existsOn(fsys, "/base/node_modules"))
+		assert.False(t, existsOn(fsys, "/base/file.orig"))
+		for _, event := range quarantined {
+			if event.IsDir {
+				content, err := fsys.ReadFile(filepath.Join(event.Destination, "pkg", "index.js"))
+				require.NoError(t, err)
+				assert.Equal(t, "js", string(content))
+				continue
+			}
+			info, err := fsys.Stat(event.Destination)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		}
+	})
+
+	t.Run("red case - quarantine_dir is required", func(t *testing.T) {
+		sut := Wiper{Action: ActionQuarantine}
+		assert.Error(t, sut.Validate())
+	})
+}
+
+// crossMountFS fails renames into /mnt like a rename across mounts.
+type crossMountFS struct {
+	*MemFileSystem
+}
+
+func (c crossMountFS) Rename(oldpath, newpath string) error {
+	if strings.HasPrefix(newpath, "/mnt/") {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	return c.MemFileSystem.Rename(oldpath, newpath)
+}
@@ -0,0 +1,114 @@
+package wiper
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Groupings of keep_newest rules
+const (
+	GroupByDir     = "dir"
+	GroupByCapture = "capture"
+)
+
+// captureGroup is the name of the capture group used by group_by: capture.
+// Patterns without it are grouped by their first capture group.
+const captureGroup = "group"
+
+type retainedEntry struct {
+	path    string
+	isDir   bool
+	modTime time.Time
+}
+
+// retained collects the matches of keep_newest rules within one directory,
+// grouped by rule and group key, until the directory has been read
+// completely.
+type retained map[*Rule]map[string][]retainedEntry
+
+func (r retained) add(rule *Rule, key string, e retainedEntry) {
+	if r[rule] == nil {
+		r[rule] = map[string][]retainedEntry{}
+	}
+	r[rule][key] = append(r[rule][key], e)
+}
+
+func (r *Rule) validateRetention() error {
+	if r.KeepNewest < 0 {
+		return fmt.Errorf("rule %s: keep_newest must not be negative, got %d", r.Name, r.KeepNewest)
+	}
+	switch r.GroupBy {
+	case "", GroupByDir:
+	case GroupByCapture:
+		for _, matcher := range r.patterns {
+			if matcher.NumSubexp() == 0 {
+				return fmt.Errorf("rule %s: group_by %s requires a capture group in %q", r.Name, GroupByCapture, matcher)
+			}
+		}
+		if len(r.Names) > 0 || len(r.patterns) == 0 {
+			return fmt.Errorf("rule %s: group_by %s requires patterns and no names", r.Name, GroupByCapture)
+		}
+	default:
+		return fmt.Errorf("rule %s: unknown group_by %q, expected %s or %s", r.Name, r.GroupBy, GroupByDir, GroupByCapture)
+	}
+	return nil
+}
+
+// groupKey returns the group name belongs to within its directory.
+func (r *Rule) groupKey(name string) string {
+	if r.GroupBy != GroupByCapture {
+		return ""
+	}
+	for _, matcher := range r.patterns {
+		match := matcher.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		if i := matcher.SubexpIndex(captureGroup); i > 0 {
+			return match[i]
+		}
+		return match[1]
+	}
+	return ""
+}
+
+// retain collects a match of a keep_newest rule instead of wiping it right
+// away.
+func (w *Wiper) retain(kept retained, rule *Rule, target string, isDir bool, events chan Event) {
+	info, err := w.fs().Lstat(target)
+	if err != nil {
+		events <- errorEvent("stat", target, isDir, err)
+		return
+	}
+	kept.add(rule, rule.groupKey(filepath.Base(target)), retainedEntry{path: target, isDir: isDir, modTime: info.ModTime()})
+}
+
+// wipeRetained keeps the KeepNewest newest entries of every group and wipes
+// the rest.
+func (w *Wiper) wipeRetained(ctx context.Context, kept retained, trash string, events chan Event) {
+	for rule, groups := range kept {
+		for key, entries := range groups {
+			slices.SortFunc(entries, func(a, b retainedEntry) int {
+				if order := b.modTime.Compare(a.modTime); order != 0 {
+					return order
+				}
+				return strings.Compare(b.path, a.path)
+			})
+			reason := fmt.Sprintf("keep_newest: one of the %d newest", rule.KeepNewest)
+			if key != "" {
+				reason = fmt.Sprintf("%s of group %q", reason, key)
+			}
+			for i, e := range entries {
+				if i < rule.KeepNewest {
+					events <- Event{Type: EventSkipped, Path: e.path, IsDir: e.isDir, Rule: rule.Name, Reason: reason}
+					continue
+				}
+				w.wipe(ctx, e.path, trash, rule, e.isDir, events)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package wiper
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepNewest(t *testing.T) {
+	files := map[string]string{}
+	modTimes := map[string]time.Time{}
+	now := time.Now()
+	for day := 1; day <= 4; day++ {
+		for _, file := range []string{
+			fmt.Sprintf("/base/db/backup-users-%d.tar.gz", day),
+			fmt.Sprintf("/base/db/backup-orders-%d.tar.gz", day),
+			fmt.Sprintf("/base/web/backup-site-%d.tar.gz", day),
+		} {
+			files[file] = ""
+			modTimes[file] = now.Add(-time.Duration(day) * 24 * time.Hour)
+		}
+	}
+
+	remaining := func(t *testing.T, fsys *MemFileSystem, dir string) []string {
+		t.Helper()
+		entries, err := fsys.ReadDir(dir)
+		require.NoError(t, err)
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	t.Run("green case - newest entries are kept per directory", func(t *testing.T) {
+		fsys := memTree(t, files)
+		for file, modTime := range modTimes {
+			require.NoError(t, fsys.Chtimes(file, modTime, modTime))
+		}
+		sut := &Wiper{
+			Rules:   []Rule{{Name: "backups", Patterns: []string{`^backup-.*\.tar\.gz$`}, KeepNewest: 3}},
+			BaseDir: "/base",
+			FS:      fsys,
+		}
+
+		events := collectEvents(sut)
+		assert.Len(t, eventsOfType(events, EventWiped), 6)
+		assert.Len(t, eventsOfType(events, EventSkipped), 6)
+		// backups of the same age are ordered by name, descending
+		assert.Equal(t, []string{"backup-orders-1.tar.gz", "backup-users-1.tar.gz", "backup-users-2.tar.gz"}, remaining(t, fsys, "/base/db"))
+		assert.Equal(t, []string{"backup-site-1.tar.gz", "backup-site-2.tar.gz", "backup-site-3.tar.gz"}, remaining(t, fsys, "/base/web"))
+	})
+
+	t.Run("green case - grouped by capture group", func(t *testing.T) {
+		fsys := memTree(t, files)
+		for file, modTime := range modTimes {
+			require.NoError(t, fsys.Chtimes(file, modTime, modTime))
+		}
+		sut := &Wiper{
+			Rules: []Rule{{
+				Name:       "backups",
+				Patterns:   []string{`^backup-(?P<group>\w+)-\d+\.tar\.gz$`},
+				KeepNewest: 2,
+				GroupBy:    GroupByCapture,
+			}},
+			BaseDir: "/base",
+			FS:      fsys,
+		}
+
+		events := collectEvents(sut)
+		assert.Len(t, eventsOfType(events, EventWiped), 6)
+		assert.Equal(t, []string{"backup-orders-1.tar.gz", "backup-orders-2.tar.gz", "backup-users-1.tar.gz", "backup-users-2.tar.gz"}, remaining(t, fsys, "/base/db"))
+		assert.Equal(t, []string{"backup-site-1.tar.gz", "backup-site-2.tar.gz"}, remaining(t, fsys, "/base/web"))
+
+		skipped := eventsOfType(events, EventSkipped)
+		require.NotEmpty(t, skipped)
+		assert.Contains(t, skipped[0].Reason, "keep_newest: one of the 2 newest of group")
+	})
+
+	t.Run("directories can be rotated too", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		for i, release := range []string{"v1", "v2", "v3"} {
+			require.NoError(t, fsys.MkdirAll("/base/releases/"+release, 0o755))
+			modTime := time.Now().Add(time.Duration(i) * time.Hour)
+			require.NoError(t, fsys.Chtimes("/base/releases/"+release, modTime, modTime))
+		}
+		sut := &Wiper{
+			Rules:   []Rule{{Patterns: []string{`^v\d+$`}, Dirs: true, KeepNewest: 1}},
+			BaseDir: "/base",
+			FS:      fsys,
+		}
+
+		collectEvents(sut)
+		assert.Equal(t, []string{"v3"}, remaining(t, fsys, "/base/releases"))
+	})
+
+	t.Run("first capture group is used without a group named group", func(t *testing.T) {
+		rule := Rule{Patterns: []string{`^(\w+)-(\d+)$`}, GroupBy: GroupByCapture}
+		require.NoError(t, rule.compile())
+		assert.Equal(t, "users", rule.groupKey("users-1"))
+		assert.Equal(t, "", rule.groupKey("other"))
+	})
+
+	t.Run("red case - invalid retention settings", func(t *testing.T) {
+		for _, rule := range []Rule{
+			{Patterns: []string{"x"}, KeepNewest: -1},
+			{Patterns: []string{"x"}, KeepNewest: 1, GroupBy: "year"},
+			{Patterns: []string{"x"}, KeepNewest: 1, GroupBy: GroupByCapture},
+			{Names: []string{"x"}, KeepNewest: 1, GroupBy: GroupByCapture},
+		} {
+			sut := Wiper{Rules: []Rule{rule}}
+			assert.Error(t, sut.Validate(), rule)
+		}
+	})
+}
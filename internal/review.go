@@ -0,0 +1,111 @@
+package wiper
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/steffakasid/eslog"
+)
+
+// Candidate is an entry a dry run would have wiped.
+type Candidate struct {
+	Path  string
+	IsDir bool
+	Rule  string
+	// Size includes the content of directories.
+	Size int64
+	rule *Rule
+}
+
+// addCandidate records target as matched by rule during a dry run.
+func (w *Wiper) addCandidate(target string, rule *Rule, isDir bool) Candidate {
+	size, err := sizeOf(w.fs(), target)
+	if err != nil {
+		eslog.Debugf("Can't determine the size of %s: %s", target, err)
+	}
+	candidate := Candidate{Path: target, IsDir: isDir, Rule: rule.Name, Size: size, rule: rule}
+	w.mu.Lock()
+	w.candidates = append(w.candidates, candidate)
+	w.mu.Unlock()
+	return candidate
+}
+
+// Candidates returns the entries matched by WipeFiles while DryRun was set,
+// sorted by path.
+func (w *Wiper) Candidates() []Candidate {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	candidates := slices.Clone(w.candidates)
+	slices.SortFunc(candidates, func(a, b Candidate) int { return strings.Compare(a.Path, b.Path) })
+	return candidates
+}
+
+// WipeCandidates applies the action of their rule to candidates collected by
+// a dry run. DryRun has to be unset again. As the tree may have changed since
+// the dry run, every candidate is checked again right before it is wiped and
+// skipped unless the same rule still matches it. Like WipeFiles it reports
+// each step on events and closes it when done.
+func (w *Wiper) WipeCandidates(candidates []Candidate, events chan Event) {
+	defer w.finishRun(events)
+	ctx, span := tracer.Start(context.Background(), "WipeCandidates")
+	defer span.End()
+
+	trash := initTrash(w)
+	for _, candidate := range candidates {
+		if candidate.rule == nil {
+			continue
+		}
+		rule, reason := w.recheck(candidate, events)
+		if rule == nil {
+			events <- Event{Type: EventSkipped, Path: candidate.Path, IsDir: candidate.IsDir, Rule: candidate.Rule, Reason: reason}
+			continue
+		}
+		w.wipe(ctx, candidate.Path, trash, rule, candidate.IsDir, events)
+	}
+}
+
+// recheck returns the rule which matches candidate now, or why candidate
+// must not be wiped anymore.
+func (w *Wiper) recheck(candidate Candidate, events chan Event) (*Rule, string) {
+	target := candidate.Path
+	if !isWithin(filepath.Clean(w.BaseDir), target) {
+		return nil, "outside base_dir"
+	}
+	info, err := w.fs().Lstat(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, "no longer exists"
+	}
+	if err != nil {
+		events <- errorEvent("stat", target, candidate.IsDir, err)
+		return nil, "can't be checked"
+	}
+	if info.IsDir() != candidate.IsDir {
+		return nil, "changed since the review"
+	}
+	if w.ownPath(target) {
+		return nil, "wiper directory"
+	}
+
+	sc, reason := w.scopeFor(filepath.Dir(target), events)
+	if reason != "" {
+		return nil, reason
+	}
+	if sc.ignored(target, candidate.IsDir) {
+		return nil, ignoreFileName
+	}
+	rule, err := w.matchingRule(w.newEntry(target, candidate.IsDir, sc))
+	if err != nil {
+		events <- errorEvent("match", target, candidate.IsDir, err)
+	}
+	if rule == nil || rule.Name != candidate.Rule {
+		return nil, "no longer matched"
+	}
+	if reason := w.protected(sc, target, candidate.IsDir); reason != "" {
+		return nil, reason
+	}
+	return rule, ""
+}
@@ -0,0 +1,111 @@
+package wiper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReview(t *testing.T) {
+	files := map[string]string{
+		"/base/app/node_modules/pkg/index.js": strings.Repeat("\x00", 100),
+		"/base/a.orig":                        strings.Repeat("\x00", 10),
+		"/base/app/b.orig":                    strings.Repeat("\x00", 20),
+	}
+
+	t.Run("green case - dry run collects candidates and wipes nothing", func(t *testing.T) {
+		fsys := memTree(t, files)
+		sut := &Wiper{
+			WipeOutPattern: []string{`\.orig$`},
+			WipeOutDirs:    []string{"node_modules"},
+			Hooks:          Hooks{OnMatch: []string{"false"}},
+			BaseDir:        "/base",
+			FS:             fsys,
+			DryRun:         true,
+		}
+		events := collectEvents(sut)
+		assert.Empty(t, eventsOfType(events, EventWiped))
+		assert.Empty(t, eventsOfType(events, EventHook))
+		assert.Len(t, eventsOfType(events, EventMatched), 3)
+		assert.Equal(t, 0, sut.WipedFiles)
+
+		candidates := sut.Candidates()
+		require.Len(t, candidates, 3)
+		assert.Equal(t, "/base/a.orig", candidates[0].Path)
+		assert.Equal(t, int64(10), candidates[0].Size)
+		assert.Equal(t, "wipe_out_pattern", candidates[0].Rule)
+		assert.Equal(t, "/base/app/b.orig", candidates[1].Path)
+		assert.Equal(t, "/base/app/node_modules", candidates[2].Path)
+		assert.True(t, candidates[2].IsDir)
+		assert.Equal(t, int64(100), candidates[2].Size)
+		for _, path := range []string{"/base/a.orig", "/base/app/b.orig", "/base/app/node_modules"} {
+			assert.True(t, existsOn(fsys, path), path)
+		}
+	})
+
+	t.Run("green case - selected candidates are wiped", func(t *testing.T) {
+		fsys := memTree(t, files)
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, WipeOutDirs: []string{"node_modules"}, BaseDir: "/base", FS: fsys, DryRun: true}
+		collectEvents(sut)
+		candidates := sut.Candidates()
+
+		sut.DryRun = false
+		events := make(chan Event)
+		go sut.WipeCandidates([]Candidate{candidates[0], candidates[2]}, events)
+		wiped := []string{}
+		for event := range events {
+			if event.Type == EventWiped {
+				wiped = append(wiped, event.Path)
+			}
+		}
+		assert.Equal(t, []string{"/base/a.orig", "/base/app/node_modules"}, wiped)
+		assert.True(t, existsOn(fsys, "/base/app/b.orig"))
+		assert.Equal(t, int64(110), sut.WipedBytes)
+	})
+
+	t.Run("red case - candidates without rule are ignored", func(t *testing.T) {
+		fsys := memTree(t, files)
+		sut := &Wiper{BaseDir: "/base", FS: fsys}
+		events := make(chan Event)
+		go sut.WipeCandidates([]Candidate{{Path: "/base/a.orig", Rule: "forged"}}, events)
+		for range events {
+		}
+		assert.True(t, existsOn(fsys, "/base/a.orig"))
+	})
+
+	t.Run("red case - candidates which changed since the dry run are skipped", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/base/c.orig", nil, 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, WipeOutDirs: []string{"node_modules"}, BaseDir: "/base", FS: fsys, DryRun: true}
+		collectEvents(sut)
+		candidates := sut.Candidates()
+		require.Len(t, candidates, 4)
+
+		require.NoError(t, fsys.Remove("/base/a.orig"))
+		require.NoError(t, fsys.WriteFile("/base/app/.wiperignore", []byte("b.orig\n"), 0o644))
+		require.NoError(t, fsys.RemoveAll("/base/app/node_modules"))
+		require.NoError(t, fsys.WriteFile("/base/app/node_modules", nil, 0o644))
+		sut.DryRun = false
+		sut.ExcludeFile = []string{"c.orig"}
+
+		events := make(chan Event)
+		go sut.WipeCandidates(candidates, events)
+		reasons := map[string]string{}
+		for event := range events {
+			assert.NotEqual(t, EventWiped, event.Type, event.Path)
+			if event.Type == EventSkipped {
+				reasons[event.Path] = event.Reason
+			}
+		}
+		assert.Equal(t, map[string]string{
+			"/base/a.orig":           "no longer exists",
+			"/base/app/b.orig":       ".wiperignore",
+			"/base/app/node_modules": "changed since the review",
+			"/base/c.orig":           "no longer matched",
+		}, reasons)
+		assert.True(t, existsOn(fsys, "/base/c.orig"))
+		assert.Zero(t, sut.WipedFiles)
+	})
+}
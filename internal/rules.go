@@ -0,0 +1,204 @@
+package wiper
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/google/cel-go/cel"
+	"github.com/steffakasid/eslog"
+)
+
+// Rule selects entries by name and content and decides what happens to them.
+// The wipe_out* lists are turned into one rule each, named after their config
+// key, which use the global action.
+type Rule struct {
+	Name            string   `json:"name,omitempty" mapstructure:"name" yaml:"name"`
+	Names           []string `json:"names,omitempty" mapstructure:"names" yaml:"names"`
+	Patterns        []string `json:"patterns,omitempty" mapstructure:"patterns" yaml:"patterns"`
+	Dirs            bool     `json:"dirs,omitempty" mapstructure:"dirs" yaml:"dirs"`
+	MimeTypes       []string `json:"mime_types,omitempty" mapstructure:"mime_types" yaml:"mime_types"`
+	ContentPattern  string   `json:"content_pattern,omitempty" mapstructure:"content_pattern" yaml:"content_pattern"`
+	ContentMaxBytes string   `json:"content_max_bytes,omitempty" mapstructure:"content_max_bytes" yaml:"content_max_bytes"`
+	Conditions      `mapstructure:",squash" yaml:",inline"`
+	Ownership       `mapstructure:",squash" yaml:",inline"`
+	When            string `json:"when,omitempty" mapstructure:"when" yaml:"when"`
+	KeepNewest      int    `json:"keep_newest,omitempty" mapstructure:"keep_newest" yaml:"keep_newest"`
+	GroupBy         string `json:"group_by,omitempty" mapstructure:"group_by" yaml:"group_by"`
+	Action          string `json:"action,omitempty" mapstructure:"action" yaml:"action"`
+	Shred           Shred  `json:"shred,omitempty" mapstructure:"shred" yaml:"shred"`
+	Delay           string `json:"delay,omitempty" mapstructure:"delay" yaml:"delay"`
+	patterns        []*regexp.Regexp
+	contentPattern  *regexp.Regexp
+	contentLimit    int64
+	when            cel.Program
+}
+
+func (r *Rule) compile() error {
+	r.patterns = make([]*regexp.Regexp, 0, len(r.Patterns))
+	for _, pattern := range r.Patterns {
+		matcher, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		r.patterns = append(r.patterns, matcher)
+	}
+	if err := r.validateRetention(); err != nil {
+		return err
+	}
+	if err := r.Conditions.validate(r.Dirs); err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	if err := r.Ownership.compile(); err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	if r.When != "" {
+		var err error
+		if r.when, err = compileWhen(r.When); err != nil {
+			return fmt.Errorf("rule %s: when: %w", r.Name, err)
+		}
+	}
+
+	if !r.hasContentConditions() {
+		return nil
+	}
+	if r.Dirs {
+		return fmt.Errorf("rule %s: mime_types and content_pattern only apply to files", r.Name)
+	}
+	var err error
+	if r.ContentPattern != "" {
+		if r.contentPattern, err = regexp.Compile(r.ContentPattern); err != nil {
+			return fmt.Errorf("rule %s: content_pattern: %w", r.Name, err)
+		}
+	}
+	if r.contentLimit, err = r.contentMaxBytes(); err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to e. Without names or patterns a
+// rule matches by its when expression or content alone; a rule without any
+// condition matches nothing.
+func (r *Rule) matches(e *entry) (bool, error) {
+	if r.Dirs != e.isDir {
+		return false, nil
+	}
+	if len(r.Names) > 0 || len(r.patterns) > 0 {
+		if !r.matchesName(e.name) {
+			return false, nil
+		}
+	} else if !r.hasContentConditions() && r.when == nil {
+		return false, nil
+	}
+	if matched, err := r.Conditions.matches(e); !matched || err != nil {
+		return false, err
+	}
+	if matched, err := r.Ownership.matches(e); !matched || err != nil {
+		return false, err
+	}
+	if matched, err := r.matchesWhen(e); !matched || err != nil {
+		return false, err
+	}
+	return r.matchesContent(e)
+}
+
+func (r *Rule) matchesName(name string) bool {
+	if slices.Contains(r.Names, name) {
+		return true
+	}
+	for _, matcher := range r.patterns {
+		if matcher.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// action returns the action of the rule, falling back to the global one.
+func (r *Rule) action(w *Wiper) string {
+	if r.Action != "" {
+		return r.Action
+	}
+	return w.action()
+}
+
+// configuredRules returns the rules list, the rules of the enabled presets
+// and the rules built from the wipe_out* lists, in the order they are checked.
+func (w *Wiper) configuredRules() []*Rule {
+	rules := make([]*Rule, 0, len(w.Rules)+4)
+	for i := range w.Rules {
+		rule := w.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rules[%d]", i)
+		}
+		rules = append(rules, &rule)
+	}
+	rules = append(rules, w.presetRules()...)
+	return append(rules,
+		&Rule{Name: "wipe_out", Names: w.WipeOut},
+		&Rule{Name: "wipe_out_pattern", Patterns: w.WipeOutPattern},
+		&Rule{Name: "wipe_out_dirs", Names: w.WipeOutDirs, Dirs: true},
+		&Rule{Name: "wipe_out_pattern_dirs", Patterns: w.WipeOutPatternDirs, Dirs: true},
+	)
+}
+
+// compileRules compiles the patterns of all rules.
+func (w *Wiper) compileRules() ([]*Rule, error) {
+	rules := w.configuredRules()
+	for _, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// rules returns the compiled rules. They are compiled once per Wiper.
+func (w *Wiper) rules() []*Rule {
+	w.rulesOnce.Do(func() {
+		var err error
+		w.compiledRules, err = w.compileRules()
+		eslog.LogIfError(err, eslog.Fatal)
+	})
+	return w.compiledRules
+}
+
+// usesAction reports whether the global action or any rule uses action.
+func (w *Wiper) usesAction(action string) bool {
+	if w.action() == action {
+		return true
+	}
+	for _, rule := range w.Rules {
+		if rule.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingRule returns the first rule that matches e, or nil if the entry
+// should be kept. The rules of the .wiper.yaml files around e are checked
+// first. Rules whose content can't be read don't match; the first read error
+// is returned if no other rule matched.
+func (w *Wiper) matchingRule(e *entry) (*Rule, error) {
+	exclude := w.ExcludeFile
+	if e.isDir {
+		exclude = w.ExcludeDir
+	}
+	if slices.Contains(exclude, e.name) {
+		return nil, nil
+	}
+
+	var readErr error
+	for _, rule := range append(e.scope.scopedRules(), w.rules()...) {
+		matched, err := rule.matches(e)
+		if err != nil && readErr == nil {
+			readErr = err
+		}
+		if matched {
+			return rule, nil
+		}
+	}
+	return nil, readErr
+}
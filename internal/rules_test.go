@@ -0,0 +1,76 @@
+package wiper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ruleFor(t *testing.T, sut *Wiper, name string, isDir bool) *Rule {
+	t.Helper()
+
+	rule, err := sut.matchingRule(newEntry(sut.fs(), name, isDir))
+	require.NoError(t, err)
+	return rule
+}
+
+func TestRules(t *testing.T) {
+	t.Run("green case - rules are checked before the wipe_out lists", func(t *testing.T) {
+		sut := &Wiper{
+			Rules: []Rule{
+				{Name: "dumps", Patterns: []string{`\.dec\.json$`}, Action: ActionShred},
+				{Names: []string{"build"}, Dirs: true},
+			},
+			WipeOutPattern: []string{`\.json$`},
+		}
+
+		rule := ruleFor(t, sut, "secrets.dec.json", false)
+		require.NotNil(t, rule)
+		assert.Equal(t, "dumps", rule.Name)
+		assert.Equal(t, ActionShred, rule.action(sut))
+
+		rule = ruleFor(t, sut, "package.json", false)
+		require.NotNil(t, rule)
+		assert.Equal(t, "wipe_out_pattern", rule.Name)
+		assert.Equal(t, ActionRemove, rule.action(sut))
+
+		rule = ruleFor(t, sut, "build", true)
+		require.NotNil(t, rule)
+		assert.Equal(t, "rules[1]", rule.Name)
+		assert.Nil(t, ruleFor(t, sut, "build", false))
+	})
+
+	t.Run("per rule action", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/creds.dec.yaml", []byte("token: secret"), 0o600))
+		require.NoError(t, fsys.WriteFile("/base/file.orig", nil, 0o644))
+		sut := &Wiper{
+			Rules:          []Rule{{Name: "dumps", Patterns: []string{`\.dec\.yaml$`}, Action: ActionShred}},
+			WipeOutPattern: []string{`\.orig$`},
+			BaseDir:        "/base",
+			FS:             fsys,
+		}
+
+		events := collectEvents(sut)
+		shredded := eventsOfType(events, EventShredded)
+		require.Len(t, shredded, 1)
+		assert.Equal(t, "/base/creds.dec.yaml", shredded[0].Path)
+		assert.Equal(t, "dumps", shredded[0].Rule)
+		wiped := eventsOfType(events, EventWiped)
+		require.Len(t, wiped, 1)
+		assert.Equal(t, "/base/file.orig", wiped[0].Path)
+	})
+
+	t.Run("red case - invalid rules", func(t *testing.T) {
+		sut := Wiper{Rules: []Rule{{Name: "broken", Patterns: []string{"("}}}}
+		assert.ErrorContains(t, sut.Validate(), "rule broken")
+
+		sut = Wiper{Rules: []Rule{{Names: []string{"x"}, Action: "burn"}}}
+		assert.ErrorContains(t, sut.Validate(), "unknown action")
+
+		sut = Wiper{Rules: []Rule{{Names: []string{"x"}, Action: ActionQuarantine}}}
+		assert.ErrorContains(t, sut.Validate(), "quarantine_dir")
+	})
+}
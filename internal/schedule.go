@@ -0,0 +1,223 @@
+package wiper
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// cronMarker tags the crontab lines written by wiper schedule, followed by
+// the name of the schedule.
+const cronMarker = "# wiper-schedule:"
+
+// profileName matches the names of profiles, which end up in file names, unit
+// names and crontab lines.
+var profileName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Schedule is a periodic wiper run, installed as a systemd user service and
+// timer or as a crontab line.
+type Schedule struct {
+	// Name is the profile the schedule runs, it is part of the unit names.
+	Name string
+	// Every is the interval between two runs.
+	Every time.Duration
+	// Executable is the absolute path of the wiper binary.
+	Executable string
+	// ConfigFile is passed to wiper with --config.
+	ConfigFile string
+}
+
+// ProfileConfig returns the config file of a profile. Profiles are config
+// files next to the default one, e.g. ~/.config/wiper/projects.yaml for the
+// profile projects. The profile "default" is the default config file.
+func ProfileConfig(profile string) (string, error) {
+	if err := ValidateProfile(profile); err != nil {
+		return "", err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	name := profile
+	if name == "default" {
+		name = configFileName
+	}
+	withoutExt := filepath.Join(home, ".config", "wiper", name)
+	configFile := getConfigFilename(withoutExt)
+	if configFile == "" {
+		return "", fmt.Errorf("profile %s not found, expected %s.yaml", profile, withoutExt)
+	}
+	return configFile, nil
+}
+
+// ValidateProfile checks that profile can be used in file and unit names and
+// in crontab lines. Only letters, digits, dots, dashes and underscores are
+// allowed.
+func ValidateProfile(profile string) error {
+	if profile == "." || profile == ".." || !profileName.MatchString(profile) {
+		return fmt.Errorf("invalid profile %q", profile)
+	}
+	return nil
+}
+
+// UnitName returns the name of the systemd units and crontab line.
+func (s Schedule) UnitName() string {
+	return "wiper-" + s.Name
+}
+
+// LockFile is held by the crontab line while wiper runs, so runs of the same
+// schedule don't overlap. systemd never starts a service which is still
+// running.
+func (s Schedule) LockFile() string {
+	return filepath.Join(stateDir(), s.UnitName()+".lock")
+}
+
+// ServiceUnit returns the systemd service which runs wiper once.
+func (s Schedule) ServiceUnit() string {
+	return fmt.Sprintf(`[Unit]
+Description=wiper (profile %s)
+
+[Service]
+Type=oneshot
+ExecStart=%s --config %s
+Nice=10
+IOSchedulingClass=idle
+`, s.Name, systemdQuote(s.Executable), systemdQuote(s.ConfigFile))
+}
+
+// TimerUnit returns the systemd timer which starts the service periodically.
+// Hourly, daily and weekly schedules are calendar based and catch up on runs
+// missed while the machine was off.
+func (s Schedule) TimerUnit() string {
+	var trigger string
+	switch s.Every {
+	case time.Hour:
+		trigger = "OnCalendar=hourly\nPersistent=true"
+	case 24 * time.Hour:
+		trigger = "OnCalendar=daily\nPersistent=true"
+	case 7 * 24 * time.Hour:
+		trigger = "OnCalendar=weekly\nPersistent=true"
+	default:
+		trigger = fmt.Sprintf("OnBootSec=5min\nOnUnitActiveSec=%s", systemdDuration(s.Every))
+	}
+	return fmt.Sprintf(`[Unit]
+Description=Run wiper every %s (profile %s)
+
+[Timer]
+%s
+
+[Install]
+WantedBy=timers.target
+`, s.Every, s.Name, trigger)
+}
+
+// CronSpec returns the crontab time fields for the interval, if cron can
+// express it.
+func (s Schedule) CronSpec() (string, error) {
+	minutes := int(s.Every / time.Minute)
+	switch {
+	case s.Every <= 0 || s.Every%time.Minute != 0:
+	case s.Every == 7*24*time.Hour:
+		return "@weekly", nil
+	case s.Every == 24*time.Hour:
+		return "@daily", nil
+	case s.Every == time.Hour:
+		return "@hourly", nil
+	case minutes < 60 && 60%minutes == 0:
+		return fmt.Sprintf("*/%d * * * *", minutes), nil
+	case minutes%60 == 0 && minutes < 24*60 && (24*60)%minutes == 0:
+		return fmt.Sprintf("0 */%d * * *", minutes/60), nil
+	}
+	return "", fmt.Errorf("cron can't run every %s, use a divisor of an hour or a day, 1d or 1w", s.Every)
+}
+
+// CronLine returns the crontab line of the schedule. If flock is given, it
+// guards the run with LockFile.
+func (s Schedule) CronLine(flock string) (string, error) {
+	spec, err := s.CronSpec()
+	if err != nil {
+		return "", err
+	}
+	command := fmt.Sprintf("%s --config %s", shellQuote(s.Executable), shellQuote(s.ConfigFile))
+	if flock != "" {
+		command = fmt.Sprintf("%s -n %s %s", shellQuote(flock), shellQuote(s.LockFile()), command)
+	}
+	return fmt.Sprintf("%s %s %s%s", spec, command, cronMarker, s.Name), nil
+}
+
+// SetCronLine replaces the line of the schedule in crontab, or appends it.
+func (s Schedule) SetCronLine(crontab, line string) string {
+	updated, _ := RemoveCronLine(crontab, s.Name)
+	if updated != "" && !strings.HasSuffix(updated, "\n") {
+		updated += "\n"
+	}
+	return updated + line + "\n"
+}
+
+// RemoveCronLine removes the line of the schedule name from crontab and
+// reports whether there was one.
+func RemoveCronLine(crontab, name string) (string, bool) {
+	var result strings.Builder
+	found := false
+	for _, line := range strings.SplitAfter(crontab, "\n") {
+		if strings.HasSuffix(strings.TrimRight(line, "\n"), cronMarker+name) {
+			found = true
+			continue
+		}
+		result.WriteString(line)
+	}
+	return result.String(), found
+}
+
+// CronSchedules returns the names and lines of the schedules in crontab.
+func CronSchedules(crontab string) map[string]string {
+	schedules := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(crontab))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.LastIndex(line, cronMarker); i >= 0 {
+			schedules[line[i+len(cronMarker):]] = strings.TrimSpace(line[:i])
+		}
+	}
+	return schedules
+}
+
+// systemdDuration formats d as a systemd time span, e.g. "1h30min".
+func systemdDuration(d time.Duration) string {
+	var parts []string
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"d", 24 * time.Hour}, {"h", time.Hour}, {"min", time.Minute}, {"s", time.Second}} {
+		if d >= unit.size {
+			parts = append(parts, fmt.Sprintf("%d%s", d/unit.size, unit.suffix))
+			d %= unit.size
+		}
+	}
+	if len(parts) == 0 {
+		return "1s"
+	}
+	return strings.Join(parts, "")
+}
+
+// systemdQuote quotes an argument of ExecStart if it needs to.
+func systemdQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\$%;") {
+		return arg
+	}
+	arg = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$", "%", "%%").Replace(arg)
+	return `"` + arg + `"`
+}
+
+// shellQuote quotes an argument for sh, as used by cron.
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\$`;&|<>()*?[]#~%!{}") {
+		return arg
+	}
+	// % starts the standard input of the command in crontab lines
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(arg, "'", `'\''`), "%", `\%`) + "'"
+}
@@ -0,0 +1,98 @@
+package wiper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	sut := Schedule{Name: "projects", Every: 24 * time.Hour, Executable: "/usr/local/bin/wiper", ConfigFile: "/home/user/.config/wiper/projects.yaml"}
+
+	t.Run("green case - systemd units", func(t *testing.T) {
+		assert.Equal(t, "wiper-projects", sut.UnitName())
+		assert.Contains(t, sut.ServiceUnit(), "ExecStart=/usr/local/bin/wiper --config /home/user/.config/wiper/projects.yaml\n")
+		assert.Contains(t, sut.ServiceUnit(), "Type=oneshot\n")
+		assert.Contains(t, sut.TimerUnit(), "OnCalendar=daily\nPersistent=true\n")
+		assert.Contains(t, sut.TimerUnit(), "WantedBy=timers.target\n")
+
+		custom := sut
+		custom.Every = 90 * time.Minute
+		custom.ConfigFile = "/home/user/my configs/100%.yaml"
+		assert.Contains(t, custom.TimerUnit(), "OnUnitActiveSec=1h30min\n")
+		assert.Contains(t, custom.ServiceUnit(), `--config "/home/user/my configs/100%%.yaml"`)
+	})
+
+	t.Run("green case - crontab lines", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", "/home/user/.local/state")
+		line, err := sut.CronLine("/usr/bin/flock")
+		require.NoError(t, err)
+		assert.Equal(t, "@daily /usr/bin/flock -n /home/user/.local/state/wiper/wiper-projects.lock /usr/local/bin/wiper --config /home/user/.config/wiper/projects.yaml # wiper-schedule:projects", line)
+
+		custom := sut
+		custom.ConfigFile = "/home/user/it's 100%.yaml"
+		line, err = custom.CronLine("")
+		require.NoError(t, err)
+		assert.Equal(t, `@daily /usr/local/bin/wiper --config '/home/user/it'\''s 100\%.yaml' # wiper-schedule:projects`, line)
+	})
+
+	t.Run("cron specs", func(t *testing.T) {
+		for every, spec := range map[time.Duration]string{
+			15 * time.Minute:   "*/15 * * * *",
+			time.Hour:          "@hourly",
+			6 * time.Hour:      "0 */6 * * *",
+			24 * time.Hour:     "@daily",
+			7 * 24 * time.Hour: "@weekly",
+		} {
+			actual, err := Schedule{Every: every}.CronSpec()
+			require.NoError(t, err, every)
+			assert.Equal(t, spec, actual, every)
+		}
+		for _, every := range []time.Duration{7 * time.Minute, 5 * time.Hour, 48 * time.Hour, 30 * time.Second} {
+			_, err := Schedule{Every: every}.CronSpec()
+			assert.Error(t, err, every)
+		}
+	})
+
+	t.Run("crontab lines are replaced and removed by name", func(t *testing.T) {
+		crontab := "MAILTO=me\n0 * * * * backup\n"
+		crontab = sut.SetCronLine(crontab, "@daily old # wiper-schedule:projects")
+		crontab = sut.SetCronLine(crontab, "@daily new # wiper-schedule:projects")
+		crontab = Schedule{Name: "downloads"}.SetCronLine(crontab, "@weekly other # wiper-schedule:downloads")
+		assert.Equal(t, "MAILTO=me\n0 * * * * backup\n@daily new # wiper-schedule:projects\n@weekly other # wiper-schedule:downloads\n", crontab)
+		assert.Equal(t, map[string]string{"projects": "@daily new", "downloads": "@weekly other"}, CronSchedules(crontab))
+
+		crontab, found := RemoveCronLine(crontab, "projects")
+		assert.True(t, found)
+		assert.Equal(t, "MAILTO=me\n0 * * * * backup\n@weekly other # wiper-schedule:downloads\n", crontab)
+		_, found = RemoveCronLine(crontab, "projects")
+		assert.False(t, found)
+	})
+
+	t.Run("ProfileConfig", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		require.NoError(t, os.MkdirAll(filepath.Join(home, ".config", "wiper"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".config", "wiper", "config.yaml"), nil, 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".config", "wiper", "projects.yml"), nil, 0o644))
+
+		configFile, err := ProfileConfig("default")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(home, ".config", "wiper", "config.yaml"), configFile)
+		configFile, err = ProfileConfig("projects")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(home, ".config", "wiper", "projects.yml"), configFile)
+
+		_, err = ProfileConfig("missing")
+		assert.Error(t, err)
+		for _, profile := range []string{"../etc", "..", ".", "", `a\b`, "my projects", "50%", "me@work", "x\n* * * * * rm -rf ~"} {
+			_, err = ProfileConfig(profile)
+			assert.ErrorContains(t, err, "invalid profile")
+		}
+		assert.NoError(t, ValidateProfile("work_2.old-1"))
+	})
+}
@@ -0,0 +1,201 @@
+package wiper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/viper"
+)
+
+// Names of the per-directory config files
+const (
+	ignoreFileName      = ".wiperignore"
+	localConfigFileName = ".wiper.yaml"
+)
+
+// isScopeFile reports whether name is one of the per-directory config files,
+// which are never wiped.
+func isScopeFile(name string) bool {
+	return name == ignoreFileName || name == localConfigFileName
+}
+
+// localConfig is the content of a .wiper.yaml.
+type localConfig struct {
+	Rules   []Rule   `mapstructure:"rules"`
+	Presets []string `mapstructure:"preset"`
+}
+
+// scope holds the .wiperignore and .wiper.yaml of a directory and, with
+// respect_git, its git repository and .gitignore. Scopes are chained to the
+// scope of the closest parent directory which has one, so the settings apply
+// to the whole subtree.
+type scope struct {
+	parent    *scope
+	dir       string
+	ignore    *ignoreList
+	rules     []*Rule
+	repo      *gitRepo
+	gitignore *ignoreList
+}
+
+// enterScope returns the scope for dir. A new scope is only created if dir
+// contains one of the per-directory config files. Broken files are reported
+// and ignored.
+func (w *Wiper) enterScope(parent *scope, dir string, names []string, events chan Event) *scope {
+	hasIgnore := slices.Contains(names, ignoreFileName)
+	hasConfig := slices.Contains(names, localConfigFileName)
+	hasRepo := w.RespectGit && slices.Contains(names, gitDirName)
+	hasGitignore := w.RespectGit && slices.Contains(names, gitIgnoreFileName) && (hasRepo || parent.gitRepo() != nil)
+	if !hasIgnore && !hasConfig && !hasRepo && !hasGitignore {
+		return parent
+	}
+
+	s := &scope{parent: parent, dir: dir}
+	if hasIgnore {
+		s.ignore = w.loadIgnore(filepath.Join(dir, ignoreFileName), events)
+	}
+	if hasRepo {
+		repo, err := openGitRepo(w.fs(), dir)
+		if err != nil {
+			events <- errorEvent("git", dir, true, err)
+			repo = &gitRepo{root: dir, unreadable: true}
+		}
+		s.repo = repo
+	}
+	if hasGitignore {
+		s.gitignore = w.loadIgnore(filepath.Join(dir, gitIgnoreFileName), events)
+	}
+	if hasConfig {
+		configPath := filepath.Join(dir, localConfigFileName)
+		rules, err := w.loadLocalRules(configPath)
+		if err != nil {
+			events <- errorEvent("config", configPath, false, err)
+		}
+		s.rules = rules
+	}
+	return s
+}
+
+// rootScope returns the scope BaseDir starts in. With respect_git this is
+// the repository BaseDir is part of, if any, together with the .gitignore
+// files above BaseDir.
+func (w *Wiper) rootScope(dir string, events chan Event) *scope {
+	if !w.RespectGit {
+		return nil
+	}
+	dirs := ancestors(filepath.Clean(dir))
+	dirs = dirs[:len(dirs)-1]
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if !existsOn(w.fs(), filepath.Join(dirs[i], gitDirName)) {
+			continue
+		}
+		var s *scope
+		for _, parent := range dirs[i:] {
+			names := []string{}
+			if parent == dirs[i] {
+				names = append(names, gitDirName)
+			}
+			if existsOn(w.fs(), filepath.Join(parent, gitIgnoreFileName)) {
+				names = append(names, gitIgnoreFileName)
+			}
+			s = w.enterScope(s, parent, names, events)
+		}
+		return s
+	}
+	return nil
+}
+
+// loadIgnore reads a file in gitignore syntax.
+func (w *Wiper) loadIgnore(ignorePath string, events chan Event) *ignoreList {
+	content, err := readFile(w.fs(), ignorePath)
+	if err != nil {
+		events <- errorEvent("config", ignorePath, false, err)
+		return nil
+	}
+	list, err := parseIgnore(bytes.NewReader(content))
+	if err != nil {
+		events <- errorEvent("config", ignorePath, false, err)
+	}
+	return list
+}
+
+// loadLocalRules reads the rules and presets of a .wiper.yaml.
+func (w *Wiper) loadLocalRules(configPath string) ([]*Rule, error) {
+	content, err := readFile(w.fs(), configPath)
+	if err != nil {
+		return nil, err
+	}
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+	local := localConfig{}
+	if err := v.Unmarshal(&local); err != nil {
+		return nil, err
+	}
+	if err := validatePresets(local.Presets); err != nil {
+		return nil, err
+	}
+
+	rules := []*Rule{}
+	for i := range local.Rules {
+		rule := local.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("%s rules[%d]", configPath, i)
+		}
+		rules = append(rules, &rule)
+	}
+	rules = append(rules, (&Wiper{Presets: local.Presets}).presetRules()...)
+	for _, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+		if err := w.validateRule(rule); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// ignored reports whether a .wiperignore of s or its parents excludes
+// target. Like in git, deeper files override their parents.
+func (s *scope) ignored(target string, isDir bool) bool {
+	if s == nil {
+		return false
+	}
+	ignored := s.parent.ignored(target, isDir)
+	if s.ignore == nil {
+		return ignored
+	}
+	rel, err := filepath.Rel(s.dir, target)
+	if err != nil {
+		return ignored
+	}
+	if result, found := s.ignore.match(filepath.ToSlash(rel), isDir); found {
+		return result
+	}
+	return ignored
+}
+
+// scopedRules returns the local rules of s and its parents, deepest first.
+func (s *scope) scopedRules() []*Rule {
+	rules := []*Rule{}
+	for ; s != nil; s = s.parent {
+		rules = append(rules, s.rules...)
+	}
+	return rules
+}
+
+func readFile(fsys FileSystem, name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(file)
+	return content, errors.Join(err, file.Close())
+}
@@ -0,0 +1,92 @@
+package wiper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopes(t *testing.T) {
+	files := map[string]string{
+		"/base/a.orig":             "",
+		"/base/repo/b.orig":        "",
+		"/base/repo/logs/c.orig":   "",
+		"/base/repo/sub/d.orig":    "",
+		"/base/repo/sub/keep.orig": "",
+		"/base/repo/sub/e.tmp":     "",
+		"/base/repo/sub/build/":    "",
+		"/base/other/f.tmp":        "",
+		"/base/other/build/":       "",
+	}
+
+	wipedPaths := func(events []Event) []string {
+		paths := []string{}
+		for _, event := range eventsOfType(events, EventWiped) {
+			paths = append(paths, event.Path)
+		}
+		return paths
+	}
+
+	t.Run("green case - .wiperignore excludes paths of its subtree", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/base/repo/.wiperignore", []byte("/b.orig\nlogs/\n"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/repo/sub/.wiperignore", []byte("*.orig\n!d.orig\n"), 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, BaseDir: "/base", FS: fsys}
+
+		events := collectEvents(sut)
+		assert.ElementsMatch(t, []string{"/base/a.orig", "/base/repo/sub/d.orig"}, wipedPaths(events))
+		assert.Contains(t, eventsOfType(events, EventSkipped), Event{Type: EventSkipped, Path: "/base/repo/logs", IsDir: true, Reason: ignoreFileName})
+		assert.True(t, existsOn(fsys, "/base/repo/.wiperignore"))
+	})
+
+	t.Run("green case - .wiper.yaml adds rules for its subtree", func(t *testing.T) {
+		fsys := memTree(t, files)
+		config := `
+rules:
+  - name: temp
+    patterns: ['\.tmp$']
+  - names: [build]
+    dirs: true
+`
+		require.NoError(t, fsys.WriteFile("/base/repo/.wiper.yaml", []byte(config), 0o644))
+		sut := &Wiper{BaseDir: "/base", FS: fsys}
+
+		events := collectEvents(sut)
+		assert.ElementsMatch(t, []string{"/base/repo/sub/e.tmp", "/base/repo/sub/build"}, wipedPaths(events))
+		assert.True(t, existsOn(fsys, "/base/other/f.tmp"))
+		assert.True(t, existsOn(fsys, "/base/other/build"))
+
+		matched := eventsOfType(events, EventMatched)
+		require.Len(t, matched, 2)
+		rules := []string{matched[0].Rule, matched[1].Rule}
+		assert.ElementsMatch(t, []string{"temp", "/base/repo/.wiper.yaml rules[1]"}, rules)
+	})
+
+	t.Run("local rules take precedence over inherited ones", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.MkdirAll("/home/user", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/repo/sub/.wiper.yaml", []byte("rules:\n  - names: [keep.orig]\n    action: trash\n"), 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, BaseDir: "/base", FS: fsys}
+
+		events := collectEvents(sut)
+		trashed := eventsOfType(events, EventTrashed)
+		require.Len(t, trashed, 1)
+		assert.Equal(t, "/base/repo/sub/keep.orig", trashed[0].Path)
+		assert.Len(t, wipedPaths(events), 4)
+	})
+
+	t.Run("red case - broken .wiper.yaml is reported and ignored", func(t *testing.T) {
+		fsys := memTree(t, files)
+		require.NoError(t, fsys.WriteFile("/base/repo/.wiper.yaml", []byte("rules:\n  - patterns: ['(']\n"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/other/.wiper.yaml", []byte("preset: cobol\n"), 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, BaseDir: "/base", FS: fsys}
+
+		events := collectEvents(sut)
+		errs := eventsOfType(events, EventError)
+		require.Len(t, errs, 2)
+		assert.Equal(t, "config", errs[0].Op)
+		assert.Len(t, wipedPaths(events), 5)
+	})
+}
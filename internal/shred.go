@@ -0,0 +1,172 @@
+package wiper
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Shred modes
+const (
+	ShredRandom = "random"
+	ShredZeros  = "zeros"
+)
+
+const (
+	defaultShredPasses = 3
+	shredChunkSize     = 64 << 10
+)
+
+// Shred configures the shred action. Rules can override the global settings.
+type Shred struct {
+	Passes int    `json:"passes,omitempty" mapstructure:"passes" yaml:"passes"`
+	Mode   string `json:"mode,omitempty" mapstructure:"mode" yaml:"mode"`
+}
+
+// merge returns s with empty values taken from defaults.
+func (s Shred) merge(defaults Shred) Shred {
+	if s.Passes == 0 {
+		s.Passes = defaults.Passes
+	}
+	if s.Mode == "" {
+		s.Mode = defaults.Mode
+	}
+	return s
+}
+
+func (s Shred) passes() int {
+	if s.Passes <= 0 {
+		return defaultShredPasses
+	}
+	return s.Passes
+}
+
+func (s Shred) validate() error {
+	if s.Passes < 0 {
+		return fmt.Errorf("shred.passes must not be negative, got %d", s.Passes)
+	}
+	if s.Mode != "" && !slices.Contains([]string{ShredRandom, ShredZeros}, s.Mode) {
+		return fmt.Errorf("unknown shred.mode %q, expected %s or %s", s.Mode, ShredRandom, ShredZeros)
+	}
+	return nil
+}
+
+// shredEntry overwrites every regular file of target, truncates it and
+// removes it. The returned warning is set if the file system target lives on
+// keeps copies of overwritten blocks, or if files had other hard links and
+// were only unlinked.
+func (w *Wiper) shredEntry(target string, isDir bool, opts Shred) (string, error) {
+	warnings := []string{}
+	if warning := shredWarning(w.fs(), target); warning != "" {
+		warnings = append(warnings, warning)
+	}
+	var err error
+	if isDir {
+		var linked []string
+		linked, err = shredTree(w.fs(), target, opts)
+		warnings = append(warnings, linked...)
+		if err == nil {
+			err = w.fs().RemoveAll(target)
+		}
+	} else {
+		var linked string
+		linked, err = shredFile(w.fs(), target, opts)
+		if linked != "" {
+			warnings = append(warnings, linked)
+		}
+		if err == nil {
+			err = w.fs().Remove(target)
+		}
+	}
+	return strings.Join(warnings, "; "), err
+}
+
+// shredTree shreds the regular files below dir and returns the warnings of
+// the hard linked ones.
+func shredTree(fsys FileSystem, dir string, opts Shred) ([]string, error) {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	warnings := []string{}
+	for _, entry := range entries {
+		entryPath := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			linked, err := shredTree(fsys, entryPath, opts)
+			warnings = append(warnings, linked...)
+			if err != nil {
+				return warnings, err
+			}
+			continue
+		}
+		linked, err := shredFile(fsys, entryPath, opts)
+		if linked != "" {
+			warnings = append(warnings, linked)
+		}
+		if err != nil {
+			return warnings, err
+		}
+	}
+	return warnings, nil
+}
+
+// shredFile overwrites the content of a regular file and truncates it. Other
+// entries like symlinks are left alone, they are removed with their parent.
+// The content of a file with other hard links is left alone as well, as it is
+// still reachable by them; the returned warning says so.
+func shredFile(fsys FileSystem, name string, opts Shred) (string, error) {
+	info, err := fsys.Lstat(name)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", nil
+	}
+	if links, ok := sysLinks(info.Sys()); ok && links > 1 {
+		return fmt.Sprintf("%s has %d hard links, only this one was removed and the content was not overwritten", name, links), nil
+	}
+
+	for pass := 0; pass < opts.passes(); pass++ {
+		if err := overwrite(fsys, name, info.Size(), opts.Mode == ShredZeros); err != nil {
+			return "", err
+		}
+	}
+	return "", fsys.Truncate(name, 0)
+}
+
+func overwrite(fsys FileSystem, name string, size int64, zeros bool) error {
+	file, err := fsys.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, shredChunkSize)
+	for written := int64(0); written < size; {
+		chunk := buf[:min(int64(len(buf)), size-written)]
+		if !zeros {
+			_, _ = rand.Read(chunk)
+		}
+		n, err := file.Write(chunk)
+		written += int64(n)
+		if err != nil {
+			return errors.Join(err, file.Close())
+		}
+	}
+	return errors.Join(file.Sync(), file.Close())
+}
+
+// shredWarning returns why overwriting files below path may not destroy their
+// content, or an empty string.
+func shredWarning(fsys FileSystem, path string) string {
+	if _, ok := fsys.(OSFileSystem); !ok {
+		return ""
+	}
+	if name := copyOnWriteFileSystem(path); name != "" {
+		return fmt.Sprintf("%s is a copy-on-write file system, the shredded data may still be recoverable", name)
+	}
+	return ""
+}
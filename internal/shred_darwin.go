@@ -0,0 +1,21 @@
+package wiper
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyOnWriteFileSystem returns the name of the file system path lives on if
+// it is copy-on-write.
+func copyOnWriteFileSystem(path string) string {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(filepath.Dir(path), &stat); err != nil {
+		return ""
+	}
+	switch name := unix.ByteSliceToString(stat.Fstypename[:]); name {
+	case "apfs", "zfs":
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,24 @@
+package wiper
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// Magic numbers of copy-on-write file systems as reported by statfs(2).
+var copyOnWriteMagics = map[uint32]string{
+	0x9123683e: "btrfs",
+	0x2fc12fc1: "zfs",
+	0xca451a4e: "bcachefs",
+}
+
+// copyOnWriteFileSystem returns the name of the file system path lives on if
+// it is copy-on-write.
+func copyOnWriteFileSystem(path string) string {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(filepath.Dir(path), &stat); err != nil {
+		return ""
+	}
+	return copyOnWriteMagics[uint32(stat.Type)]
+}
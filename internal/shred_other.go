@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package wiper
+
+// copyOnWriteFileSystem can't detect copy-on-write file systems on this
+// platform.
+func copyOnWriteFileSystem(path string) string {
+	return ""
+}
@@ -0,0 +1,100 @@
+package wiper
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShred(t *testing.T) {
+	t.Run("green case - file content is overwritten and truncated", func(t *testing.T) {
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/creds.txt", []byte("password"), 0o600))
+
+		require.NoError(t, overwrite(fsys, "/base/creds.txt", 8, true))
+		content, err := fsys.ReadFile("/base/creds.txt")
+		require.NoError(t, err)
+		assert.Equal(t, make([]byte, 8), content)
+
+		require.NoError(t, overwrite(fsys, "/base/creds.txt", 8, false))
+		content, err = fsys.ReadFile("/base/creds.txt")
+		require.NoError(t, err)
+		assert.Len(t, content, 8)
+		assert.NotEqual(t, []byte("password"), content)
+
+		warning, err := shredFile(fsys, "/base/creds.txt", Shred{Passes: 2, Mode: ShredZeros})
+		require.NoError(t, err)
+		assert.Empty(t, warning)
+		content, err = fsys.ReadFile("/base/creds.txt")
+		require.NoError(t, err)
+		assert.Empty(t, content)
+	})
+
+	t.Run("directories are shredded recursively", func(t *testing.T) {
+		testDir := t.TempDir()
+		dumps := filepath.Join(testDir, "dumps")
+		require.NoError(t, os.MkdirAll(filepath.Join(dumps, "nested"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dumps, "nested", "a.json"), bytes.Repeat([]byte("x"), 100_000), 0o600))
+		require.NoError(t, os.Symlink("/etc/hostname", filepath.Join(dumps, "link")))
+		sut := &Wiper{WipeOutDirs: []string{"dumps"}, BaseDir: testDir, Action: ActionShred}
+
+		events := collectEvents(sut)
+		require.Empty(t, eventsOfType(events, EventError))
+		shredded := eventsOfType(events, EventShredded)
+		require.Len(t, shredded, 1)
+		assert.True(t, shredded[0].IsDir)
+		assert.NoDirExists(t, dumps)
+		assert.FileExists(t, "/etc/hostname")
+	})
+
+	t.Run("red case - hard linked files are only unlinked", func(t *testing.T) {
+		testDir := t.TempDir()
+		outside := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(testDir, "dumps"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(outside, "creds.txt"), []byte("password"), 0o600))
+		require.NoError(t, os.Link(filepath.Join(outside, "creds.txt"), filepath.Join(testDir, "creds.orig")))
+		require.NoError(t, os.Link(filepath.Join(outside, "creds.txt"), filepath.Join(testDir, "dumps", "creds.txt")))
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, WipeOutDirs: []string{"dumps"}, BaseDir: testDir, Action: ActionShred}
+
+		events := collectEvents(sut)
+		require.Empty(t, eventsOfType(events, EventError))
+		shredded := eventsOfType(events, EventShredded)
+		require.Len(t, shredded, 2)
+		for _, event := range shredded {
+			assert.Contains(t, event.Reason, "hard links")
+		}
+		assert.NoFileExists(t, filepath.Join(testDir, "creds.orig"))
+		assert.NoDirExists(t, filepath.Join(testDir, "dumps"))
+		content, err := os.ReadFile(filepath.Join(outside, "creds.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "password", string(content))
+	})
+
+	t.Run("Report collects shred warnings", func(t *testing.T) {
+		report := &Report{}
+		report.Add(Event{Type: EventShredded, Path: "/a"})
+		report.Add(Event{Type: EventShredded, Path: "/b", Reason: "btrfs is a copy-on-write file system"})
+		assert.Len(t, report.Shredded, 2)
+		require.Len(t, report.ShredWarnings(), 1)
+		assert.Equal(t, "/b", report.ShredWarnings()[0].Path)
+		assert.Contains(t, report.ShredWarnings()[0].String(), "copy-on-write")
+	})
+
+	t.Run("red case - invalid shred settings", func(t *testing.T) {
+		sut := Wiper{Shred: Shred{Mode: "ones"}}
+		assert.Error(t, sut.Validate())
+		sut = Wiper{Rules: []Rule{{Names: []string{"x"}, Shred: Shred{Passes: -1}}}}
+		assert.Error(t, sut.Validate())
+	})
+
+	t.Run("rule settings override the global ones", func(t *testing.T) {
+		opts := Shred{Passes: 1}.merge(Shred{Passes: 5, Mode: ShredZeros})
+		assert.Equal(t, Shred{Passes: 1, Mode: ShredZeros}, opts)
+		assert.Equal(t, defaultShredPasses, Shred{}.passes())
+	})
+}
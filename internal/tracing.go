@@ -0,0 +1,92 @@
+package wiper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of wipe runs. It does nothing unless SetupTracing
+// installed an exporter.
+var tracer = otel.Tracer("github.com/steffakasid/wiper")
+
+// SetupTracing installs the trace exporter selected by OTEL_TRACES_EXPORTER:
+// otlp, console or none. Tracing stays off unless OTEL_TRACES_EXPORTER or an
+// OTLP endpoint is set. The exporters read the other OTEL_* variables
+// themselves. The returned function flushes the spans and has to be called
+// before wiper exits.
+func SetupTracing(ctx context.Context, version string) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER")))
+	if name == "" && (os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "") {
+		name = "otlp"
+	}
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" {
+		name = "none"
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch name {
+	case "", "none":
+		return noop, nil
+	case "otlp":
+		exporter, err = newOTLPExporter(ctx)
+	case "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return noop, fmt.Errorf("OTEL_TRACES_EXPORTER: unsupported exporter %q, expected otlp, console or none", name)
+	}
+	if err != nil {
+		return noop, err
+	}
+
+	res, err := resource.Merge(
+		resource.NewSchemaless(attribute.String("service.name", "wiper"), attribute.String("service.version", version)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return noop, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// newOTLPExporter returns the OTLP exporter of the protocol selected by
+// OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or OTEL_EXPORTER_OTLP_PROTOCOL.
+func newOTLPExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	switch protocol {
+	case "", "http/protobuf":
+		return otlptracehttp.New(ctx)
+	case "grpc":
+		return otlptracegrpc.New(ctx)
+	}
+	return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL: unsupported protocol %q, expected http/protobuf or grpc", protocol)
+}
+
+// endSpan records err on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,73 @@
+package wiper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	t.Run("green case - runs are traced", func(t *testing.T) {
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		previous := otel.GetTracerProvider()
+		otel.SetTracerProvider(provider)
+		t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base/sub", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/sub/a.orig", []byte("abc"), 0o644))
+		require.NoError(t, fsys.WriteFile("/base/b.txt", nil, 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, BaseDir: "/base", FS: fsys}
+		collectEvents(sut)
+		require.NoError(t, provider.ForceFlush(context.Background()))
+
+		spans := exporter.GetSpans()
+		byName := map[string][]tracetest.SpanStub{}
+		for _, span := range spans {
+			byName[span.Name] = append(byName[span.Name], span)
+		}
+		require.Len(t, byName["WipeFiles"], 1)
+		assert.Len(t, byName["wipeDir"], 2)
+		assert.Len(t, byName["ReadDir"], 2)
+		assert.Len(t, byName["handleDir"], 1)
+		assert.Len(t, byName["handleFile"], 2)
+		require.Len(t, byName["wipe"], 1)
+		require.Len(t, byName["remove"], 1)
+
+		root := byName["WipeFiles"][0]
+		assert.False(t, root.Parent.IsValid())
+		assert.Contains(t, root.Attributes, attribute.Int("wiper.wiped_files", 1))
+		wipe := byName["wipe"][0]
+		assert.Equal(t, root.SpanContext.TraceID(), wipe.SpanContext.TraceID())
+		assert.Contains(t, wipe.Attributes, attribute.String("wiper.action", ActionRemove))
+		assert.Contains(t, wipe.Attributes, attribute.Int64("wiper.size", 3))
+		assert.Equal(t, wipe.SpanContext.SpanID(), byName["remove"][0].Parent.SpanID())
+	})
+
+	t.Run("exporter selection", func(t *testing.T) {
+		for env, wantErr := range map[string]bool{"": false, "none": false, "console": false, "zipkin": true} {
+			t.Setenv("OTEL_TRACES_EXPORTER", env)
+			shutdown, err := SetupTracing(context.Background(), "test")
+			if wantErr {
+				assert.Error(t, err, env)
+			} else {
+				assert.NoError(t, err, env)
+			}
+			assert.NoError(t, shutdown(context.Background()), env)
+		}
+	})
+
+	t.Run("red case - unknown OTLP protocol", func(t *testing.T) {
+		t.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+		t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json")
+		_, err := SetupTracing(context.Background(), "test")
+		assert.ErrorContains(t, err, "http/json")
+	})
+}
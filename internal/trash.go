@@ -0,0 +1,291 @@
+package wiper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/steffakasid/eslog"
+)
+
+// trashInfoDir is the folder inside the trash where wiper keeps one metadata
+// file per item it moved there. Items without metadata are never touched by
+// the trash commands.
+const trashInfoDir = ".wiper"
+
+// TrashRetention limits what wiper keeps in the trash. Both values are
+// optional; OlderThan accepts e.g. "14d", MaxSize e.g. "5GB".
+type TrashRetention struct {
+	OlderThan string `json:"older_than,omitempty" mapstructure:"older_than" yaml:"older_than"`
+	MaxSize   string `json:"max_size,omitempty" mapstructure:"max_size" yaml:"max_size"`
+}
+
+// Limits returns the parsed retention values. Zero means unlimited.
+func (r TrashRetention) Limits() (time.Duration, int64, error) {
+	var olderThan time.Duration
+	var maxSize int64
+	var err error
+	if r.OlderThan != "" {
+		if olderThan, err = ParseAge(r.OlderThan); err != nil {
+			return 0, 0, fmt.Errorf("trash_retention.older_than: %w", err)
+		}
+	}
+	if r.MaxSize != "" {
+		if maxSize, err = ParseSize(r.MaxSize); err != nil {
+			return 0, 0, fmt.Errorf("trash_retention.max_size: %w", err)
+		}
+	}
+	return olderThan, maxSize, nil
+}
+
+func (r TrashRetention) Enabled() bool {
+	return r.OlderThan != "" || r.MaxSize != ""
+}
+
+// TrashItem is the metadata of an item wiper moved to the trash.
+type TrashItem struct {
+	Name         string    `json:"name"`
+	OriginalPath string    `json:"original_path"`
+	TrashedAt    time.Time `json:"trashed_at"`
+	IsDir        bool      `json:"is_dir"`
+	Size         int64     `json:"size"`
+}
+
+// Trash gives access to the items wiper moved to the trash folder Dir.
+type Trash struct {
+	Dir string
+	FS  FileSystem
+	// w writes the audit records of deleted items, if set.
+	w *Wiper
+}
+
+// TrashDir returns the trash folder used by the trash action.
+func TrashDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".Trash")
+}
+
+// Trash returns the trash the trash action moves items to.
+func (w *Wiper) Trash() *Trash {
+	return &Trash{Dir: TrashDir(), FS: w.fs(), w: w}
+}
+
+// Path returns the location of item inside the trash.
+func (t *Trash) Path(item TrashItem) string {
+	return filepath.Join(t.Dir, item.Name)
+}
+
+// record stores the metadata of an item that was just moved to destination.
+func (t *Trash) record(originalPath, destination string, isDir bool) error {
+	if err := mkdirAll(t.FS, filepath.Join(t.Dir, trashInfoDir), 0o700); err != nil {
+		return err
+	}
+
+	size, err := sizeOf(t.FS, destination)
+	if err != nil {
+		return err
+	}
+	item := TrashItem{
+		Name:         filepath.Base(destination),
+		OriginalPath: originalPath,
+		TrashedAt:    time.Now(),
+		IsDir:        isDir,
+		Size:         size,
+	}
+	content, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	// the metadata is renamed into place once it is complete, so List never
+	// reads a partly written file
+	info := t.infoPath(item.Name)
+	file, err := t.FS.Create(info + ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err := errors.Join(err, file.Close()); err != nil {
+		return errors.Join(err, t.FS.Remove(info+".tmp"))
+	}
+	return t.FS.Rename(info+".tmp", info)
+}
+
+// List returns the tracked items still present in the trash, oldest first.
+// Metadata of items which were removed from the trash by other means is
+// deleted, metadata which can't be read is skipped with a warning.
+func (t *Trash) List() ([]TrashItem, error) {
+	entries, err := t.FS.ReadDir(filepath.Join(t.Dir, trashInfoDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []TrashItem{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items := []TrashItem{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		item, err := t.readInfo(filepath.Join(t.Dir, trashInfoDir, entry.Name()))
+		if err != nil {
+			eslog.Warnf("Skipping item %s of the trash: %s", strings.TrimSuffix(entry.Name(), ".json"), err)
+			continue
+		}
+		if !existsOn(t.FS, t.Path(item)) {
+			_ = t.FS.Remove(t.infoPath(item.Name))
+			continue
+		}
+		items = append(items, item)
+	}
+	slices.SortFunc(items, func(a, b TrashItem) int {
+		return a.TrashedAt.Compare(b.TrashedAt)
+	})
+	return items, nil
+}
+
+// Delete permanently removes item and its metadata from the trash.
+func (t *Trash) Delete(item TrashItem) error {
+	err := t.delete(item, "")
+	if t.w != nil {
+		err = errors.Join(err, t.w.closeAuditLog())
+	}
+	return err
+}
+
+// delete removes item like Delete and records it in the audit log with rule.
+// The audit log is left open.
+func (t *Trash) delete(item TrashItem, rule string) error {
+	var record *AuditRecord
+	if t.w != nil {
+		var err error
+		if record, err = t.w.auditRecord(t.Path(item), rule, "purge", item.IsDir, item.Size); err != nil {
+			return err
+		}
+	}
+	err := t.FS.RemoveAll(t.Path(item))
+	if err == nil {
+		err = t.FS.Remove(t.infoPath(item.Name))
+	}
+	if t.w != nil {
+		err = errors.Join(err, t.w.audit(record, "", err))
+	}
+	return err
+}
+
+// Empty permanently removes all tracked items and returns them.
+func (t *Trash) Empty() ([]TrashItem, error) {
+	return t.purge(0, 0, true, "")
+}
+
+// Purge permanently removes tracked items trashed more than olderThan ago and
+// then, oldest first, as many items as needed to bring the total size of the
+// tracked items down to maxSize. Zero values disable the respective limit; all
+// is used to remove every tracked item. The removed items are returned.
+func (t *Trash) Purge(olderThan time.Duration, maxSize int64, all bool) ([]TrashItem, error) {
+	return t.purge(olderThan, maxSize, all, "")
+}
+
+// purge is Purge, with rule recorded in the audit log for the removed items.
+func (t *Trash) purge(olderThan time.Duration, maxSize int64, all bool, rule string) ([]TrashItem, error) {
+	items, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, item := range items {
+		total += item.Size
+	}
+
+	purged := []TrashItem{}
+	var errs []error
+	for _, item := range items {
+		expired := olderThan > 0 && time.Since(item.TrashedAt) > olderThan
+		oversized := maxSize > 0 && total > maxSize
+		if !all && !expired && !oversized {
+			continue
+		}
+		if err := t.delete(item, rule); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		total -= item.Size
+		purged = append(purged, item)
+	}
+	if t.w != nil {
+		errs = append(errs, t.w.closeAuditLog())
+	}
+	return purged, errors.Join(errs...)
+}
+
+// ApplyRetention purges the trash according to retention.
+func (t *Trash) ApplyRetention(retention TrashRetention) ([]TrashItem, error) {
+	olderThan, maxSize, err := retention.Limits()
+	if err != nil {
+		return nil, err
+	}
+	return t.purge(olderThan, maxSize, false, "trash_retention")
+}
+
+func (t *Trash) infoPath(name string) string {
+	return filepath.Join(t.Dir, trashInfoDir, name+".json")
+}
+
+func (t *Trash) readInfo(path string) (TrashItem, error) {
+	item := TrashItem{}
+	file, err := t.FS.Open(path)
+	if err != nil {
+		return item, err
+	}
+	content, err := io.ReadAll(file)
+	if err = errors.Join(err, file.Close()); err != nil {
+		return item, err
+	}
+	if err := json.Unmarshal(content, &item); err != nil {
+		return item, fmt.Errorf("%s: %w", path, err)
+	}
+	// the name is removed from the trash later, so it must not point to the
+	// trash itself or outside of it
+	if item.Name == "" || item.Name == "." || item.Name == ".." || filepath.Base(item.Name) != item.Name || t.infoPath(item.Name) != path {
+		return item, fmt.Errorf("%s: invalid name %q", path, item.Name)
+	}
+	return item, nil
+}
+
+// sizeOf returns the size of path, including everything below it for
+// directories. Symlinks are not followed.
+func sizeOf(fsys FileSystem, path string) (int64, error) {
+	info, err := fsys.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+
+	entries, err := fsys.ReadDir(path)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, entry := range entries {
+		entrySize, err := sizeOf(fsys, filepath.Join(path, entry.Name()))
+		if err != nil {
+			return 0, err
+		}
+		size += entrySize
+	}
+	return size, nil
+}
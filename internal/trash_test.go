@@ -0,0 +1,198 @@
+package wiper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backdate rewrites the metadata of a trashed item as if it was trashed age ago.
+func backdate(t *testing.T, trash *Trash, name string, age time.Duration) {
+	t.Helper()
+
+	item, err := trash.readInfo(trash.infoPath(name))
+	require.NoError(t, err)
+	item.TrashedAt = time.Now().Add(-age)
+	content, err := json.Marshal(item)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(trash.infoPath(name), content, 0o600))
+}
+
+func trashFixture(t *testing.T) (*Wiper, *Trash, string) {
+	t.Helper()
+
+	testHome := t.TempDir()
+	t.Setenv("HOME", testHome)
+	baseDir := filepath.Join(testHome, "source")
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "node_modules", "pkg"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "node_modules", "pkg", "index.js"), make([]byte, 300), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "old.orig"), make([]byte, 100), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "new.orig"), make([]byte, 200), 0o644))
+
+	sut := &Wiper{
+		WipeOutPattern: []string{`.*\.orig$`},
+		WipeOutDirs:    []string{"node_modules"},
+		BaseDir:        baseDir,
+		UseTrash:       true,
+	}
+	return sut, sut.Trash(), baseDir
+}
+
+func TestTrash(t *testing.T) {
+	t.Run("green case - trashed items are tracked with metadata", func(t *testing.T) {
+		sut, trash, baseDir := trashFixture(t)
+		events := collectEvents(sut)
+		require.Empty(t, eventsOfType(events, EventError))
+
+		// not trashed by wiper
+		require.NoError(t, os.WriteFile(filepath.Join(trash.Dir, "foreign.txt"), nil, 0o644))
+
+		items, err := trash.List()
+		require.NoError(t, err)
+		require.Len(t, items, 3)
+
+		byName := map[string]TrashItem{}
+		for _, item := range items {
+			byName[item.Name] = item
+		}
+		assert.Equal(t, filepath.Join(baseDir, "node_modules"), byName["node_modules"].OriginalPath)
+		assert.True(t, byName["node_modules"].IsDir)
+		assert.Equal(t, int64(300), byName["node_modules"].Size)
+		assert.Equal(t, int64(100), byName["old.orig"].Size)
+		assert.NotContains(t, byName, "foreign.txt")
+	})
+
+	t.Run("metadata is only listed once it is complete", func(t *testing.T) {
+		sut, trash, _ := trashFixture(t)
+		collectEvents(sut)
+		// what a run killed while recording an item leaves behind
+		require.NoError(t, os.WriteFile(filepath.Join(trash.Dir, trashInfoDir, "later.orig.json.tmp"), []byte(`{"name":`), 0o600))
+
+		entries, err := os.ReadDir(filepath.Join(trash.Dir, trashInfoDir))
+		require.NoError(t, err)
+		assert.Len(t, entries, 4)
+		items, err := trash.List()
+		require.NoError(t, err)
+		assert.Len(t, items, 3)
+	})
+
+	t.Run("red case - unreadable metadata is skipped", func(t *testing.T) {
+		sut, trash, _ := trashFixture(t)
+		collectEvents(sut)
+		require.NoError(t, os.WriteFile(filepath.Join(trash.Dir, trashInfoDir, "broken.json"), []byte(`{"name":`), 0o600))
+
+		items, err := trash.List()
+		require.NoError(t, err)
+		assert.Len(t, items, 3)
+		purged, err := trash.Empty()
+		require.NoError(t, err)
+		assert.Len(t, purged, 3)
+	})
+
+	t.Run("red case - metadata with invalid names is skipped", func(t *testing.T) {
+		sut, trash, _ := trashFixture(t)
+		collectEvents(sut)
+		outside := filepath.Join(filepath.Dir(trash.Dir), "outside")
+		require.NoError(t, os.WriteFile(outside, []byte("keep"), 0o644))
+		for file, name := range map[string]string{"empty": "", "dot": ".", "escape": "../outside", "other": "old.orig"} {
+			content := fmt.Sprintf(`{"name":%q,"original_path":"/x","trashed_at":"2020-01-01T00:00:00Z"}`, name)
+			require.NoError(t, os.WriteFile(filepath.Join(trash.Dir, trashInfoDir, file+".json"), []byte(content), 0o600))
+		}
+
+		items, err := trash.List()
+		require.NoError(t, err)
+		assert.Len(t, items, 3)
+		purged, err := trash.Empty()
+		require.NoError(t, err)
+		assert.Len(t, purged, 3)
+		assert.DirExists(t, trash.Dir)
+		assert.FileExists(t, outside)
+	})
+
+	t.Run("List drops metadata of items removed from the trash by other means", func(t *testing.T) {
+		sut, trash, _ := trashFixture(t)
+		collectEvents(sut)
+		require.NoError(t, os.Remove(filepath.Join(trash.Dir, "old.orig")))
+
+		items, err := trash.List()
+		require.NoError(t, err)
+		assert.Len(t, items, 2)
+		assert.NoFileExists(t, trash.infoPath("old.orig"))
+	})
+
+	t.Run("Empty deletes only tracked items", func(t *testing.T) {
+		sut, trash, _ := trashFixture(t)
+		collectEvents(sut)
+		foreign := filepath.Join(trash.Dir, "foreign.txt")
+		require.NoError(t, os.WriteFile(foreign, nil, 0o644))
+
+		purged, err := trash.Empty()
+		require.NoError(t, err)
+		assert.Len(t, purged, 3)
+		assert.FileExists(t, foreign)
+		assert.NoDirExists(t, filepath.Join(trash.Dir, "node_modules"))
+
+		items, err := trash.List()
+		require.NoError(t, err)
+		assert.Empty(t, items)
+	})
+
+	t.Run("Purge by age", func(t *testing.T) {
+		sut, trash, _ := trashFixture(t)
+		collectEvents(sut)
+		backdate(t, trash, "old.orig", 20*24*time.Hour)
+
+		purged, err := trash.Purge(14*24*time.Hour, 0, false)
+		require.NoError(t, err)
+		require.Len(t, purged, 1)
+		assert.Equal(t, "old.orig", purged[0].Name)
+		assert.NoFileExists(t, filepath.Join(trash.Dir, "old.orig"))
+		assert.FileExists(t, filepath.Join(trash.Dir, "new.orig"))
+	})
+
+	t.Run("Purge by size removes oldest items first", func(t *testing.T) {
+		sut, trash, _ := trashFixture(t)
+		collectEvents(sut)
+		backdate(t, trash, "node_modules", 3*time.Hour)
+		backdate(t, trash, "old.orig", 2*time.Hour)
+		backdate(t, trash, "new.orig", 1*time.Hour)
+
+		purged, err := trash.ApplyRetention(TrashRetention{MaxSize: "250B"})
+		require.NoError(t, err)
+		require.Len(t, purged, 2)
+		assert.Equal(t, "node_modules", purged[0].Name)
+		assert.Equal(t, "old.orig", purged[1].Name)
+		assert.FileExists(t, filepath.Join(trash.Dir, "new.orig"))
+	})
+
+	t.Run("red case - invalid retention", func(t *testing.T) {
+		trash := &Trash{Dir: t.TempDir(), FS: OSFileSystem{}}
+		_, err := trash.ApplyRetention(TrashRetention{OlderThan: "soon"})
+		assert.Error(t, err)
+
+		sut := Wiper{TrashRetention: TrashRetention{MaxSize: "lots"}}
+		assert.Error(t, sut.Validate())
+	})
+
+	t.Run("memory file system", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := NewMemFileSystem()
+		require.NoError(t, fsys.MkdirAll("/base", 0o755))
+		require.NoError(t, fsys.MkdirAll("/home/user", 0o755))
+		require.NoError(t, fsys.WriteFile("/base/file.orig", []byte("12345"), 0o644))
+		sut := &Wiper{WipeOutPattern: []string{`.*\.orig$`}, BaseDir: "/base", UseTrash: true, FS: fsys}
+
+		collectEvents(sut)
+		items, err := sut.Trash().List()
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, "/base/file.orig", items[0].OriginalPath)
+		assert.Equal(t, int64(5), items[0].Size)
+	})
+}
@@ -0,0 +1,71 @@
+package wiper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{input: "512", expected: 512},
+		{input: "10B", expected: 10},
+		{input: "64KB", expected: 64_000},
+		{input: "5GB", expected: 5_000_000_000},
+		{input: "1.5GiB", expected: 1_610_612_736},
+		{input: "2m", expected: 2 << 20},
+		{input: " 3 MB ", expected: 3_000_000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseSize(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	t.Run("red case - invalid sizes", func(t *testing.T) {
+		for _, input := range []string{"", "GB", "5XB", "1.2.3MB"} {
+			_, err := ParseSize(input)
+			assert.Error(t, err, input)
+		}
+	})
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "999 B", FormatSize(999))
+	assert.Equal(t, "1.0 KiB", FormatSize(1024))
+	assert.Equal(t, "1.5 GiB", FormatSize(1_610_612_736))
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{input: "14d", expected: 14 * 24 * time.Hour},
+		{input: "2w", expected: 14 * 24 * time.Hour},
+		{input: "36h", expected: 36 * time.Hour},
+		{input: "10m", expected: 10 * time.Minute},
+		{input: "0.5d", expected: 12 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseAge(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	t.Run("red case - invalid ages", func(t *testing.T) {
+		for _, input := range []string{"", "soon", "xd"} {
+			_, err := ParseAge(input)
+			assert.Error(t, err, input)
+		}
+	})
+}
@@ -27,6 +27,9 @@ func refreshInstanceFromViper() error {
 	if err := viper.Unmarshal(next); err != nil {
 		return err
 	}
+	if err := next.Validate(); err != nil {
+		return err
+	}
 
 	wiper = next
 	return nil
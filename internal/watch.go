@@ -0,0 +1,327 @@
+package wiper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/steffakasid/eslog"
+)
+
+// defaultWatchDebounce is how long an entry has to stay unchanged before
+// wiper watch checks it, unless watch.debounce is set.
+const defaultWatchDebounce = 2 * time.Second
+
+// Watch holds the settings of wiper watch.
+type Watch struct {
+	// Debounce is how long an entry has to stay unchanged before it is
+	// checked, e.g. "500ms".
+	Debounce string `json:"debounce,omitempty" mapstructure:"debounce" yaml:"debounce"`
+	// Delay is how long a matched entry is kept after it appeared, e.g. "10m".
+	// Rules can override it with their own delay.
+	Delay string `json:"delay,omitempty" mapstructure:"delay" yaml:"delay"`
+}
+
+func (w Watch) debounce() (time.Duration, error) {
+	if w.Debounce == "" {
+		return defaultWatchDebounce, nil
+	}
+	debounce, err := ParseAge(w.Debounce)
+	if err != nil {
+		return 0, fmt.Errorf("watch.debounce: %w", err)
+	}
+	return debounce, nil
+}
+
+func (w Watch) delay() (time.Duration, error) {
+	if w.Delay == "" {
+		return 0, nil
+	}
+	delay, err := ParseAge(w.Delay)
+	if err != nil {
+		return 0, fmt.Errorf("watch.delay: %w", err)
+	}
+	return delay, nil
+}
+
+func (w Watch) validate() error {
+	if _, err := w.debounce(); err != nil {
+		return err
+	}
+	_, err := w.delay()
+	return err
+}
+
+// delay returns how long wiper watch keeps the matches of the rule.
+func (r *Rule) delay(w *Wiper) time.Duration {
+	if r.Delay != "" {
+		delay, _ := ParseAge(r.Delay)
+		return delay
+	}
+	delay, _ := w.Watch.delay()
+	return delay
+}
+
+// watchRun is a running wiper watch. Every created or renamed entry gets a
+// timer which is reset on further changes and checks the entry once it
+// fires.
+type watchRun struct {
+	ctx      context.Context
+	w        *Wiper
+	watcher  *fsnotify.Watcher
+	debounce time.Duration
+	trash    string
+	events   chan Event
+	mu       sync.Mutex
+	pending  map[string]*pendingEntry
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type pendingEntry struct {
+	timer *time.Timer
+	seen  time.Time
+}
+
+// WatchFiles wipes matching entries below BaseDir as they are created or
+// renamed, until ctx is done. Entries which already exist are left alone, run
+// WipeFiles for them. events is closed when WatchFiles returns.
+func (w *Wiper) WatchFiles(ctx context.Context, events chan Event) error {
+	defer w.finishRun(events)
+	if _, ok := w.fs().(OSFileSystem); !ok {
+		return errors.New("watch only supports the OS file system")
+	}
+	debounce, err := w.Watch.debounce()
+	if err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	r := &watchRun{
+		ctx:      context.WithoutCancel(ctx),
+		w:        w,
+		watcher:  watcher,
+		debounce: debounce,
+		trash:    initTrash(w),
+		events:   events,
+		pending:  map[string]*pendingEntry{},
+	}
+	base := filepath.Clean(w.BaseDir)
+	if err := r.addTree(base, false); err != nil {
+		return err
+	}
+	eslog.Infof("Watching %d directories below %s.", len(watcher.WatchList()), base)
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.stop()
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				r.stop()
+				return nil
+			}
+			r.handle(event)
+		case err, ok := <-watcher.Errors:
+			if ok {
+				events <- errorEvent("watch", base, true, err)
+			}
+		}
+	}
+}
+
+// handle schedules a check of the entry of a file system event.
+func (r *watchRun) handle(event fsnotify.Event) {
+	if r.w.ownPath(event.Name) {
+		return
+	}
+	switch {
+	case event.Has(fsnotify.Create):
+		if info, err := r.w.fs().Lstat(event.Name); err == nil && info.IsDir() && !r.skipDir(event.Name) {
+			if err := r.addTree(event.Name, true); err != nil {
+				r.events <- errorEvent("watch", event.Name, true, err)
+			}
+		}
+		r.schedule(event.Name, r.debounce, time.Now())
+	case event.Has(fsnotify.Write):
+		r.mu.Lock()
+		pending, ok := r.pending[event.Name]
+		r.mu.Unlock()
+		if ok {
+			r.schedule(event.Name, r.debounce, pending.seen)
+		}
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		r.cancel(event.Name)
+	}
+}
+
+// addTree watches dir and its subdirectories. If schedule is set, the
+// entries found are checked like newly created ones, as they may have been
+// created before the watch was in place.
+func (r *watchRun) addTree(dir string, schedule bool) error {
+	if err := r.watcher.Add(dir); err != nil {
+		return err
+	}
+	entries, err := r.w.fs().ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		target := filepath.Join(dir, entry.Name())
+		if schedule {
+			r.schedule(target, r.debounce, time.Now())
+		}
+		if !entry.IsDir() || r.skipDir(target) {
+			continue
+		}
+		if err := r.addTree(target, schedule); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipDir reports whether the directory target is left unwatched. Ignored
+// directories are still watched, check skips their entries.
+func (r *watchRun) skipDir(target string) bool {
+	return r.w.skipDir(nil, target) != ""
+}
+
+// schedule (re)starts the timer of target. seen is when the entry appeared.
+func (r *watchRun) schedule(target string, after time.Duration, seen time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	if pending, ok := r.pending[target]; ok {
+		pending.timer.Stop()
+	}
+	r.pending[target] = &pendingEntry{seen: seen, timer: time.AfterFunc(after, func() {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return
+		}
+		r.wg.Add(1)
+		delete(r.pending, target)
+		r.mu.Unlock()
+		defer r.wg.Done()
+		r.check(target, seen)
+	})}
+}
+
+func (r *watchRun) cancel(target string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if pending, ok := r.pending[target]; ok {
+		pending.timer.Stop()
+		delete(r.pending, target)
+	}
+}
+
+// stop cancels all pending checks and waits for running ones.
+func (r *watchRun) stop() {
+	r.mu.Lock()
+	r.closed = true
+	for _, pending := range r.pending {
+		pending.timer.Stop()
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+// check wipes target if a rule matches it and its delay has passed since it
+// appeared at seen. keep_newest rules are only applied by full runs.
+func (r *watchRun) check(target string, seen time.Time) {
+	w := r.w
+	info, err := w.fs().Lstat(target)
+	if err != nil {
+		return
+	}
+	isDir := info.IsDir()
+	name := filepath.Base(target)
+	if !isDir && isScopeFile(name) {
+		return
+	}
+	if isDir && r.skipDir(target) {
+		return
+	}
+
+	w.mu.Lock()
+	if isDir {
+		w.InspectedDirs++
+	} else {
+		w.InspectedFiles++
+	}
+	w.mu.Unlock()
+
+	sc, reason := w.scopeFor(filepath.Dir(target), r.events)
+	if reason != "" {
+		r.events <- Event{Type: EventSkipped, Path: target, IsDir: isDir, Reason: reason}
+		return
+	}
+	if sc.ignored(target, isDir) {
+		r.events <- Event{Type: EventSkipped, Path: target, IsDir: isDir, Reason: ignoreFileName}
+		return
+	}
+	rule, err := w.matchingRule(w.newEntry(target, isDir, sc))
+	if err != nil {
+		r.events <- errorEvent("match", target, isDir, err)
+	}
+	if rule == nil {
+		return
+	}
+	if reason := w.protected(sc, target, isDir); reason != "" {
+		r.events <- Event{Type: EventSkipped, Path: target, IsDir: isDir, Rule: rule.Name, Reason: reason}
+		return
+	}
+	if rule.KeepNewest > 0 {
+		r.events <- Event{Type: EventSkipped, Path: target, IsDir: isDir, Rule: rule.Name, Reason: "keep_newest is only applied by full runs"}
+		return
+	}
+	if remaining := time.Until(seen.Add(rule.delay(w))); remaining > 0 {
+		eslog.Debugf("%s matched %s, wiping it in %s", target, rule.Name, remaining.Round(time.Second))
+		r.schedule(target, remaining, seen)
+		return
+	}
+	w.wipe(r.ctx, target, r.trash, rule, isDir, r.events)
+}
+
+// scopeFor returns the scope of the entries in dir, which lies below
+// BaseDir. If a run never gets to them because dir or one of its parents is
+// excluded or ignored, it returns why instead.
+func (w *Wiper) scopeFor(dir string, events chan Event) (*scope, string) {
+	base := filepath.Clean(w.BaseDir)
+	sc := w.rootScope(base, events)
+	for _, parent := range ancestors(dir) {
+		if parent != base && !isWithin(base, parent) {
+			continue
+		}
+		if parent != base {
+			if reason := w.skipDir(sc, parent); reason != "" {
+				return nil, reason
+			}
+		}
+		entries, err := w.fs().ReadDir(parent)
+		if err != nil {
+			continue
+		}
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		sc = w.enterScope(sc, parent, names, events)
+	}
+	return sc, ""
+}
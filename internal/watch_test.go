@@ -0,0 +1,143 @@
+package wiper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchFiles(t *testing.T) {
+	// startWatch runs WatchFiles until the test ends and returns the collected
+	// events once it stopped.
+	startWatch := func(t *testing.T, sut *Wiper) func() []Event {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.Background())
+		events := make(chan Event)
+		collected := make(chan []Event)
+		go func() {
+			all := []Event{}
+			for event := range events {
+				all = append(all, event)
+			}
+			collected <- all
+		}()
+		errs := make(chan error, 1)
+		go func() { errs <- sut.WatchFiles(ctx, events) }()
+		// give the watcher time to register the directories
+		time.Sleep(100 * time.Millisecond)
+
+		stop := func() []Event {
+			cancel()
+			require.NoError(t, <-errs)
+			return <-collected
+		}
+		t.Cleanup(func() { cancel() })
+		return stop
+	}
+
+	newBase := func(t *testing.T) string {
+		t.Helper()
+		t.Setenv("HOME", t.TempDir())
+		base := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(base, "existing.orig"), nil, 0o644))
+		return base
+	}
+
+	t.Run("green case - new matches are wiped", func(t *testing.T) {
+		base := newBase(t)
+		sut := &Wiper{WipeOutPattern: []string{`\.orig$`}, BaseDir: base, Watch: Watch{Debounce: "20ms"}}
+		stop := startWatch(t, sut)
+
+		require.NoError(t, os.WriteFile(filepath.Join(base, "a.orig"), nil, 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(base, "b.txt"), nil, 0o644))
+		require.NoError(t, os.MkdirAll(filepath.Join(base, "sub", "deeper"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(base, "sub", "deeper", "c.orig"), nil, 0o644))
+
+		assert.Eventually(t, func() bool {
+			return !pathExists(filepath.Join(base, "a.orig")) && !pathExists(filepath.Join(base, "sub", "deeper", "c.orig"))
+		}, 5*time.Second, 10*time.Millisecond)
+		events := stop()
+
+		assert.Len(t, eventsOfType(events, EventWiped), 2)
+		assert.FileExists(t, filepath.Join(base, "b.txt"))
+		assert.FileExists(t, filepath.Join(base, "existing.orig"))
+	})
+
+	t.Run("excluded and ignored directories are left alone", func(t *testing.T) {
+		base := newBase(t)
+		require.NoError(t, os.WriteFile(filepath.Join(base, ignoreFileName), []byte("ign/\n"), 0o644))
+		sut := &Wiper{
+			WipeOutPattern: []string{`\.orig$`},
+			ExcludeDir:     []string{"Library"},
+			RespectGit:     true,
+			BaseDir:        base,
+			Watch:          Watch{Debounce: "20ms"},
+		}
+		stop := startWatch(t, sut)
+
+		for _, dir := range []string{"Library", ".git", "ign"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(base, dir, "sub"), 0o755))
+		}
+		time.Sleep(100 * time.Millisecond)
+		for _, dir := range []string{"Library", ".git", "ign"} {
+			require.NoError(t, os.WriteFile(filepath.Join(base, dir, "keep.orig"), nil, 0o644))
+			require.NoError(t, os.WriteFile(filepath.Join(base, dir, "sub", "keep.orig"), nil, 0o644))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(base, "a.orig"), nil, 0o644))
+
+		assert.Eventually(t, func() bool { return !pathExists(filepath.Join(base, "a.orig")) }, 5*time.Second, 10*time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+		events := stop()
+
+		assert.Len(t, eventsOfType(events, EventWiped), 1)
+		for _, dir := range []string{"Library", ".git", "ign"} {
+			assert.FileExists(t, filepath.Join(base, dir, "keep.orig"))
+			assert.FileExists(t, filepath.Join(base, dir, "sub", "keep.orig"))
+		}
+	})
+
+	t.Run("matches are kept for their delay", func(t *testing.T) {
+		base := newBase(t)
+		sut := &Wiper{
+			Rules:   []Rule{{Name: "backups", Patterns: []string{`\.orig$`}, Delay: "400ms"}},
+			BaseDir: base,
+			Watch:   Watch{Debounce: "20ms"},
+		}
+		stop := startWatch(t, sut)
+
+		created := time.Now()
+		target := filepath.Join(base, "a.orig")
+		require.NoError(t, os.WriteFile(target, nil, 0o644))
+		assert.Eventually(t, func() bool { return !pathExists(target) }, 5*time.Second, 10*time.Millisecond)
+		assert.GreaterOrEqual(t, time.Since(created), 400*time.Millisecond)
+
+		wiped := eventsOfType(stop(), EventWiped)
+		require.Len(t, wiped, 1)
+		assert.Equal(t, "backups", wiped[0].Rule)
+	})
+
+	t.Run("red case - only the OS file system can be watched", func(t *testing.T) {
+		sut := &Wiper{BaseDir: "/", FS: NewMemFileSystem()}
+		events := make(chan Event)
+		go func() {
+			for range events {
+			}
+		}()
+		assert.Error(t, sut.WatchFiles(context.Background(), events))
+	})
+
+	t.Run("red case - invalid durations", func(t *testing.T) {
+		for _, sut := range []*Wiper{
+			{Watch: Watch{Debounce: "soon"}},
+			{Watch: Watch{Delay: "10x"}},
+			{Rules: []Rule{{Names: []string{"x"}, Delay: "later"}}},
+		} {
+			assert.Error(t, sut.Validate())
+		}
+	})
+}
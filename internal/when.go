@@ -0,0 +1,146 @@
+package wiper
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+)
+
+// sizeLiteral matches sizes such as 50MB or 1.5GiB in when expressions, which
+// are replaced by their number of bytes before the expression is compiled.
+var sizeLiteral = regexp.MustCompile(`\b\d+(\.\d+)?(KB|MB|GB|TB|KiB|MiB|GiB|TiB)\b`)
+
+// whenEnv declares the variables a when expression can use.
+var whenEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("name", cel.StringType),
+		cel.Variable("path", cel.StringType),
+		cel.Variable("rel_path", cel.StringType),
+		cel.Variable("ext", cel.StringType),
+		cel.Variable("size", cel.IntType),
+		cel.Variable("mtime", cel.TimestampType),
+		cel.Variable("age", cel.DurationType),
+		cel.Variable("is_dir", cel.BoolType),
+		cel.Variable("is_symlink", cel.BoolType),
+		cel.Variable("mode", cel.IntType),
+		cel.Variable("uid", cel.IntType),
+		cel.Variable("gid", cel.IntType),
+		cel.Variable("depth", cel.IntType),
+	)
+})
+
+// compileWhen compiles a when expression, which has to evaluate to a bool.
+func compileWhen(expr string) (cel.Program, error) {
+	env, err := whenEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expandSizeLiterals(expr))
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("must evaluate to bool, not %s", ast.OutputType())
+	}
+	return env.Program(ast)
+}
+
+// expandSizeLiterals replaces size literals outside of string literals.
+func expandSizeLiterals(expr string) string {
+	var result strings.Builder
+	var quote byte
+	start := 0
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+			result.WriteString(expr[start : i+1])
+			start = i + 1
+		case quote == 0 && (c == '"' || c == '\''):
+			result.WriteString(replaceSizes(expr[start:i]))
+			quote = c
+			start = i
+		}
+	}
+	if quote != 0 {
+		result.WriteString(expr[start:])
+	} else {
+		result.WriteString(replaceSizes(expr[start:]))
+	}
+	return result.String()
+}
+
+func replaceSizes(code string) string {
+	return sizeLiteral.ReplaceAllStringFunc(code, func(literal string) string {
+		size, err := ParseSize(literal)
+		if err != nil {
+			return literal
+		}
+		return strconv.FormatInt(size, 10)
+	})
+}
+
+// matchesWhen evaluates the when expression of the rule against e.
+func (r *Rule) matchesWhen(e *entry) (bool, error) {
+	if r.when == nil {
+		return true, nil
+	}
+	info, err := e.stat()
+	if err != nil {
+		return false, err
+	}
+	result, _, err := r.when.Eval(e.whenVars(info))
+	if err != nil {
+		return false, fmt.Errorf("rule %s: when: %w", r.Name, err)
+	}
+	matched, ok := result.Value().(bool)
+	return ok && matched, nil
+}
+
+// whenVars returns the values of the variables declared in whenEnv.
+func (e *entry) whenVars(info fs.FileInfo) map[string]any {
+	relPath := e.path
+	if e.base != "" {
+		if rel, err := filepath.Rel(e.base, e.path); err == nil {
+			relPath = rel
+		}
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	uid, gid := int64(-1), int64(-1)
+	if owner, group, ok := fileOwner(info); ok {
+		uid, gid = int64(owner), int64(group)
+	}
+	mode := int64(info.Mode().Perm())
+	for bit, octal := range map[fs.FileMode]int64{fs.ModeSetuid: 0o4000, fs.ModeSetgid: 0o2000, fs.ModeSticky: 0o1000} {
+		if info.Mode()&bit != 0 {
+			mode |= octal
+		}
+	}
+
+	return map[string]any{
+		"name":       e.name,
+		"path":       e.path,
+		"rel_path":   relPath,
+		"ext":        filepath.Ext(e.name),
+		"size":       info.Size(),
+		"mtime":      info.ModTime(),
+		"age":        time.Since(info.ModTime()),
+		"is_dir":     e.isDir,
+		"is_symlink": info.Mode()&fs.ModeSymlink != 0,
+		"mode":       mode,
+		"uid":        uid,
+		"gid":        gid,
+		"depth":      int64(strings.Count(relPath, "/") + 1),
+	}
+}
@@ -0,0 +1,94 @@
+package wiper
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhen(t *testing.T) {
+	large := strings.Repeat("\x00", 3<<20)
+	files := map[string]string{
+		"/base/Downloads/ubuntu.iso":   large,
+		"/base/Downloads/fresh.iso":    large,
+		"/base/Downloads/small.dmg":    strings.Repeat("\x00", 1<<10),
+		"/base/Downloads/notes.txt":    large,
+		"/base/Downloads/old/app.dmg":  large,
+		"/base/Downloads/old/keep.dmg": large,
+	}
+
+	t.Run("green case - when selects entries without names", func(t *testing.T) {
+		fsys := memTree(t, files)
+		for file := range files {
+			if file != "/base/Downloads/fresh.iso" {
+				require.NoError(t, fsys.Chtimes(file, time.Time{}, time.Now().Add(-60*24*time.Hour)))
+			}
+		}
+		sut := &Wiper{
+			Rules: []Rule{{
+				Name: "large-images",
+				When: `size > 2MB && age > duration('720h') && ext in ['.iso', '.dmg'] && name != "keep.dmg"`,
+			}},
+			BaseDir: "/base",
+			FS:      fsys,
+		}
+
+		wiped := []string{}
+		for _, event := range eventsOfType(collectEvents(sut), EventWiped) {
+			wiped = append(wiped, event.Path)
+		}
+		assert.ElementsMatch(t, []string{"/base/Downloads/ubuntu.iso", "/base/Downloads/old/app.dmg"}, wiped)
+	})
+
+	t.Run("variables", func(t *testing.T) {
+		fsys := memTree(t, files)
+		for file := range files {
+			if file != "/base/Downloads/fresh.iso" {
+				require.NoError(t, fsys.Chtimes(file, time.Time{}, time.Now().Add(-60*24*time.Hour)))
+			}
+		}
+		sut := &Wiper{BaseDir: "/base", FS: fsys}
+		for _, expr := range []string{
+			`rel_path == "Downloads/old/app.dmg"`,
+			`path == "/base/Downloads/old/app.dmg"`,
+			`depth == 3 && !is_dir && !is_symlink`,
+			`mode == 420 && uid >= 0 && gid >= 0`,
+			`mtime < timestamp('2100-01-01T00:00:00Z')`,
+			`size == 3MiB`,
+		} {
+			rule := Rule{Names: []string{"app.dmg"}, When: expr}
+			require.NoError(t, rule.compile(), expr)
+
+			matched, err := rule.matches(sut.newEntry("/base/Downloads/old/app.dmg", false, nil))
+			require.NoError(t, err, expr)
+			assert.True(t, matched, expr)
+		}
+	})
+
+	t.Run("size literals in strings are kept", func(t *testing.T) {
+		assert.Equal(t, `size > 50000000 && name == "50MB" && ext != '1GB\'s'`, expandSizeLiterals(`size > 50MB && name == "50MB" && ext != '1GB\'s'`))
+		assert.Equal(t, "size < 1610612736", expandSizeLiterals("size < 1.5GiB"))
+	})
+
+	t.Run("when is read from the config", func(t *testing.T) {
+		viper.Reset()
+		t.Cleanup(viper.Reset)
+		viper.Set("rules", []map[string]any{{"when": "is_dir && name == 'tmp'", "dirs": true}})
+		require.NoError(t, refreshInstanceFromViper())
+
+		rules := GetInstance().Rules
+		require.Len(t, rules, 1)
+		assert.Equal(t, "is_dir && name == 'tmp'", rules[0].When)
+	})
+
+	t.Run("red case - invalid when expressions", func(t *testing.T) {
+		for _, expr := range []string{"size >", "size + 1", "unknown == 1", "name > 3"} {
+			sut := Wiper{Rules: []Rule{{When: expr}}}
+			assert.Error(t, sut.Validate(), expr)
+		}
+	})
+}
@@ -1,34 +1,63 @@
 package wiper
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"io/fs"
 	"path"
 	"path/filepath"
-	"regexp"
 	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/steffakasid/eslog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Wiper struct {
-	WipeOut            []string `json:"wipe_out,omitempty" mapstructure:"wipe_out" yaml:"wipe_out"`
-	WipeOutPattern     []string `json:"wipe_out_pattern,omitempty"  mapstructure:"wipe_out_pattern"  yaml:"wipe_out_pattern"`
-	WipeOutDirs        []string `json:"wipe_out_dirs,omitempty" mapstructure:"wipe_out_dirs" yaml:"wipe_out_dirs"`
-	WipeOutPatternDirs []string `json:"wipe_out_pattern_dirs,omitempty" mapstructure:"wipe_out_pattern_dirs" yaml:"wipe_out_pattern_dirs"`
-	ExcludeFile        []string `json:"exclude_file,omitempty" mapstructure:"exclude_file" yaml:"exclude_file"`
-	ExcludeDir         []string `json:"exclude_dir,omitempty" mapstructure:"exclude_dir" yaml:"exclude_dir"`
-	BaseDir            string   `json:"base_dir,omitempty" mapstructure:"base_dir" yaml:"base_dir"`
-	UseTrash           bool     `json:"use_trash,omitempty" mapstructure:"use_trash" yaml:"use_trash"`
-	InspectedFiles     int      `json:"-"`
-	WipedFiles         int      `json:"-"`
-	InspectedDirs      int      `json:"-"`
-	WipedDirs          int      `json:"-"`
-	mu                 sync.Mutex
-	trashMu            sync.Mutex
+	WipeOut                []string       `json:"wipe_out,omitempty" mapstructure:"wipe_out" yaml:"wipe_out"`
+	WipeOutPattern         []string       `json:"wipe_out_pattern,omitempty"  mapstructure:"wipe_out_pattern"  yaml:"wipe_out_pattern"`
+	WipeOutDirs            []string       `json:"wipe_out_dirs,omitempty" mapstructure:"wipe_out_dirs" yaml:"wipe_out_dirs"`
+	WipeOutPatternDirs     []string       `json:"wipe_out_pattern_dirs,omitempty" mapstructure:"wipe_out_pattern_dirs" yaml:"wipe_out_pattern_dirs"`
+	ExcludeFile            []string       `json:"exclude_file,omitempty" mapstructure:"exclude_file" yaml:"exclude_file"`
+	ExcludeDir             []string       `json:"exclude_dir,omitempty" mapstructure:"exclude_dir" yaml:"exclude_dir"`
+	BaseDir                string         `json:"base_dir,omitempty" mapstructure:"base_dir" yaml:"base_dir"`
+	UseTrash               bool           `json:"use_trash,omitempty" mapstructure:"use_trash" yaml:"use_trash"`
+	Action                 string         `json:"action,omitempty" mapstructure:"action" yaml:"action"`
+	Archive                Archive        `json:"archive,omitempty" mapstructure:"archive" yaml:"archive"`
+	QuarantineDir          string         `json:"quarantine_dir,omitempty" mapstructure:"quarantine_dir" yaml:"quarantine_dir"`
+	TrashRetention         TrashRetention `json:"trash_retention,omitempty" mapstructure:"trash_retention" yaml:"trash_retention"`
+	Hooks                  Hooks          `json:"hooks,omitempty" mapstructure:"hooks" yaml:"hooks"`
+	Rules                  []Rule         `json:"rules,omitempty" mapstructure:"rules" yaml:"rules"`
+	Presets                []string       `json:"preset,omitempty" mapstructure:"preset" yaml:"preset"`
+	RespectGit             bool           `json:"respect_git,omitempty" mapstructure:"respect_git" yaml:"respect_git"`
+	GitIgnoredOnly         bool           `json:"git_ignored_only,omitempty" mapstructure:"git_ignored_only" yaml:"git_ignored_only"`
+	OnlyOwnedByCurrentUser bool           `json:"only_owned_by_current_user,omitempty" mapstructure:"only_owned_by_current_user" yaml:"only_owned_by_current_user"`
+	Shred                  Shred          `json:"shred,omitempty" mapstructure:"shred" yaml:"shred"`
+	Dupes                  Dupes          `json:"dupes,omitempty" mapstructure:"dupes" yaml:"dupes"`
+	Watch                  Watch          `json:"watch,omitempty" mapstructure:"watch" yaml:"watch"`
+	AuditLog               AuditLog       `json:"audit_log,omitempty" mapstructure:"audit_log" yaml:"audit_log"`
+	FS                     FileSystem     `json:"-" mapstructure:"-" yaml:"-"`
+	DryRun                 bool           `json:"-" mapstructure:"-" yaml:"-"`
+	InspectedFiles         int            `json:"-"`
+	WipedFiles             int            `json:"-"`
+	InspectedDirs          int            `json:"-"`
+	WipedDirs              int            `json:"-"`
+	WipedBytes             int64          `json:"-"`
+	mu                     sync.Mutex
+	currentDir             string
+	candidates             []Candidate
+	rulesOnce              sync.Once
+	compiledRules          []*Rule
+	trashMu                sync.Mutex
+	archiveMu              sync.Mutex
+	archiver               *archiveWriter
+	quarantineMu           sync.Mutex
+	quarantine             *quarantineRun
+	auditMu                sync.Mutex
+	auditor                *auditWriter
 }
 
 func GetInstance() *Wiper {
@@ -38,111 +67,260 @@ func GetInstance() *Wiper {
 	return wiper
 }
 
-func (w *Wiper) WipeFiles(wg *sync.WaitGroup, dir string, errChan chan error) {
+// FileSystem returns the file system the Wiper works on.
+func (w *Wiper) FileSystem() FileSystem {
+	return w.fs()
+}
+
+func (w *Wiper) fs() FileSystem {
+	if w.FS == nil {
+		return OSFileSystem{}
+	}
+	return w.FS
+}
+
+// WipeFiles walks dir (BaseDir if empty) and wipes every matching entry. Each
+// step is reported on events, which is closed once the walk is complete.
+func (w *Wiper) WipeFiles(wg *sync.WaitGroup, dir string, events chan Event) {
+	w.WipeFilesContext(context.Background(), wg, dir, events)
+}
+
+// WipeFilesContext is WipeFiles with the spans of the run below ctx.
+func (w *Wiper) WipeFilesContext(ctx context.Context, wg *sync.WaitGroup, dir string, events chan Event) {
 	if dir == "" {
 		dir = w.BaseDir
 	}
-	eslog.Debugf("CurrentDir %s", dir)
-	w.mu.Lock()
-	w.InspectedDirs++
-	w.mu.Unlock()
-
 	if wg == nil {
+		var span trace.Span
+		ctx, span = tracer.Start(ctx, "WipeFiles", trace.WithAttributes(attribute.String("wiper.base_dir", dir)))
 		wg = &sync.WaitGroup{}
 		defer func() {
 			wg.Wait()
-			close(errChan)
+			w.finishRun(events)
+			w.mu.Lock()
+			span.SetAttributes(
+				attribute.Int("wiper.inspected_files", w.InspectedFiles),
+				attribute.Int("wiper.inspected_dirs", w.InspectedDirs),
+				attribute.Int("wiper.wiped_files", w.WipedFiles),
+				attribute.Int("wiper.wiped_dirs", w.WipedDirs),
+				attribute.Int64("wiper.wiped_bytes", w.WipedBytes),
+			)
+			w.mu.Unlock()
+			span.End()
 		}()
 	}
+	w.wipeDir(ctx, wg, dir, w.rootScope(dir, events), events)
+}
 
+// wipeDir wipes the matching entries of dir and walks its subdirectories in
+// the background. parent is the scope of the closest parent directory with
+// a .wiperignore or .wiper.yaml.
+func (w *Wiper) wipeDir(ctx context.Context, wg *sync.WaitGroup, dir string, parent *scope, events chan Event) {
+	eslog.Debugf("CurrentDir %s", dir)
+	ctx, span := tracer.Start(ctx, "wipeDir", trace.WithAttributes(attribute.String("wiper.dir", dir)))
+	defer span.End()
 	trash := initTrash(w)
-
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		errChan <- err
+	entries, sc, ok := w.listDir(ctx, dir, parent, events)
+	if !ok {
 		return
 	}
 
+	kept := retained{}
 	for _, entry := range entries {
 		name := entry.Name()
 		if entry.IsDir() {
-			w.handleDir(wg, dir, trash, name, errChan)
+			w.handleDir(ctx, wg, dir, sc, trash, name, kept, events)
 		} else {
-			w.handleFile(dir, trash, name, errChan)
+			w.handleFile(ctx, dir, sc, trash, name, kept, events)
 		}
 	}
+	w.wipeRetained(ctx, kept, trash, events)
+}
+
+// listDir lists dir, enters its scope and returns the entries to walk.
+// parent is the scope of the closest parent directory with a .wiperignore or
+// .wiper.yaml. Every walk of BaseDir lists directories with it and skips
+// entries with skipDir and skipFile, so they all skip the same entries.
+func (w *Wiper) listDir(ctx context.Context, dir string, parent *scope, events chan Event) ([]fs.DirEntry, *scope, bool) {
+	w.mu.Lock()
+	w.InspectedDirs++
+	w.currentDir = dir
+	w.mu.Unlock()
+
+	events <- Event{Type: EventDirEntered, Path: dir, IsDir: true}
+	_, readSpan := tracer.Start(ctx, "ReadDir", trace.WithAttributes(attribute.String("wiper.dir", dir)))
+	entries, err := w.fs().ReadDir(dir)
+	readSpan.SetAttributes(attribute.Int("wiper.entries", len(entries)))
+	endSpan(readSpan, err)
+	if err != nil {
+		events <- errorEvent("readdir", dir, true, err)
+		return nil, nil, false
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sc := w.enterScope(parent, dir, names, events)
+	// .wiperignore and .wiper.yaml belong to the scope, not to the entries
+	entries = slices.DeleteFunc(entries, func(entry fs.DirEntry) bool {
+		return !entry.IsDir() && isScopeFile(entry.Name())
+	})
+	return entries, sc, true
+}
+
+// skipDir returns why walks don't descend into the directory target, or ""
+// if they do. sc is the scope of the directory containing target.
+func (w *Wiper) skipDir(sc *scope, target string) string {
+	name := filepath.Base(target)
+	switch {
+	case w.ownPath(target):
+		return "wiper directory"
+	case slices.Contains(w.ExcludeDir, name):
+		return "exclude_dir"
+	case sc.ignored(target, true):
+		return ignoreFileName
+	case w.RespectGit && name == gitDirName:
+		return gitDirName
+	}
+	return ""
+}
+
+// skipFile returns why walks leave the file target alone, or "" if they
+// don't. sc is the scope of the directory containing target.
+func (w *Wiper) skipFile(sc *scope, target string) string {
+	name := filepath.Base(target)
+	switch {
+	case slices.Contains(w.ExcludeFile, name):
+		return "exclude_file"
+	case sc.ignored(target, false):
+		return ignoreFileName
+	}
+	return ""
+}
+
+// finishRun closes the archive and quarantine manifest of the run and then
+// events.
+func (w *Wiper) finishRun(events chan Event) {
+	if err := w.closeArchive(); err != nil {
+		events <- errorEvent("archive", w.Archive.dir(), false, err)
+	}
+	if err := w.closeQuarantine(); err != nil {
+		events <- errorEvent("quarantine", w.QuarantineDir, false, err)
+	}
+	if err := w.closeAuditLog(); err != nil {
+		events <- errorEvent("audit", w.AuditLog.Path, false, err)
+	}
+	close(events)
 }
 
 func initTrash(w *Wiper) string {
-	home, _ := os.UserHomeDir()
-	trash := path.Join(home, ".Trash")
-	if w.UseTrash && !dirExists(trash) {
-		_ = os.Mkdir(trash, 0700)
+	trash := TrashDir()
+	if w.usesAction(ActionTrash) && !existsOn(w.fs(), trash) {
+		_ = w.fs().Mkdir(trash, 0700)
 	}
 	return trash
 }
 
-func (w *Wiper) handleDir(wg *sync.WaitGroup, dir, trash, name string, errChan chan error) {
-	if slices.Contains(w.ExcludeDir, name) {
+// ownPath reports whether target is one of the directories wiper moves
+// entries to or keeps its state in, or lies within one. Runs never descend
+// into them, so they don't wipe what an earlier run kept.
+func (w *Wiper) ownPath(target string) bool {
+	for _, dir := range []string{TrashDir(), w.QuarantineDir, w.Archive.dir(), stateDir()} {
+		if dir != "" && (target == filepath.Clean(dir) || isWithin(filepath.Clean(dir), target)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Wiper) handleDir(ctx context.Context, wg *sync.WaitGroup, dir string, sc *scope, trash, name string, kept retained, events chan Event) {
+	target := path.Join(dir, name)
+	ctx, span := tracer.Start(ctx, "handleDir", trace.WithAttributes(attribute.String("wiper.path", target)))
+	defer span.End()
+	if reason := w.skipDir(sc, target); reason != "" {
+		events <- Event{Type: EventSkipped, Path: target, IsDir: true, Reason: reason}
 		return
 	}
-	if w.shouldWipe(name, true) {
-		w.mu.Lock()
-		w.WipedDirs++
-		w.mu.Unlock()
-		target := path.Join(dir, name)
-		var err error
-		if w.UseTrash {
-			err = w.moveToTrash(target, trash, true)
-		} else {
-			err = os.RemoveAll(target)
-		}
-		if err != nil {
-			errChan <- err
+	rule, err := w.matchingRule(w.newEntry(target, true, sc))
+	if err != nil {
+		events <- errorEvent("match", target, true, err)
+	}
+	// protected walks the whole tree with only_owned_by_current_user, so it
+	// is only asked about matched directories
+	if rule != nil {
+		if reason := w.protected(sc, target, true); reason != "" {
+			events <- Event{Type: EventSkipped, Path: target, IsDir: true, Rule: rule.Name, Reason: reason}
+			rule = nil
 		}
+	}
+	if rule != nil && rule.KeepNewest > 0 {
+		w.retain(kept, rule, target, true, events)
+		return
+	}
+	if rule != nil {
+		w.wipe(ctx, target, trash, rule, true, events)
 		return
 	}
 	wg.Add(1)
 	go func(subDir string) {
 		defer wg.Done()
-		w.WipeFiles(wg, subDir, errChan)
-	}(path.Join(dir, name))
+		w.wipeDir(ctx, wg, subDir, sc, events)
+	}(target)
 }
 
-func (w *Wiper) handleFile(dir, trash, name string, errChan chan error) {
+func (w *Wiper) handleFile(ctx context.Context, dir string, sc *scope, trash, name string, kept retained, events chan Event) {
 	w.mu.Lock()
 	w.InspectedFiles++
 	w.mu.Unlock()
 
-	if !w.shouldWipe(name, false) {
+	target := path.Join(dir, name)
+	ctx, span := tracer.Start(ctx, "handleFile", trace.WithAttributes(attribute.String("wiper.path", target)))
+	defer span.End()
+	if reason := w.skipFile(sc, target); reason != "" {
+		events <- Event{Type: EventSkipped, Path: target, Reason: reason}
 		return
 	}
-
-	w.mu.Lock()
-	w.WipedFiles++
-	w.mu.Unlock()
-
-	var err error
-	if w.UseTrash {
-		err = w.moveToTrash(path.Join(dir, name), trash, false)
-	} else {
-		err = os.Remove(path.Join(dir, name))
-	}
+	rule, err := w.matchingRule(w.newEntry(target, false, sc))
 	if err != nil {
-		errChan <- err
+		events <- errorEvent("match", target, false, err)
 	}
+	if rule == nil {
+		return
+	}
+	if reason := w.protected(sc, target, false); reason != "" {
+		events <- Event{Type: EventSkipped, Path: target, Rule: rule.Name, Reason: reason}
+		return
+	}
+	if rule.KeepNewest > 0 {
+		w.retain(kept, rule, target, false, events)
+		return
+	}
+
+	w.wipe(ctx, target, trash, rule, false, events)
 }
 
-func (w *Wiper) moveToTrash(sourcePath, trash string, isDir bool) error {
+func (w *Wiper) moveToTrash(sourcePath, trash string, isDir bool) (string, error) {
 	w.trashMu.Lock()
-	defer w.trashMu.Unlock()
+	// rules of a .wiper.yaml may use the trash even if initTrash did not
+	// create it
+	err := mkdirAll(w.fs(), trash, 0o700)
+	destination := uniqueTrashDestination(w.fs(), trash, filepath.Base(sourcePath), isDir)
+	if err == nil {
+		err = w.fs().Rename(sourcePath, destination)
+	}
+	w.trashMu.Unlock()
+	if err != nil {
+		return destination, err
+	}
 
-	return os.Rename(sourcePath, uniqueTrashDestination(trash, filepath.Base(sourcePath), isDir))
+	t := &Trash{Dir: trash, FS: w.fs()}
+	return destination, t.record(sourcePath, destination, isDir)
 }
 
-func uniqueTrashDestination(trash, name string, isDir bool) string {
+func uniqueTrashDestination(fsys FileSystem, trash, name string, isDir bool) string {
 	destination := filepath.Join(trash, name)
-	if !pathExists(destination) {
+	if !existsOn(fsys, destination) {
 		return destination
 	}
 
@@ -154,7 +332,7 @@ func uniqueTrashDestination(trash, name string, isDir bool) string {
 		}
 
 		candidate := filepath.Join(trash, trashNameWithPostfix(name, suffix, isDir))
-		if !pathExists(candidate) {
+		if !existsOn(fsys, candidate) {
 			return candidate
 		}
 	}
@@ -175,34 +353,9 @@ func trashNameWithPostfix(name, postfix string, isDir bool) string {
 }
 
 func pathExists(path string) bool {
-	_, err := os.Stat(path)
-	return !os.IsNotExist(err)
+	return existsOn(OSFileSystem{}, path)
 }
 
 func dirExists(path string) bool {
 	return pathExists(path)
 }
-
-func (w *Wiper) matchWipe(name string, items, patterns, exclude []string) bool {
-	if !slices.Contains(exclude, name) {
-		if slices.Contains(items, name) {
-			return true
-		}
-		for _, pattern := range patterns {
-			matcher, err := regexp.Compile(pattern)
-			eslog.LogIfError(err, eslog.Fatal)
-			if matcher.MatchString(name) {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-func (w *Wiper) shouldWipe(name string, isDir bool) bool {
-	if isDir {
-		return w.matchWipe(name, w.WipeOutDirs, w.WipeOutPatternDirs, w.ExcludeDir)
-	}
-
-	return w.matchWipe(name, w.WipeOut, w.WipeOutPattern, w.ExcludeFile)
-}
@@ -1,6 +1,7 @@
 package wiper
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -30,11 +31,11 @@ func findTrashEntry(t *testing.T, trashDir, prefix, suffix string) string {
 
 func TestWipeFiles(t *testing.T) {
 
-	receiveAllErrors := func(errChan chan error) []error {
+	receiveAllErrors := func(events chan Event) []error {
 		errs := []error{}
-		for err := range errChan {
-			if err != nil {
-				errs = append(errs, err)
+		for event := range events {
+			if event.Type == EventError {
+				errs = append(errs, event.Err)
 			}
 		}
 		return errs
@@ -51,9 +52,9 @@ func TestWipeFiles(t *testing.T) {
 			BaseDir: testDir,
 		}
 
-		errChan := make(chan error)
-		sut.WipeFiles(nil, "", errChan)
-		errs := receiveAllErrors(errChan)
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		errs := receiveAllErrors(events)
 		assert.Empty(t, errs)
 		assert.NoFileExists(t, fileToDelete.Name())
 	})
@@ -70,9 +71,9 @@ func TestWipeFiles(t *testing.T) {
 			WipeOutPattern: []string{pattern},
 			BaseDir:        testdir,
 		}
-		errChan := make(chan error)
-		sut.WipeFiles(nil, "", errChan)
-		errs := receiveAllErrors(errChan)
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		errs := receiveAllErrors(events)
 		assert.Empty(t, errs)
 		assert.NoFileExists(t, fileToDelete.Name())
 	})
@@ -90,9 +91,9 @@ func TestWipeFiles(t *testing.T) {
 			BaseDir: testDir,
 		}
 
-		errChan := make(chan error)
-		sut.WipeFiles(nil, "", errChan)
-		errs := receiveAllErrors(errChan)
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		errs := receiveAllErrors(events)
 		assert.Empty(t, errs)
 		assert.NoFileExists(t, fileToDelete.Name())
 		assert.FileExists(t, fileNotToDelete.Name())
@@ -115,9 +116,9 @@ func TestWipeFiles(t *testing.T) {
 			BaseDir:    testDir,
 		}
 
-		errChan := make(chan error)
-		sut.WipeFiles(nil, "", errChan)
-		errs := receiveAllErrors(errChan)
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		errs := receiveAllErrors(events)
 		assert.Empty(t, errs)
 		assert.NoFileExists(t, fileToDelete.Name())
 		assert.FileExists(t, skippedFile.Name())
@@ -133,9 +134,9 @@ func TestWipeFiles(t *testing.T) {
 			BaseDir:     testDir,
 		}
 
-		errChan := make(chan error)
-		sut.WipeFiles(nil, "", errChan)
-		errs := receiveAllErrors(errChan)
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		errs := receiveAllErrors(events)
 		assert.Empty(t, errs)
 		assert.FileExists(t, fileToExclude.Name())
 	})
@@ -156,9 +157,9 @@ func TestWipeFiles(t *testing.T) {
 			UseTrash: true,
 		}
 
-		errChan := make(chan error)
-		sut.WipeFiles(nil, "", errChan)
-		errs := receiveAllErrors(errChan)
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		errs := receiveAllErrors(events)
 		assert.Empty(t, errs)
 		assert.NoFileExists(t, fileToDelete.Name())
 		trashPath := filepath.Join(testHome, ".Trash", filepath.Base(fileToDelete.Name()))
@@ -188,9 +189,9 @@ func TestWipeFiles(t *testing.T) {
 			UseTrash: true,
 		}
 
-		errChan := make(chan error)
-		sut.WipeFiles(nil, "", errChan)
-		errs := receiveAllErrors(errChan)
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		errs := receiveAllErrors(events)
 		assert.Empty(t, errs)
 		assert.NoFileExists(t, sourceFile)
 
@@ -217,9 +218,9 @@ func TestWipeFiles(t *testing.T) {
 			BaseDir:     testDir,
 		}
 
-		errChan := make(chan error)
-		sut.WipeFiles(nil, "", errChan)
-		errs := receiveAllErrors(errChan)
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		errs := receiveAllErrors(events)
 		assert.Empty(t, errs)
 		assert.False(t, dirExists(subDir))
 	})
@@ -248,9 +249,9 @@ func TestWipeFiles(t *testing.T) {
 			UseTrash:    true,
 		}
 
-		errChan := make(chan error)
-		sut.WipeFiles(nil, "", errChan)
-		errs := receiveAllErrors(errChan)
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		errs := receiveAllErrors(events)
 		assert.Empty(t, errs)
 		assert.False(t, dirExists(sourceDir))
 		assert.FileExists(t, filepath.Join(existingTrashDir, "old.txt"))
@@ -260,6 +261,81 @@ func TestWipeFiles(t *testing.T) {
 	})
 }
 
+func TestWipeFilesMemFileSystem(t *testing.T) {
+
+	files := map[string]string{
+		"/home/user/.Trash/":          "",
+		"/base/project/main.go.orig":  "",
+		"/base/project/main.go":       "",
+		"/base/project/build/out.bin": "",
+		"/base/Library/keep.orig":     "",
+	}
+
+	t.Run("green case - rules applied to fixture tree", func(t *testing.T) {
+		fsys := memTree(t, files)
+		sut := Wiper{
+			WipeOutPattern: []string{`.*\.orig$`},
+			WipeOutDirs:    []string{"build"},
+			ExcludeDir:     []string{"Library"},
+			BaseDir:        "/base",
+			FS:             fsys,
+		}
+
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		for event := range events {
+			assert.NotEqual(t, EventError, event.Type, event.String())
+		}
+
+		assert.False(t, existsOn(fsys, "/base/project/main.go.orig"))
+		assert.False(t, existsOn(fsys, "/base/project/build"))
+		assert.True(t, existsOn(fsys, "/base/project/main.go"))
+		assert.True(t, existsOn(fsys, "/base/Library/keep.orig"))
+		assert.Equal(t, 2, sut.InspectedDirs)
+		assert.Equal(t, 1, sut.WipedFiles)
+		assert.Equal(t, 1, sut.WipedDirs)
+	})
+
+	t.Run("UseTrash renames into trash on the same file system", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		fsys := memTree(t, files)
+		sut := Wiper{
+			WipeOutDirs: []string{"build"},
+			BaseDir:     "/base",
+			UseTrash:    true,
+			FS:          fsys,
+		}
+
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		for event := range events {
+			assert.NotEqual(t, EventError, event.Type, event.String())
+		}
+
+		assert.False(t, existsOn(fsys, "/base/project/build"))
+		assert.True(t, existsOn(fsys, "/home/user/.Trash/build/out.bin"))
+	})
+
+	t.Run("red case - missing base dir reported", func(t *testing.T) {
+		sut := Wiper{
+			BaseDir: "/missing",
+			FS:      NewMemFileSystem(),
+		}
+
+		events := make(chan Event)
+		go sut.WipeFiles(nil, "", events)
+		errs := []Event{}
+		for event := range events {
+			if event.Type == EventError {
+				errs = append(errs, event)
+			}
+		}
+		require.Len(t, errs, 1)
+		assert.Equal(t, "readdir", errs[0].Op)
+		assert.Equal(t, "/missing", errs[0].Path)
+	})
+}
+
 func TestDirExists(t *testing.T) {
 	t.Run("green case - directory exists", func(t *testing.T) {
 		testDir := t.TempDir()
@@ -283,7 +359,15 @@ func TestDirExists(t *testing.T) {
 	})
 }
 
-func TestMatchWipe(t *testing.T) {
+// matches reports whether a rule of sut matches target.
+func matches(t *testing.T, sut *Wiper, target string, isDir bool) bool {
+	t.Helper()
+	rule, err := sut.matchingRule(sut.newEntry(target, isDir, nil))
+	require.NoError(t, err)
+	return rule != nil
+}
+
+func TestMatchingRule(t *testing.T) {
 	tests := []struct {
 		name     string
 		itemName string
@@ -344,78 +428,69 @@ func TestMatchWipe(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			sut := Wiper{}
-			result := sut.matchWipe(tt.itemName, tt.items, tt.patterns, tt.exclude)
-			assert.Equal(t, tt.expected, result)
+			sut := &Wiper{WipeOut: tt.items, WipeOutPattern: tt.patterns, ExcludeFile: tt.exclude}
+			assert.Equal(t, tt.expected, matches(t, sut, tt.itemName, false))
 		})
 	}
 }
 
-func TestShouldWipe(t *testing.T) {
+func TestMatchingRuleOfEntries(t *testing.T) {
 	t.Run("green case - file should be wiped", func(t *testing.T) {
-		sut := Wiper{
+		sut := &Wiper{
 			WipeOut: []string{"test.txt"},
 		}
-		result := sut.shouldWipe("test.txt", false)
-		assert.True(t, result)
+		assert.True(t, matches(t, sut, "test.txt", false))
 	})
 
 	t.Run("red case - file should not be wiped", func(t *testing.T) {
-		sut := Wiper{
+		sut := &Wiper{
 			WipeOut: []string{"delete.txt"},
 		}
-		result := sut.shouldWipe("keep.txt", false)
-		assert.False(t, result)
+		assert.False(t, matches(t, sut, "keep.txt", false))
 	})
 
 	t.Run("green case - directory should be wiped", func(t *testing.T) {
-		sut := Wiper{
+		sut := &Wiper{
 			WipeOutDirs: []string{"tempdir"},
 		}
-		result := sut.shouldWipe("tempdir", true)
-		assert.True(t, result)
+		assert.True(t, matches(t, sut, "tempdir", true))
 	})
 
 	t.Run("red case - directory should not be wiped", func(t *testing.T) {
-		sut := Wiper{
+		sut := &Wiper{
 			WipeOutDirs: []string{"tempdir"},
 		}
-		result := sut.shouldWipe("importantdir", true)
-		assert.False(t, result)
+		assert.False(t, matches(t, sut, "importantdir", true))
 	})
 
 	t.Run("file pattern match", func(t *testing.T) {
-		sut := Wiper{
+		sut := &Wiper{
 			WipeOutPattern: []string{`.*\.orig$`},
 		}
-		result := sut.shouldWipe("file.orig", false)
-		assert.True(t, result)
+		assert.True(t, matches(t, sut, "file.orig", false))
 	})
 
 	t.Run("directory pattern match", func(t *testing.T) {
-		sut := Wiper{
+		sut := &Wiper{
 			WipeOutPatternDirs: []string{`^\..*$`},
 		}
-		result := sut.shouldWipe(".hidden", true)
-		assert.True(t, result)
+		assert.True(t, matches(t, sut, ".hidden", true))
 	})
 
 	t.Run("excluded file", func(t *testing.T) {
-		sut := Wiper{
+		sut := &Wiper{
 			WipeOut:     []string{"file.txt"},
 			ExcludeFile: []string{"file.txt"},
 		}
-		result := sut.shouldWipe("file.txt", false)
-		assert.False(t, result)
+		assert.False(t, matches(t, sut, "file.txt", false))
 	})
 
 	t.Run("excluded directory", func(t *testing.T) {
-		sut := Wiper{
+		sut := &Wiper{
 			WipeOutDirs: []string{"dir"},
 			ExcludeDir:  []string{"dir"},
 		}
-		result := sut.shouldWipe("dir", true)
-		assert.False(t, result)
+		assert.False(t, matches(t, sut, "dir", true))
 	})
 }
 
@@ -475,15 +550,15 @@ func TestHandleDir(t *testing.T) {
 		}
 
 		var wg sync.WaitGroup
-		errChan := make(chan error)
+		events := make(chan Event)
 		go func() {
-			for range errChan {
+			for range events {
 			}
 		}()
 
-		sut.handleDir(&wg, testDir, filepath.Join(testDir, ".Trash"), "todelete", errChan)
+		sut.handleDir(context.Background(), &wg, testDir, nil, filepath.Join(testDir, ".Trash"), "todelete", retained{}, events)
 		wg.Wait()
-		close(errChan)
+		close(events)
 
 		assert.False(t, dirExists(subDir))
 		assert.Equal(t, 1, sut.WipedDirs)
@@ -508,9 +583,9 @@ func TestHandleDir(t *testing.T) {
 		}
 
 		var wg sync.WaitGroup
-		errChan := make(chan error, 10)
+		events := make(chan Event, 10)
 
-		sut.handleDir(&wg, testDir, trashDir, "todelete", errChan)
+		sut.handleDir(context.Background(), &wg, testDir, nil, trashDir, "todelete", retained{}, events)
 		wg.Wait()
 
 		assert.False(t, dirExists(subDir))
@@ -528,9 +603,9 @@ func TestHandleDir(t *testing.T) {
 		}
 
 		var wg sync.WaitGroup
-		errChan := make(chan error, 10)
+		events := make(chan Event, 10)
 
-		sut.handleDir(&wg, testDir, filepath.Join(testDir, ".Trash"), "keepdir", errChan)
+		sut.handleDir(context.Background(), &wg, testDir, nil, filepath.Join(testDir, ".Trash"), "keepdir", retained{}, events)
 
 		assert.True(t, dirExists(subDir), "directory should not be deleted when excluded")
 		assert.Equal(t, 0, sut.WipedDirs)
@@ -546,9 +621,9 @@ func TestHandleDir(t *testing.T) {
 		}
 
 		var wg sync.WaitGroup
-		errChan := make(chan error, 10)
+		events := make(chan Event, 10)
 
-		sut.handleDir(&wg, testDir, filepath.Join(testDir, ".Trash"), "keepdir", errChan)
+		sut.handleDir(context.Background(), &wg, testDir, nil, filepath.Join(testDir, ".Trash"), "keepdir", retained{}, events)
 		wg.Wait()
 
 		assert.True(t, dirExists(subDir), "directory should not be deleted when not matching")
@@ -567,8 +642,8 @@ func TestHandleFile(t *testing.T) {
 			WipeOut: []string{filepath.Base(file.Name())},
 		}
 
-		errChan := make(chan error, 10)
-		sut.handleFile(testDir, filepath.Join(testDir, ".Trash"), filepath.Base(file.Name()), errChan)
+		events := make(chan Event, 10)
+		sut.handleFile(context.Background(), testDir, nil, filepath.Join(testDir, ".Trash"), filepath.Base(file.Name()), retained{}, events)
 
 		assert.NoFileExists(t, file.Name())
 		assert.Equal(t, 1, sut.WipedFiles)
@@ -589,8 +664,8 @@ func TestHandleFile(t *testing.T) {
 			UseTrash: true,
 		}
 
-		errChan := make(chan error, 10)
-		sut.handleFile(testDir, trash, fileName, errChan)
+		events := make(chan Event, 10)
+		sut.handleFile(context.Background(), testDir, nil, trash, fileName, retained{}, events)
 
 		assert.NoFileExists(t, file.Name())
 		assert.FileExists(t, filepath.Join(trash, fileName))
@@ -608,8 +683,8 @@ func TestHandleFile(t *testing.T) {
 			WipeOut: []string{"todelete.txt"},
 		}
 
-		errChan := make(chan error, 10)
-		sut.handleFile(testDir, filepath.Join(testDir, ".Trash"), fileName, errChan)
+		events := make(chan Event, 10)
+		sut.handleFile(context.Background(), testDir, nil, filepath.Join(testDir, ".Trash"), fileName, retained{}, events)
 
 		assert.FileExists(t, file.Name())
 		assert.Equal(t, 0, sut.WipedFiles)
@@ -627,17 +702,19 @@ func TestHandleFile(t *testing.T) {
 			WipeOut: []string{"file.txt"},
 		}
 
-		errChan := make(chan error, 10)
-		sut.handleFile(readOnlyDir, filepath.Join(readOnlyDir, ".Trash"), "file.txt", errChan)
+		events := make(chan Event, 10)
+		sut.handleFile(context.Background(), readOnlyDir, nil, filepath.Join(readOnlyDir, ".Trash"), "file.txt", retained{}, events)
 
-		close(errChan)
-		errs := make([]error, 0)
-		for err := range errChan {
-			if err != nil {
-				errs = append(errs, err)
+		close(events)
+		errs := make([]Event, 0)
+		for event := range events {
+			if event.Type == EventError {
+				errs = append(errs, event)
 			}
 		}
-		assert.NotEmpty(t, errs, "should report error when file deletion fails")
+		require.NotEmpty(t, errs, "should report error when file deletion fails")
+		assert.Equal(t, "remove", errs[0].Op)
+		assert.Equal(t, filepath.Join(readOnlyDir, "file.txt"), errs[0].Path)
 	})
 }
 